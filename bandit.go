@@ -0,0 +1,564 @@
+package monoikos
+
+import (
+	"math"
+	"math/rand"
+	"strconv"
+)
+
+var banditContextKey = "bandit"
+var banditDoneContextKey = "done"
+var banditRewardContextKey = "reward"
+
+// Arm is a single arm of a multi-armed bandit.  Pulling it pays out a random reward, and its mean is
+// the reward it pays out on average.  Step is called on every arm after any arm is pulled, which
+// gives arms whose mean changes over time the chance to change it.
+type Arm interface {
+	Pull() float64
+	GetMean() float64
+	Step()
+}
+
+// BernoulliArm is an arm that pays out one with a fixed probability and zero otherwise.
+type BernoulliArm struct {
+	Probability float64
+}
+
+// Pull pays out one with the arm probability, and zero otherwise.
+func (this *BernoulliArm) Pull() float64 {
+
+	if rand.Float64() < this.Probability {
+
+		return 1
+	}
+
+	return 0
+}
+
+// GetMean returns the arm probability.
+func (this *BernoulliArm) GetMean() float64 {
+
+	return this.Probability
+}
+
+// Step does nothing, since the arm never changes.
+func (this *BernoulliArm) Step() {
+}
+
+// GaussianArm is an arm that pays out a normally distributed reward.
+type GaussianArm struct {
+	Mean              float64
+	StandardDeviation float64
+}
+
+// Pull pays out a reward drawn from the arm distribution.
+func (this *GaussianArm) Pull() float64 {
+
+	return this.Mean + rand.NormFloat64()*this.StandardDeviation
+}
+
+// GetMean returns the arm mean.
+func (this *GaussianArm) GetMean() float64 {
+
+	return this.Mean
+}
+
+// Step does nothing, since the arm never changes.
+func (this *GaussianArm) Step() {
+}
+
+// DriftingArm is a non-stationary arm that pays out a normally distributed reward whose mean takes a
+// random walk, moving by a normally distributed amount with the drift as its standard deviation
+// every time any arm is pulled.
+type DriftingArm struct {
+	Mean              float64
+	StandardDeviation float64
+	Drift             float64
+}
+
+// Pull pays out a reward drawn from the arm distribution.
+func (this *DriftingArm) Pull() float64 {
+
+	return this.Mean + rand.NormFloat64()*this.StandardDeviation
+}
+
+// GetMean returns the current arm mean.
+func (this *DriftingArm) GetMean() float64 {
+
+	return this.Mean
+}
+
+// Step moves the mean of the arm.
+func (this *DriftingArm) Step() {
+
+	this.Mean += rand.NormFloat64() * this.Drift
+}
+
+// Bandit is a k-armed bandit.  It can be used directly by bandit agents, and it also implements
+// Environment, where every experiment is a single decision of which arm to pull.  State rewards are
+// integers, so rewards are rounded when the bandit is used as an Environment.
+type Bandit struct {
+	Arms []Arm
+}
+
+// NewBandit should be used to create a Bandit.
+func NewBandit(arms ...Arm) *Bandit {
+
+	bandit := new(Bandit)
+	bandit.Arms = arms
+	return bandit
+}
+
+// Pull pulls an arm and returns its reward, and then steps every arm.
+func (this *Bandit) Pull(arm int) float64 {
+
+	reward := this.Arms[arm].Pull()
+	for _, a := range this.Arms {
+
+		a.Step()
+	}
+
+	return reward
+}
+
+// GetBestArm returns the arm with the highest mean right now.
+func (this *Bandit) GetBestArm() int {
+
+	best := 0
+	for i, arm := range this.Arms {
+
+		if arm.GetMean() > this.Arms[best].GetMean() {
+
+			best = i
+		}
+	}
+
+	return best
+}
+
+// CreateRandomPolicy creates a random policy for the bandit.
+func (this *Bandit) CreateRandomPolicy() Policy {
+
+	return CreateRandomPolicy(this)
+}
+
+// CreateImprovedPolicy creates an improved policy for the bandit from a set of outcomes.
+func (this *Bandit) CreateImprovedPolicy(outcomes []Outcome) Policy {
+
+	return CreateImprovedPolicy(this, outcomes)
+}
+
+// CreateOptimizedPolicy creates an optimized policy for the bandit by running iterations of experiments.
+func (this *Bandit) CreateOptimizedPolicy(initialRandomizationRate int, experimentsPerIteration int, iterations int) Policy {
+
+	return CreateOptimizedPolicy(this, initialRandomizationRate, experimentsPerIteration, iterations)
+}
+
+// CreateExperiment creates an experiment that makes a single decision.
+func (this *Bandit) CreateExperiment() Experiment {
+
+	experiment := new(BanditExperiment)
+	experiment.Context = make(map[string]interface{})
+	experiment.Context[banditContextKey] = this
+	experiment.Context[banditDoneContextKey] = false
+	experiment.Context[banditRewardContextKey] = 0
+
+	return experiment
+}
+
+// GetLegalActions returns an action for pulling each arm.
+func (this *Bandit) GetLegalActions(state State) []Action {
+
+	actions := make([]Action, len(this.Arms))
+	for i := range this.Arms {
+
+		actions[i] = &BanditAction{Arm: i}
+	}
+
+	return actions
+}
+
+// GetKnownStates returns the only state that a decision is ever made in.
+func (this *Bandit) GetKnownStates() []State {
+
+	states := make([]State, 1)
+	states[0] = createBanditState(false, 0)
+	return states
+}
+
+// createBanditState creates the state before or after the decision.
+func createBanditState(done bool, reward int) *BasicState {
+
+	state := NewBasicState()
+	state.Context[banditDoneContextKey] = strconv.FormatBool(done)
+	state.Terminal = done
+	state.Reward = reward
+
+	return state
+}
+
+// BanditExperiment is a single decision of which arm of a Bandit to pull.
+type BanditExperiment struct {
+	Context map[string]interface{}
+	runnable
+}
+
+// ObserveState returns the state before or after the decision.
+func (this *BanditExperiment) ObserveState() State {
+
+	done := this.Context[banditDoneContextKey].(bool)
+	reward := this.Context[banditRewardContextKey].(int)
+	return createBanditState(done, reward)
+}
+
+// Run pulls the arm that the policy picks.
+func (this *BanditExperiment) Run(policy Policy) []Outcome {
+
+	return this.getRunner().Run(this, policy)
+}
+
+// ForceRun pulls the given arm.
+func (this *BanditExperiment) ForceRun(action Action, policy Policy) []Outcome {
+
+	return this.getRunner().ForceRun(this, action, policy)
+}
+
+// Apply runs an action against the experiment context.
+func (this *BanditExperiment) Apply(action Action) {
+
+	action.Run(this.Context)
+}
+
+// BanditAction pulls an arm of a Bandit.
+type BanditAction struct {
+	Arm int
+}
+
+// GetId returns an identifier for the arm.
+func (this *BanditAction) GetId() string {
+
+	return "Arm " + strconv.Itoa(this.Arm)
+}
+
+// Run pulls the arm and rounds the reward.
+func (this *BanditAction) Run(context map[string]interface{}) {
+
+	bandit := context[banditContextKey].(*Bandit)
+	context[banditRewardContextKey] = int(math.Round(bandit.Pull(this.Arm)))
+	context[banditDoneContextKey] = true
+}
+
+// BanditAgent is a strategy for repeatedly picking an arm of a bandit and learning from the reward.
+type BanditAgent interface {
+	SelectArm() int
+	Update(arm int, reward float64)
+}
+
+// EpsilonGreedyAgent picks a random arm with probability epsilon, and otherwise picks the arm with the
+// highest average reward so far.  A non-zero step size replaces the average with an exponentially
+// weighted one, which keeps up with non-stationary arms.
+type EpsilonGreedyAgent struct {
+	Epsilon  float64
+	StepSize float64
+	Counts   []int
+	Values   []float64
+}
+
+// NewEpsilonGreedyAgent should be used to create an EpsilonGreedyAgent for a bandit with k arms.
+func NewEpsilonGreedyAgent(k int, epsilon float64) *EpsilonGreedyAgent {
+
+	agent := new(EpsilonGreedyAgent)
+	agent.Epsilon = epsilon
+	agent.Counts = make([]int, k)
+	agent.Values = make([]float64, k)
+	return agent
+}
+
+// SelectArm explores with probability epsilon, and otherwise exploits.
+func (this *EpsilonGreedyAgent) SelectArm() int {
+
+	if rand.Float64() < this.Epsilon {
+
+		return rand.Intn(len(this.Values))
+	}
+
+	return getMaxIndex(this.Values)
+}
+
+// Update moves the value of the arm towards the reward.
+func (this *EpsilonGreedyAgent) Update(arm int, reward float64) {
+
+	this.Counts[arm]++
+	stepSize := this.StepSize
+	if stepSize == 0 {
+
+		stepSize = 1 / float64(this.Counts[arm])
+	}
+
+	this.Values[arm] += stepSize * (reward - this.Values[arm])
+}
+
+// UCB1Agent picks the arm with the highest upper confidence bound on its mean, which balances the
+// average reward of an arm against how rarely it's been tried.
+type UCB1Agent struct {
+	Counts []int
+	Values []float64
+	total  int
+}
+
+// NewUCB1Agent should be used to create a UCB1Agent for a bandit with k arms.
+func NewUCB1Agent(k int) *UCB1Agent {
+
+	agent := new(UCB1Agent)
+	agent.Counts = make([]int, k)
+	agent.Values = make([]float64, k)
+	return agent
+}
+
+// SelectArm tries every arm once, and then picks the arm with the highest upper confidence bound.
+func (this *UCB1Agent) SelectArm() int {
+
+	bounds := make([]float64, len(this.Values))
+	for i, value := range this.Values {
+
+		if this.Counts[i] == 0 {
+
+			return i
+		}
+
+		bounds[i] = value + math.Sqrt(2*math.Log(float64(this.total))/float64(this.Counts[i]))
+	}
+
+	return getMaxIndex(bounds)
+}
+
+// Update adds the reward to the average for the arm.
+func (this *UCB1Agent) Update(arm int, reward float64) {
+
+	this.total++
+	this.Counts[arm]++
+	this.Values[arm] += (reward - this.Values[arm]) / float64(this.Counts[arm])
+}
+
+// ThompsonAgent keeps a beta distribution over the mean of each arm, and picks the arm whose sampled
+// mean is highest.  Rewards are expected to be between zero and one; anything else is clamped, and
+// rewards in between are treated as a success with that probability.
+type ThompsonAgent struct {
+	Successes []float64
+	Failures  []float64
+}
+
+// NewThompsonAgent should be used to create a ThompsonAgent for a bandit with k arms.
+func NewThompsonAgent(k int) *ThompsonAgent {
+
+	agent := new(ThompsonAgent)
+	agent.Successes = make([]float64, k)
+	agent.Failures = make([]float64, k)
+	return agent
+}
+
+// SelectArm samples a mean for every arm from its posterior and picks the highest.
+func (this *ThompsonAgent) SelectArm() int {
+
+	samples := make([]float64, len(this.Successes))
+	for i := range samples {
+
+		samples[i] = sampleBeta(this.Successes[i]+1, this.Failures[i]+1)
+	}
+
+	return getMaxIndex(samples)
+}
+
+// Update counts the reward as a success or failure for the arm.
+func (this *ThompsonAgent) Update(arm int, reward float64) {
+
+	if rand.Float64() < reward {
+
+		this.Successes[arm]++
+
+	} else {
+
+		this.Failures[arm]++
+	}
+}
+
+// EXP3Agent is an adversarial bandit strategy that keeps exponential weights for each arm and mixes
+// them with uniform exploration, controlled by gamma.  Rewards are expected to be between zero and one.
+type EXP3Agent struct {
+	Gamma         float64
+	Weights       []float64
+	probabilities []float64
+}
+
+// NewEXP3Agent should be used to create an EXP3Agent for a bandit with k arms.
+func NewEXP3Agent(k int, gamma float64) *EXP3Agent {
+
+	agent := new(EXP3Agent)
+	agent.Gamma = gamma
+	agent.Weights = make([]float64, k)
+	for i := range agent.Weights {
+
+		agent.Weights[i] = 1
+	}
+
+	return agent
+}
+
+// SelectArm picks an arm at random in proportion to the mixed probabilities.
+func (this *EXP3Agent) SelectArm() int {
+
+	this.probabilities = this.getProbabilities()
+	r := rand.Float64()
+	for i, probability := range this.probabilities {
+
+		r -= probability
+		if r < 0 {
+
+			return i
+		}
+	}
+
+	return len(this.probabilities) - 1
+}
+
+// Update scales up the weight of the arm by the importance weighted reward.
+func (this *EXP3Agent) Update(arm int, reward float64) {
+
+	if this.probabilities == nil {
+
+		this.probabilities = this.getProbabilities()
+	}
+
+	k := float64(len(this.Weights))
+	estimate := reward / this.probabilities[arm]
+	this.Weights[arm] *= math.Exp(this.Gamma * estimate / k)
+
+	// Keep the weights from overflowing by scaling them down together.
+	max := this.Weights[getMaxIndex(this.Weights)]
+	for i := range this.Weights {
+
+		this.Weights[i] /= max
+	}
+}
+
+// getProbabilities mixes the normalized weights with the uniform distribution.
+func (this *EXP3Agent) getProbabilities() []float64 {
+
+	total := 0.0
+	for _, weight := range this.Weights {
+
+		total += weight
+	}
+
+	k := float64(len(this.Weights))
+	probabilities := make([]float64, len(this.Weights))
+	for i, weight := range this.Weights {
+
+		probabilities[i] = (1-this.Gamma)*weight/total + this.Gamma/k
+	}
+
+	return probabilities
+}
+
+// BanditReport is the result of running an agent against a bandit; the arm pulled and reward paid out
+// for each pull, and the cumulative regret after each pull against always pulling the best arm.
+type BanditReport struct {
+	Arms        []int
+	Rewards     []float64
+	Regret      []float64
+	TotalReward float64
+}
+
+// GetRegret returns the cumulative regret at the end of the run.
+func (this *BanditReport) GetRegret() float64 {
+
+	if len(this.Regret) == 0 {
+
+		return 0
+	}
+
+	return this.Regret[len(this.Regret)-1]
+}
+
+// RunBandit lets an agent pull arms of a bandit a number of times, and reports how it did.  Regret is
+// measured with the arm means at the time of each pull, so it stays meaningful for non-stationary arms.
+func RunBandit(bandit *Bandit, agent BanditAgent, pulls int) *BanditReport {
+
+	report := new(BanditReport)
+	report.Arms = make([]int, 0)
+	report.Rewards = make([]float64, 0)
+	report.Regret = make([]float64, 0)
+
+	regret := 0.0
+	for i := 0; i < pulls; i++ {
+
+		arm := agent.SelectArm()
+		regret += bandit.Arms[bandit.GetBestArm()].GetMean() - bandit.Arms[arm].GetMean()
+
+		reward := bandit.Pull(arm)
+		agent.Update(arm, reward)
+
+		report.Arms = append(report.Arms, arm)
+		report.Rewards = append(report.Rewards, reward)
+		report.Regret = append(report.Regret, regret)
+		report.TotalReward += reward
+	}
+
+	return report
+}
+
+// getMaxIndex returns the index of the highest value, breaking ties at random.
+func getMaxIndex(values []float64) int {
+
+	best := make([]int, 0)
+	for i, value := range values {
+
+		if len(best) == 0 || value > values[best[0]] {
+
+			best = []int{i}
+
+		} else if value == values[best[0]] {
+
+			best = append(best, i)
+		}
+	}
+
+	return best[rand.Intn(len(best))]
+}
+
+// sampleBeta draws a sample from a beta distribution using two gamma samples.
+func sampleBeta(alpha float64, beta float64) float64 {
+
+	x := sampleGamma(alpha)
+	y := sampleGamma(beta)
+	return x / (x + y)
+}
+
+// sampleGamma draws a sample from a gamma distribution with unit scale, using the method of Marsaglia
+// and Tsang.  Shapes below one are boosted and then scaled back down.
+func sampleGamma(shape float64) float64 {
+
+	if shape < 1 {
+
+		return sampleGamma(shape+1) * math.Pow(rand.Float64(), 1/shape)
+	}
+
+	d := shape - 1.0/3.0
+	c := 1 / math.Sqrt(9*d)
+	for {
+
+		x := rand.NormFloat64()
+		v := 1 + c*x
+		if v <= 0 {
+
+			continue
+		}
+
+		v = v * v * v
+		u := rand.Float64()
+		if math.Log(u) < 0.5*x*x+d-d*v+d*math.Log(v) {
+
+			return d * v
+		}
+	}
+}
@@ -0,0 +1,726 @@
+package monoikos
+
+import (
+	"math"
+	"math/rand"
+	"strconv"
+)
+
+var blackjackGameContextKey = "game"
+
+// BlackjackPlayerContextKey and the other blackjack context keys are the keys used in the context of
+// blackjack states; the player total, whether it's soft, the dealer up card (with aces as one), whether
+// the player can still double, split a pair or surrender, and whether insurance is being offered.
+var (
+	BlackjackPlayerContextKey    = "player"
+	BlackjackSoftContextKey      = "soft"
+	BlackjackDealerContextKey    = "dealer"
+	BlackjackDoubleContextKey    = "double"
+	BlackjackPairContextKey      = "pair"
+	BlackjackSurrenderContextKey = "surrender"
+	BlackjackInsuranceContextKey = "insurance"
+)
+
+// BlackjackHoleContextKey is the key for the dealer hole card in the context of hidden blackjack states.
+var BlackjackHoleContextKey = "hole"
+
+// Blackjack moves, which are also the identifiers of the blackjack actions.
+const (
+	BlackjackHit         = "Hit"
+	BlackjackStand       = "Stand"
+	BlackjackDouble      = "Double"
+	BlackjackSplit       = "Split"
+	BlackjackSurrender   = "Surrender"
+	BlackjackInsurance   = "Insurance"
+	BlackjackNoInsurance = "NoInsurance"
+)
+
+// BlackjackRules are the house rules for a blackjack table.  Rewards are paid out in chips, where a
+// regular bet is the given number of chips, so that payouts like 3:2 for a blackjack stay whole.
+// Penetration is the fraction of the shoe that's dealt before it's reshuffled, and max split hands
+// caps how many hands a player can end up with by splitting and resplitting.  Late surrender lets the
+// player give up half the bet once the dealer has checked for blackjack, and insurance is a side bet
+// of half the bet that's offered when the dealer shows an ace and pays 2:1 if the dealer has blackjack.
+type BlackjackRules struct {
+	Decks            int
+	Penetration      float64
+	DealerHitsSoft17 bool
+	BlackjackPayout  float64
+	DoubleAfterSplit bool
+	MaxSplitHands    int
+	LateSurrender    bool
+	Insurance        bool
+	Bet              int
+}
+
+// NewBlackjackRules should be used to create BlackjackRules; it sets up a typical six deck shoe game.
+func NewBlackjackRules() *BlackjackRules {
+
+	rules := new(BlackjackRules)
+	rules.Decks = 6
+	rules.Penetration = 0.75
+	rules.DealerHitsSoft17 = false
+	rules.BlackjackPayout = 1.5
+	rules.DoubleAfterSplit = true
+	rules.MaxSplitHands = 4
+	rules.LateSurrender = true
+	rules.Insurance = true
+	rules.Bet = 10
+
+	return rules
+}
+
+// Shoe is a set of decks that cards are dealt from in order.  Cards are stored by value, with aces as
+// one and every ten and face card as ten.
+type Shoe struct {
+	Cards       []int
+	Position    int
+	Penetration float64
+}
+
+// NewShoe should be used to create a Shoe; it fills it with the given number of decks and shuffles it.
+func NewShoe(decks int, penetration float64) *Shoe {
+
+	shoe := new(Shoe)
+	shoe.Penetration = penetration
+	shoe.Cards = make([]int, 0)
+	for i := 0; i < decks; i++ {
+		for value := 1; value <= 13; value++ {
+			for suit := 0; suit < 4; suit++ {
+
+				shoe.Cards = append(shoe.Cards, int(math.Min(float64(value), 10)))
+			}
+		}
+	}
+
+	shoe.Shuffle()
+	return shoe
+}
+
+// Draw deals the next card, reshuffling first if the shoe has run out.
+func (this *Shoe) Draw() int {
+
+	if this.Position >= len(this.Cards) {
+
+		this.Shuffle()
+	}
+
+	card := this.Cards[this.Position]
+	this.Position++
+	return card
+}
+
+// NeedsShuffle returns whether the shoe has been dealt past its penetration.
+func (this *Shoe) NeedsShuffle() bool {
+
+	return float64(this.Position) >= this.Penetration*float64(len(this.Cards))
+}
+
+// GetDealt returns the cards that have been dealt since the last shuffle.
+func (this *Shoe) GetDealt() []int {
+
+	return this.Cards[:this.Position]
+}
+
+// GetRemainingDecks returns how many decks are left to be dealt.
+func (this *Shoe) GetRemainingDecks() float64 {
+
+	return float64(len(this.Cards)-this.Position) / 52.0
+}
+
+// Shuffle puts every card back in the shoe and shuffles it.
+func (this *Shoe) Shuffle() {
+
+	rand.Shuffle(len(this.Cards), func(i int, j int) {
+
+		this.Cards[i], this.Cards[j] = this.Cards[j], this.Cards[i]
+	})
+
+	this.Position = 0
+}
+
+// EvaluateBlackjackHand returns the best total of a hand, and whether it's soft (counting an ace as eleven).
+func EvaluateBlackjackHand(cards []int) (int, bool) {
+
+	total := 0
+	ace := false
+	for _, card := range cards {
+
+		total += card
+		if card == 1 {
+
+			ace = true
+		}
+	}
+
+	if ace && total+10 <= 21 {
+
+		return total + 10, true
+	}
+
+	return total, false
+}
+
+// IsPair returns whether a hand is a pair, which is two cards of the same value.
+func IsPair(cards []int) bool {
+
+	return len(cards) == 2 && cards[0] == cards[1]
+}
+
+// IsBlackjack returns whether a hand is a natural blackjack.
+func IsBlackjack(cards []int) bool {
+
+	total, _ := EvaluateBlackjackHand(cards)
+	return len(cards) == 2 && total == 21
+}
+
+// BlackjackHand is one of the hands a player is playing, along with the bet on it and what it paid out
+// once the game is complete.
+type BlackjackHand struct {
+	Cards       []int
+	Bet         int
+	Split       bool
+	Doubled     bool
+	Surrendered bool
+	Done        bool
+	Payout      int
+}
+
+// BlackjackGame is a single round of blackjack between a player and the dealer.  If the dealer shows an
+// ace the player first decides whether to take insurance.  The player starts with one hand, which may
+// become several by splitting, and plays them one at a time in order.  Once every hand is done the
+// dealer plays and the payout is settled across every hand and the insurance bet.  Games only share
+// the shoe they're dealt from, so games with their own shoes can be played in parallel.
+type BlackjackGame struct {
+	Rules        *BlackjackRules
+	Shoe         *Shoe
+	Hands        []*BlackjackHand
+	Active       int
+	Dealer       []int
+	Insuring     bool
+	InsuranceBet int
+	Complete     bool
+	Payout       int
+}
+
+// NewBlackjackGame should be used to create a BlackjackGame; it deals the opening cards, and then
+// either offers insurance or checks for blackjacks.
+func NewBlackjackGame(rules *BlackjackRules, shoe *Shoe) *BlackjackGame {
+
+	return NewBlackjackGameWithBet(rules, shoe, rules.Bet)
+}
+
+// NewBlackjackGameWithBet creates a BlackjackGame in the same way as NewBlackjackGame, except that
+// the player bets the given number of chips rather than the regular bet.
+func NewBlackjackGameWithBet(rules *BlackjackRules, shoe *Shoe, bet int) *BlackjackGame {
+
+	game := new(BlackjackGame)
+	game.Rules = rules
+	game.Shoe = shoe
+
+	hand := new(BlackjackHand)
+	hand.Bet = bet
+	hand.Cards = []int{shoe.Draw()}
+	game.Dealer = []int{shoe.Draw()}
+	hand.Cards = append(hand.Cards, shoe.Draw())
+	game.Dealer = append(game.Dealer, shoe.Draw())
+	game.Hands = []*BlackjackHand{hand}
+
+	if game.GetDealerUpCard() == 1 && rules.Insurance {
+
+		game.Insuring = true
+		return game
+	}
+
+	game.peek()
+	return game
+}
+
+// peek checks for blackjacks, which end the game before the player acts.
+func (this *BlackjackGame) peek() {
+
+	hand := this.GetActiveHand()
+	if IsBlackjack(hand.Cards) || IsBlackjack(this.Dealer) {
+
+		hand.Done = true
+		this.settle()
+	}
+}
+
+// TakeInsurance places an insurance bet of half the bet, and then checks for blackjacks.  It does
+// nothing if insurance isn't being offered.
+func (this *BlackjackGame) TakeInsurance() {
+
+	if !this.Insuring {
+
+		return
+	}
+
+	this.InsuranceBet = this.GetActiveHand().Bet / 2
+	this.Insuring = false
+	this.peek()
+}
+
+// DeclineInsurance turns down insurance, and then checks for blackjacks.  It does nothing if insurance
+// isn't being offered.
+func (this *BlackjackGame) DeclineInsurance() {
+
+	if !this.Insuring {
+
+		return
+	}
+
+	this.Insuring = false
+	this.peek()
+}
+
+// GetActiveHand returns the hand that's being played.
+func (this *BlackjackGame) GetActiveHand() *BlackjackHand {
+
+	return this.Hands[this.Active]
+}
+
+// GetDealerUpCard returns the dealer card that the player can see.
+func (this *BlackjackGame) GetDealerUpCard() int {
+
+	return this.Dealer[0]
+}
+
+// CanDouble returns whether the active hand can be doubled, which requires two cards, and that the hand
+// didn't come from a split unless the rules allow doubling after splitting.
+func (this *BlackjackGame) CanDouble() bool {
+
+	hand := this.GetActiveHand()
+	return this.isPlaying() && len(hand.Cards) == 2 && (!hand.Split || this.Rules.DoubleAfterSplit)
+}
+
+// CanSplit returns whether the active hand can be split, which requires a pair and room for another hand.
+func (this *BlackjackGame) CanSplit() bool {
+
+	return this.isPlaying() && IsPair(this.GetActiveHand().Cards) && len(this.Hands) < this.Rules.MaxSplitHands
+}
+
+// CanSurrender returns whether the player can still surrender, which is only on the first two cards
+// before any splits, and only if the rules allow it.
+func (this *BlackjackGame) CanSurrender() bool {
+
+	hand := this.GetActiveHand()
+	return this.isPlaying() && this.Rules.LateSurrender && len(this.Hands) == 1 && len(hand.Cards) == 2
+}
+
+// isPlaying returns whether the player is playing hands, rather than deciding on insurance or done.
+func (this *BlackjackGame) isPlaying() bool {
+
+	return !this.Complete && !this.Insuring
+}
+
+// Hit deals another card to the active hand, which is done if it busts.
+func (this *BlackjackGame) Hit() {
+
+	if !this.isPlaying() {
+
+		return
+	}
+
+	hand := this.GetActiveHand()
+	hand.Cards = append(hand.Cards, this.Shoe.Draw())
+	if total, _ := EvaluateBlackjackHand(hand.Cards); total >= 21 {
+
+		this.finishHand()
+	}
+}
+
+// Stand finishes the active hand.
+func (this *BlackjackGame) Stand() {
+
+	if !this.isPlaying() {
+
+		return
+	}
+
+	this.finishHand()
+}
+
+// Surrender gives up the hand for half the bet.  It does nothing if the player can't surrender.
+func (this *BlackjackGame) Surrender() {
+
+	if !this.CanSurrender() {
+
+		return
+	}
+
+	this.GetActiveHand().Surrendered = true
+	this.finishHand()
+}
+
+// Double doubles the bet on the active hand, deals it exactly one more card and finishes it.  It does
+// nothing if the hand can't be doubled.
+func (this *BlackjackGame) Double() {
+
+	if !this.CanDouble() {
+
+		return
+	}
+
+	hand := this.GetActiveHand()
+	hand.Bet *= 2
+	hand.Doubled = true
+	hand.Cards = append(hand.Cards, this.Shoe.Draw())
+	this.finishHand()
+}
+
+// Split splits the active pair into two hands with the same bet, and deals a second card to each.
+// Split aces only get one card each and can't be played any further.  It does nothing if the hand
+// can't be split.
+func (this *BlackjackGame) Split() {
+
+	if !this.CanSplit() {
+
+		return
+	}
+
+	hand := this.GetActiveHand()
+	other := &BlackjackHand{Cards: []int{hand.Cards[1]}, Bet: hand.Bet, Split: true}
+	hand.Cards = []int{hand.Cards[0], this.Shoe.Draw()}
+	hand.Split = true
+	other.Cards = append(other.Cards, this.Shoe.Draw())
+
+	// Put the new hand right after the one being played.
+	hands := append([]*BlackjackHand{}, this.Hands[:this.Active+1]...)
+	hands = append(hands, other)
+	this.Hands = append(hands, this.Hands[this.Active+1:]...)
+
+	if hand.Cards[0] == 1 {
+
+		hand.Done = true
+		other.Done = true
+		this.advance()
+
+	} else if total, _ := EvaluateBlackjackHand(hand.Cards); total == 21 {
+
+		this.finishHand()
+	}
+}
+
+// finishHand marks the active hand done and moves on.
+func (this *BlackjackGame) finishHand() {
+
+	this.GetActiveHand().Done = true
+	this.advance()
+}
+
+// advance moves on to the next hand that isn't done, or lets the dealer play if there aren't any.
+func (this *BlackjackGame) advance() {
+
+	for i, hand := range this.Hands {
+
+		if !hand.Done {
+
+			this.Active = i
+			return
+		}
+	}
+
+	// The dealer only needs to play if there's a hand that hasn't busted or been surrendered.
+	for _, hand := range this.Hands {
+
+		if total, _ := EvaluateBlackjackHand(hand.Cards); total <= 21 && !hand.Surrendered {
+
+			this.playDealer()
+			break
+		}
+	}
+
+	this.settle()
+}
+
+// playDealer draws cards for the dealer until it stands according to the rules.
+func (this *BlackjackGame) playDealer() {
+
+	for {
+
+		total, soft := EvaluateBlackjackHand(this.Dealer)
+		if total > 17 || (total == 17 && (!soft || !this.Rules.DealerHitsSoft17)) {
+
+			return
+		}
+
+		this.Dealer = append(this.Dealer, this.Shoe.Draw())
+	}
+}
+
+// settle works out the payout across every hand and completes the game.
+func (this *BlackjackGame) settle() {
+
+	dealer, _ := EvaluateBlackjackHand(this.Dealer)
+	dealerBlackjack := IsBlackjack(this.Dealer)
+
+	// Settle the insurance bet first, which only pays if the dealer has blackjack.
+	this.Payout = -this.InsuranceBet
+	if dealerBlackjack {
+
+		this.Payout = 2 * this.InsuranceBet
+	}
+
+	for _, hand := range this.Hands {
+
+		total, _ := EvaluateBlackjackHand(hand.Cards)
+		blackjack := IsBlackjack(hand.Cards) && len(this.Hands) == 1
+
+		hand.Payout = 0
+		switch {
+		case hand.Surrendered:
+			hand.Payout = -hand.Bet / 2
+		case blackjack && dealerBlackjack:
+		case blackjack:
+			hand.Payout = int(math.Round(float64(hand.Bet) * this.Rules.BlackjackPayout))
+		case dealerBlackjack || total > 21:
+			hand.Payout = -hand.Bet
+		case dealer > 21 || total > dealer:
+			hand.Payout = hand.Bet
+		case total < dealer:
+			hand.Payout = -hand.Bet
+		}
+
+		this.Payout += hand.Payout
+	}
+
+	this.Complete = true
+}
+
+// BlackjackEnvironment is a blackjack Environment that's played with the given house rules.  Every
+// experiment is a single round dealt from its own freshly shuffled shoe, so there's no state shared
+// between experiments and they can be run in parallel.
+type BlackjackEnvironment struct {
+	Rules *BlackjackRules
+}
+
+// NewBlackjackEnvironment should be used to create a BlackjackEnvironment; default rules are used if
+// none are given.
+func NewBlackjackEnvironment(rules *BlackjackRules) *BlackjackEnvironment {
+
+	if rules == nil {
+
+		rules = NewBlackjackRules()
+	}
+
+	environment := new(BlackjackEnvironment)
+	environment.Rules = rules
+	return environment
+}
+
+// CreateRandomPolicy creates a random blackjack policy.
+func (this *BlackjackEnvironment) CreateRandomPolicy() Policy {
+
+	return CreateRandomPolicy(this)
+}
+
+// CreateImprovedPolicy creates an improved blackjack policy from a set of outcomes.
+func (this *BlackjackEnvironment) CreateImprovedPolicy(outcomes []Outcome) Policy {
+
+	return CreateImprovedPolicy(this, outcomes)
+}
+
+// CreateOptimizedPolicy creates an optimized blackjack policy by running iterations of experiments.
+func (this *BlackjackEnvironment) CreateOptimizedPolicy(initialRandomizationRate int, experimentsPerIteration int, iterations int) Policy {
+
+	return CreateOptimizedPolicy(this, initialRandomizationRate, experimentsPerIteration, iterations)
+}
+
+// CreateExperiment deals a new round from a fresh shoe.
+func (this *BlackjackEnvironment) CreateExperiment() Experiment {
+
+	shoe := NewShoe(this.Rules.Decks, this.Rules.Penetration)
+	return NewBlackjackExperiment(NewBlackjackGame(this.Rules, shoe))
+}
+
+// GetLegalActions returns whether to take insurance if it's being offered.  Otherwise it returns Hit and
+// Stand, plus Double, Split and Surrender if the player can still make those moves.
+func (this *BlackjackEnvironment) GetLegalActions(state State) []Action {
+
+	return getBlackjackActions(state)
+}
+
+// getBlackjackActions returns the moves the player can make in a blackjack state.
+func getBlackjackActions(state State) []Action {
+
+	context := state.GetContext()
+	actions := make([]Action, 0)
+	if context[BlackjackInsuranceContextKey] == strconv.FormatBool(true) {
+
+		actions = append(actions, &BlackjackAction{Move: BlackjackInsurance})
+		actions = append(actions, &BlackjackAction{Move: BlackjackNoInsurance})
+		return actions
+	}
+
+	actions = append(actions, &BlackjackAction{Move: BlackjackHit})
+	actions = append(actions, &BlackjackAction{Move: BlackjackStand})
+	if context[BlackjackDoubleContextKey] == strconv.FormatBool(true) {
+
+		actions = append(actions, &BlackjackAction{Move: BlackjackDouble})
+	}
+
+	if context[BlackjackPairContextKey] == strconv.FormatBool(true) {
+
+		actions = append(actions, &BlackjackAction{Move: BlackjackSplit})
+	}
+
+	if context[BlackjackSurrenderContextKey] == strconv.FormatBool(true) {
+
+		actions = append(actions, &BlackjackAction{Move: BlackjackSurrender})
+	}
+
+	return actions
+}
+
+// GetKnownStates returns every state that a player can act in.
+func (this *BlackjackEnvironment) GetKnownStates() []State {
+
+	states := make([]State, 0)
+	for dealer := 1; dealer <= 10; dealer++ {
+		for player := 4; player <= 21; player++ {
+			for s := 0; s <= 1; s++ {
+				soft := s != 0
+
+				if soft && player < 12 {
+
+					continue
+				}
+
+				// Insurance is decided before anything else, so nothing else is possible.
+				if dealer == 1 {
+
+					states = append(states, createBlackjackState(player, soft, dealer, false, false, false, true))
+				}
+
+				// Surrendering is only possible on the first two cards, where doubling is too.
+				pair := (soft && player == 12) || (!soft && player%2 == 0 && player <= 20)
+				for p := 0; p <= 1; p++ {
+
+					if p != 0 && !pair {
+
+						continue
+					}
+
+					states = append(states, createBlackjackState(player, soft, dealer, false, p != 0, false, false))
+					states = append(states, createBlackjackState(player, soft, dealer, true, p != 0, false, false))
+					states = append(states, createBlackjackState(player, soft, dealer, true, p != 0, true, false))
+				}
+			}
+		}
+	}
+
+	return states
+}
+
+// createBlackjackState creates a state from the player total and everything the player can do.
+func createBlackjackState(player int, soft bool, dealer int, double bool, pair bool, surrender bool, insurance bool) *BasicState {
+
+	state := NewBasicState()
+	state.Context[BlackjackPlayerContextKey] = strconv.Itoa(player)
+	state.Context[BlackjackSoftContextKey] = strconv.FormatBool(soft)
+	state.Context[BlackjackDealerContextKey] = strconv.Itoa(dealer)
+	state.Context[BlackjackDoubleContextKey] = strconv.FormatBool(double)
+	state.Context[BlackjackPairContextKey] = strconv.FormatBool(pair)
+	state.Context[BlackjackSurrenderContextKey] = strconv.FormatBool(surrender)
+	state.Context[BlackjackInsuranceContextKey] = strconv.FormatBool(insurance)
+
+	return state
+}
+
+// BlackjackExperiment is a single round of blackjack.
+type BlackjackExperiment struct {
+	Context map[string]interface{}
+	runnable
+}
+
+// NewBlackjackExperiment should be used to create a BlackjackExperiment for a game that's been dealt.
+func NewBlackjackExperiment(game *BlackjackGame) *BlackjackExperiment {
+
+	experiment := new(BlackjackExperiment)
+	experiment.Context = make(map[string]interface{})
+	experiment.Context[blackjackGameContextKey] = game
+	return experiment
+}
+
+// ObserveState returns the state of the active hand against the dealer up card, which is terminal
+// with the payout across every hand as its reward once the game is complete.  When there are several
+// hands they're played one after the other, so a single experiment can visit several hands.
+func (this *BlackjackExperiment) ObserveState() State {
+
+	game := this.Context[blackjackGameContextKey].(*BlackjackGame)
+	return observeBlackjackGame(game)
+}
+
+// ObserveHiddenState returns the state of the game along with the dealer hole card, which the player
+// can't see until the dealer plays.
+func (this *BlackjackExperiment) ObserveHiddenState() State {
+
+	game := this.Context[blackjackGameContextKey].(*BlackjackGame)
+	state := observeBlackjackGame(game)
+	state.Context[BlackjackHoleContextKey] = strconv.Itoa(game.Dealer[1])
+	return state
+}
+
+// observeBlackjackGame creates the state of a game from the point of view of the player.
+func observeBlackjackGame(game *BlackjackGame) *BasicState {
+
+	player, soft := EvaluateBlackjackHand(game.GetActiveHand().Cards)
+
+	state := createBlackjackState(player, soft, game.GetDealerUpCard(), game.CanDouble(), game.CanSplit(), game.CanSurrender(), game.Insuring)
+	state.Terminal = game.Complete
+	state.Reward = game.Payout
+
+	return state
+}
+
+// Run plays the round out by following the policy.
+func (this *BlackjackExperiment) Run(policy Policy) []Outcome {
+
+	return this.getRunner().Run(this, policy)
+}
+
+// ForceRun makes a move and then plays the round out by following the policy.
+func (this *BlackjackExperiment) ForceRun(action Action, policy Policy) []Outcome {
+
+	return this.getRunner().ForceRun(this, action, policy)
+}
+
+// Apply runs an action against the experiment context.
+func (this *BlackjackExperiment) Apply(action Action) {
+
+	action.Run(this.Context)
+}
+
+// BlackjackAction is a move in a game of blackjack.
+type BlackjackAction struct {
+	Move string
+}
+
+// GetId returns the name of the move.
+func (this *BlackjackAction) GetId() string {
+
+	return this.Move
+}
+
+// Run makes the move in the game.
+func (this *BlackjackAction) Run(context map[string]interface{}) {
+
+	game := context[blackjackGameContextKey].(*BlackjackGame)
+	switch this.Move {
+	case BlackjackHit:
+		game.Hit()
+	case BlackjackStand:
+		game.Stand()
+	case BlackjackDouble:
+		game.Double()
+	case BlackjackSplit:
+		game.Split()
+	case BlackjackSurrender:
+		game.Surrender()
+	case BlackjackInsurance:
+		game.TakeInsurance()
+	case BlackjackNoInsurance:
+		game.DeclineInsurance()
+	}
+}
@@ -0,0 +1,264 @@
+package monoikos
+
+import (
+	"strconv"
+)
+
+var blackjackTableContextKey = "table"
+
+// BlackjackCountContextKey is the key for the true count in the context of card counting blackjack
+// states, and BlackjackBettingContextKey marks the state where the bet for the next round is placed.
+var (
+	BlackjackCountContextKey   = "count"
+	BlackjackBettingContextKey = "betting"
+)
+
+// CountingSystem is a card counting system, which tags every card value (with aces as one and every ten
+// and face card as ten) with a number that's added to the running count when the card is seen.
+type CountingSystem struct {
+	Name string
+	Tags map[int]int
+}
+
+// NewCountingSystem should be used to create a CountingSystem with custom tags; card values without a
+// tag count as zero.
+func NewCountingSystem(name string, tags map[int]int) *CountingSystem {
+
+	system := new(CountingSystem)
+	system.Name = name
+	system.Tags = tags
+	return system
+}
+
+// NewHiLoSystem creates the Hi-Lo counting system, where two thru six count as plus one, seven thru
+// nine count as zero, and tens and aces count as minus one.
+func NewHiLoSystem() *CountingSystem {
+
+	return NewCountingSystem("Hi-Lo", map[int]int{1: -1, 2: 1, 3: 1, 4: 1, 5: 1, 6: 1, 10: -1})
+}
+
+// GetRunningCount returns the running count for a set of cards that have been seen.
+func (this *CountingSystem) GetRunningCount(cards []int) int {
+
+	count := 0
+	for _, card := range cards {
+
+		count += this.Tags[card]
+	}
+
+	return count
+}
+
+// GetTrueCount returns the running count for the cards that have been dealt from a shoe, divided by
+// the number of decks that are left and truncated towards zero.
+func (this *CountingSystem) GetTrueCount(shoe *Shoe) int {
+
+	decks := shoe.GetRemainingDecks()
+	if decks < 0.5 {
+
+		decks = 0.5
+	}
+
+	return int(float64(this.GetRunningCount(shoe.GetDealt())) / decks)
+}
+
+// CountingBlackjackEnvironment is a blackjack Environment where rounds are dealt one after another from
+// a single shoe, which is only reshuffled once it's been dealt past its penetration.  Each round starts
+// with a bet of some multiple of the regular bet from the bet spread, and every state includes the true
+// count at the time the bet was placed, so that bet sizing and count dependent deviations from basic
+// strategy can be learned.  Since each round starts where the last one left off, experiments need to
+// be created and run one at a time, and can't be run in parallel.
+type CountingBlackjackEnvironment struct {
+	Rules        *BlackjackRules
+	System       *CountingSystem
+	BetSpread    []int
+	MaxTrueCount int
+	Shoe         *Shoe
+}
+
+// NewCountingBlackjackEnvironment should be used to create a CountingBlackjackEnvironment; default rules
+// and the Hi-Lo system are used if none are given.  True counts are capped at plus or minus five.
+func NewCountingBlackjackEnvironment(rules *BlackjackRules, system *CountingSystem) *CountingBlackjackEnvironment {
+
+	if rules == nil {
+
+		rules = NewBlackjackRules()
+	}
+
+	if system == nil {
+
+		system = NewHiLoSystem()
+	}
+
+	environment := new(CountingBlackjackEnvironment)
+	environment.Rules = rules
+	environment.System = system
+	environment.BetSpread = []int{1, 2, 4, 8}
+	environment.MaxTrueCount = 5
+	environment.Shoe = NewShoe(rules.Decks, rules.Penetration)
+
+	return environment
+}
+
+// CreateRandomPolicy creates a random counting blackjack policy.
+func (this *CountingBlackjackEnvironment) CreateRandomPolicy() Policy {
+
+	return CreateRandomPolicy(this)
+}
+
+// CreateImprovedPolicy creates an improved counting blackjack policy from a set of outcomes.
+func (this *CountingBlackjackEnvironment) CreateImprovedPolicy(outcomes []Outcome) Policy {
+
+	return CreateImprovedPolicy(this, outcomes)
+}
+
+// CreateOptimizedPolicy creates an optimized counting blackjack policy by running iterations of experiments.
+func (this *CountingBlackjackEnvironment) CreateOptimizedPolicy(initialRandomizationRate int, experimentsPerIteration int, iterations int) Policy {
+
+	return CreateOptimizedPolicy(this, initialRandomizationRate, experimentsPerIteration, iterations)
+}
+
+// CreateExperiment starts the next round, reshuffling first if the shoe needs it.  Cards aren't dealt
+// until the bet has been placed.
+func (this *CountingBlackjackEnvironment) CreateExperiment() Experiment {
+
+	if this.Shoe.NeedsShuffle() {
+
+		this.Shoe.Shuffle()
+	}
+
+	experiment := new(CountingBlackjackExperiment)
+	experiment.Context = make(map[string]interface{})
+	experiment.Context[blackjackTableContextKey] = this
+	experiment.Context[BlackjackCountContextKey] = this.GetTrueCount()
+
+	return experiment
+}
+
+// GetTrueCount returns the true count of the shoe, capped at the maximum true count either way.
+func (this *CountingBlackjackEnvironment) GetTrueCount() int {
+
+	count := this.System.GetTrueCount(this.Shoe)
+	if count > this.MaxTrueCount {
+
+		return this.MaxTrueCount
+	}
+
+	if count < -this.MaxTrueCount {
+
+		return -this.MaxTrueCount
+	}
+
+	return count
+}
+
+// GetLegalActions returns a bet for every multiple in the bet spread when betting, and the moves the
+// player can make otherwise.
+func (this *CountingBlackjackEnvironment) GetLegalActions(state State) []Action {
+
+	if state.GetContext()[BlackjackBettingContextKey] != strconv.FormatBool(true) {
+
+		return getBlackjackActions(state)
+	}
+
+	actions := make([]Action, 0)
+	for _, units := range this.BetSpread {
+
+		actions = append(actions, &BlackjackBetAction{Units: units})
+	}
+
+	return actions
+}
+
+// GetKnownStates returns the betting state and every blackjack state, for every true count.
+func (this *CountingBlackjackEnvironment) GetKnownStates() []State {
+
+	states := make([]State, 0)
+	blackjack := NewBlackjackEnvironment(this.Rules).GetKnownStates()
+	for count := -this.MaxTrueCount; count <= this.MaxTrueCount; count++ {
+
+		states = append(states, createBettingState(count))
+		for _, s := range blackjack {
+
+			state := NewBasicState()
+			for k, v := range s.GetContext() {
+
+				state.Context[k] = v
+			}
+
+			state.Context[BlackjackCountContextKey] = strconv.Itoa(count)
+			states = append(states, state)
+		}
+	}
+
+	return states
+}
+
+// createBettingState creates the state where the bet is placed.
+func createBettingState(count int) *BasicState {
+
+	state := NewBasicState()
+	state.Context[BlackjackBettingContextKey] = strconv.FormatBool(true)
+	state.Context[BlackjackCountContextKey] = strconv.Itoa(count)
+	return state
+}
+
+// CountingBlackjackExperiment is a single round of blackjack dealt from a shared shoe, starting with a bet.
+type CountingBlackjackExperiment struct {
+	Context map[string]interface{}
+	runnable
+}
+
+// ObserveState returns the betting state until the bet has been placed, and then the state of the game
+// along with the true count from when the bet was placed.  The count isn't updated as cards are dealt
+// during the round, since the dealer hole card would give itself away.
+func (this *CountingBlackjackExperiment) ObserveState() State {
+
+	count := this.Context[BlackjackCountContextKey].(int)
+	game, ok := this.Context[blackjackGameContextKey].(*BlackjackGame)
+	if !ok {
+
+		return createBettingState(count)
+	}
+
+	state := observeBlackjackGame(game)
+	state.Context[BlackjackCountContextKey] = strconv.Itoa(count)
+	return state
+}
+
+// Run places a bet and plays the round out by following the policy.
+func (this *CountingBlackjackExperiment) Run(policy Policy) []Outcome {
+
+	return this.getRunner().Run(this, policy)
+}
+
+// ForceRun takes an action, which should be a bet, and then plays the round out by following the policy.
+func (this *CountingBlackjackExperiment) ForceRun(action Action, policy Policy) []Outcome {
+
+	return this.getRunner().ForceRun(this, action, policy)
+}
+
+// Apply runs an action against the experiment context.
+func (this *CountingBlackjackExperiment) Apply(action Action) {
+
+	action.Run(this.Context)
+}
+
+// BlackjackBetAction places a bet of some multiple of the regular bet and deals the round.
+type BlackjackBetAction struct {
+	Units int
+}
+
+// GetId returns an identifier for the size of the bet.
+func (this *BlackjackBetAction) GetId() string {
+
+	return "Bet " + strconv.Itoa(this.Units)
+}
+
+// Run places the bet and deals the round from the shared shoe.
+func (this *BlackjackBetAction) Run(context map[string]interface{}) {
+
+	environment := context[blackjackTableContextKey].(*CountingBlackjackEnvironment)
+	bet := environment.Rules.Bet * this.Units
+	context[blackjackGameContextKey] = NewBlackjackGameWithBet(environment.Rules, environment.Shoe, bet)
+}
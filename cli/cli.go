@@ -0,0 +1,650 @@
+// Package cli is the monoikos command, which trains, evaluates, shows and compares policies for
+// registered environments without writing any Go.  It's a package rather than just a main so that
+// teams with environments of their own can build the same command with them registered.
+package cli
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/tysont/monoikos"
+	"github.com/tysont/monoikos/server"
+	"gopkg.in/yaml.v2"
+)
+
+// usage describes the subcommands.
+var usage = `Usage: monoikos <command> [flags]
+
+Commands:
+  train    train a policy and write it to a policy file
+  eval     evaluate a policy file
+  show     show what a policy file does in every state
+  compare  compare policy files against each other and a random policy
+  serve    serve a policy file over HTTP
+
+Run 'monoikos <command> -h' for the flags of a command.
+`
+
+// Config holds the settings for a command, which can be read from a JSON or YAML file and then
+// overridden with flags.  Experiments is the number of experiments per iteration when training, and
+// the total number of experiments when evaluating.  Failures is either abort or skip.
+type Config struct {
+	Environment              string            `json:"environment" yaml:"environment"`
+	Options                  map[string]string `json:"options" yaml:"options"`
+	InitialRandomizationRate int               `json:"initial_randomization_rate" yaml:"initial_randomization_rate"`
+	Experiments              int               `json:"experiments" yaml:"experiments"`
+	Iterations               int               `json:"iterations" yaml:"iterations"`
+	Duration                 string            `json:"duration" yaml:"duration"`
+	Failures                 string            `json:"failures" yaml:"failures"`
+}
+
+// NewConfig should be used to create a Config; it starts with the same defaults as training.
+func NewConfig() *Config {
+
+	training := monoikos.NewTrainingConfig()
+
+	config := new(Config)
+	config.Options = make(map[string]string)
+	config.InitialRandomizationRate = training.InitialRandomizationRate
+	config.Experiments = training.ExperimentsPerIteration
+	config.Iterations = training.Iterations
+	config.Failures = "abort"
+
+	return config
+}
+
+// LoadConfig reads a config from a file, which is parsed as YAML if it has a .yaml or .yml extension
+// and as JSON otherwise.  Anything the file leaves out keeps its default.
+func LoadConfig(path string) (*Config, error) {
+
+	b, err := ioutil.ReadFile(path)
+	if err != nil {
+
+		return nil, err
+	}
+
+	config := NewConfig()
+	extension := strings.ToLower(filepath.Ext(path))
+	if extension == ".yaml" || extension == ".yml" {
+
+		err = yaml.Unmarshal(b, config)
+
+	} else {
+
+		err = json.Unmarshal(b, config)
+	}
+
+	if config.Options == nil {
+
+		config.Options = make(map[string]string)
+	}
+
+	return config, err
+}
+
+// Report is what's written to the training report file.
+type Report struct {
+	Environment string                   `json:"environment"`
+	Options     map[string]string        `json:"options,omitempty"`
+	Version     string                   `json:"version"`
+	Training    *monoikos.TrainingReport `json:"training"`
+	Evaluation  *monoikos.Evaluation     `json:"evaluation"`
+}
+
+// Run runs the command with its arguments, not including the name of the program, and returns the exit
+// code; zero for success, one if the command failed and two if it was used wrongly.
+func Run(args []string, stdout io.Writer, stderr io.Writer) int {
+
+	if len(args) == 0 {
+
+		fmt.Fprint(stderr, usage)
+		return 2
+	}
+
+	commands := map[string]func([]string, io.Writer, io.Writer) error{
+		"train":   train,
+		"eval":    eval,
+		"show":    show,
+		"compare": compare,
+		"serve":   serve,
+	}
+
+	command, ok := commands[args[0]]
+	if !ok {
+
+		fmt.Fprint(stderr, usage)
+		return 2
+	}
+
+	err := command(args[1:], stdout, stderr)
+	if errors.Is(err, flag.ErrHelp) {
+
+		return 0
+
+	} else if errors.As(err, new(*usageError)) {
+
+		fmt.Fprintln(stderr, "monoikos: "+err.Error())
+		return 2
+
+	} else if err != nil {
+
+		fmt.Fprintln(stderr, err)
+		return 1
+	}
+
+	return 0
+}
+
+// usageError is returned when a command is used wrongly.
+type usageError struct {
+	message string
+}
+
+// Error returns the message.
+func (this *usageError) Error() string {
+
+	return this.message
+}
+
+// options is a flag that can be given more than once to set options as key=value.
+type options map[string]string
+
+// String returns the options as a comma separated list.
+func (this options) String() string {
+
+	pairs := make([]string, 0)
+	for k, v := range this {
+
+		pairs = append(pairs, k+"="+v)
+	}
+
+	sort.Strings(pairs)
+	return strings.Join(pairs, ",")
+}
+
+// Set sets an option from key=value.
+func (this options) Set(s string) error {
+
+	pair := strings.SplitN(s, "=", 2)
+	if len(pair) != 2 {
+
+		return errors.New("options have to be key=value")
+	}
+
+	this[pair[0]] = pair[1]
+	return nil
+}
+
+// parseFlags reads the config file if one is given, and then parses the flags on top of it.  The flags
+// that only some commands have are added by the function that's passed in.
+func parseFlags(name string, args []string, stderr io.Writer, add func(*flag.FlagSet, *Config)) (*Config, *flag.FlagSet, error) {
+
+	// Find the config file first, so that the flags can override it.
+	config := NewConfig()
+	for i, arg := range args {
+
+		arg = strings.TrimLeft(arg, "-")
+		if strings.HasPrefix(arg, "config=") {
+
+			return parseConfig(name, args, stderr, strings.TrimPrefix(arg, "config="), add)
+
+		} else if arg == "config" && i+1 < len(args) {
+
+			return parseConfig(name, args, stderr, args[i+1], add)
+		}
+	}
+
+	return parseArgs(name, args, stderr, config, add)
+}
+
+// parseConfig reads a config file and parses the flags on top of it.
+func parseConfig(name string, args []string, stderr io.Writer, path string, add func(*flag.FlagSet, *Config)) (*Config, *flag.FlagSet, error) {
+
+	config, err := LoadConfig(path)
+	if err != nil {
+
+		return nil, nil, err
+	}
+
+	return parseArgs(name, args, stderr, config, add)
+}
+
+// parseArgs parses the flags, with the config providing the defaults.
+func parseArgs(name string, args []string, stderr io.Writer, config *Config, add func(*flag.FlagSet, *Config)) (*Config, *flag.FlagSet, error) {
+
+	flags := flag.NewFlagSet(name, flag.ContinueOnError)
+	flags.SetOutput(stderr)
+	flags.String("config", "", "a JSON or YAML file with settings, which flags override")
+	flags.StringVar(&config.Environment, "env", config.Environment, "the registered environment, one of "+strings.Join(monoikos.GetEnvironmentNames(), ", "))
+	flags.Var(options(config.Options), "option", "an environment option as key=value, which can be given more than once")
+	if add != nil {
+
+		add(flags, config)
+	}
+
+	err := flags.Parse(args)
+	if err != nil && !errors.Is(err, flag.ErrHelp) {
+
+		return nil, nil, &usageError{message: err.Error()}
+	}
+
+	return config, flags, err
+}
+
+// createEnvironment creates the environment in the config, or the environment the policy file was
+// trained on if the config doesn't name one.
+func createEnvironment(config *Config, file *monoikos.PolicyFile) (monoikos.Environment, error) {
+
+	name := config.Environment
+	options := config.Options
+	if name == "" && file != nil {
+
+		name = file.Environment
+		options = file.Options
+	}
+
+	if name == "" {
+
+		return nil, &usageError{message: "an environment has to be given with -env"}
+	}
+
+	return monoikos.CreateEnvironment(name, options)
+}
+
+// loadPolicy reads a policy file and creates the policy for its environment.
+func loadPolicy(config *Config, path string) (monoikos.Environment, *monoikos.PolicyFile, *monoikos.BasicPolicy, error) {
+
+	if path == "" {
+
+		return nil, nil, nil, &usageError{message: "a policy file has to be given"}
+	}
+
+	file, err := monoikos.ReadPolicyFile(path)
+	if err != nil {
+
+		return nil, nil, nil, err
+	}
+
+	environment, err := createEnvironment(config, file)
+	if err != nil {
+
+		return nil, nil, nil, err
+	}
+
+	policy, err := file.CreatePolicy(environment)
+	return environment, file, policy, err
+}
+
+// train trains a policy, prints the learning curve, and writes the policy and the report.
+func train(args []string, stdout io.Writer, stderr io.Writer) error {
+
+	var out, report, version, trajectories, run string
+	config, _, err := parseFlags("train", args, stderr, func(flags *flag.FlagSet, config *Config) {
+
+		flags.IntVar(&config.InitialRandomizationRate, "rate", config.InitialRandomizationRate, "the initial randomization rate")
+		flags.IntVar(&config.Experiments, "experiments", config.Experiments, "the number of experiments per iteration")
+		flags.IntVar(&config.Iterations, "iterations", config.Iterations, "the number of iterations")
+		flags.StringVar(&config.Duration, "duration", config.Duration, "train for this long, such as 30s, instead of a number of experiments")
+		flags.StringVar(&config.Failures, "failures", config.Failures, "abort or skip experiments that fail")
+		flags.StringVar(&out, "out", "policy.json", "the policy file to write")
+		flags.StringVar(&report, "report", "", "the training report file to write, if any")
+		flags.StringVar(&version, "version", "", "the version of the policy, which defaults to the time")
+		flags.StringVar(&trajectories, "trajectories", "", "a directory to record every episode to, if any")
+		flags.StringVar(&run, "run", "", "what to call the run in every episode recorded, which defaults to the time")
+	})
+
+	if err != nil {
+
+		return err
+	}
+
+	if version == "" {
+
+		version = time.Now().UTC().Format(time.RFC3339)
+	}
+
+	training, err := createTrainingConfig(config)
+	if err != nil {
+
+		return err
+	}
+
+	environment, err := createEnvironment(config, nil)
+	if err != nil {
+
+		return err
+	}
+
+	hooks, stop, err := startRecording(trajectories, run, version)
+	if err != nil {
+
+		return err
+	}
+
+	training.Hooks = hooks
+	policy, trainingReport, err := monoikos.TrainPolicy(context.Background(), environment, training)
+	err = stopRecording(stop, err)
+	if err != nil {
+
+		return err
+	}
+
+	printLearningCurve(stdout, trainingReport.Iterations)
+
+	basicPolicy, ok := policy.(*monoikos.BasicPolicy)
+	if !ok {
+
+		return errors.New("monoikos: the " + config.Environment + " environment doesn't create policies that can be saved")
+	}
+
+	file := monoikos.NewPolicyFile(basicPolicy, trainingReport.Values, trainingReport.Visits)
+	file.Version = version
+	file.Environment = config.Environment
+	file.Options = config.Options
+	err = file.Write(out)
+	if err != nil {
+
+		return err
+	}
+
+	fmt.Fprintf(stdout, "wrote %v states to %v\n", len(file.States), out)
+	if report == "" {
+
+		return nil
+	}
+
+	// Evaluate the final policy as it'll be used, so that the report says how good it is.
+	evaluation := monoikos.EvaluatePolicy(environment, policy, config.Experiments)
+	r := &Report{Environment: config.Environment, Options: config.Options, Version: version, Training: trainingReport, Evaluation: evaluation}
+	b, err := json.MarshalIndent(r, "", "  ")
+	if err != nil {
+
+		return err
+	}
+
+	return ioutil.WriteFile(report, b, 0644)
+}
+
+// createTrainingConfig turns a config into the settings for training.
+func createTrainingConfig(config *Config) (*monoikos.TrainingConfig, error) {
+
+	training := monoikos.NewTrainingConfig()
+	training.InitialRandomizationRate = config.InitialRandomizationRate
+	training.ExperimentsPerIteration = config.Experiments
+	training.Iterations = config.Iterations
+	if config.Iterations < 1 {
+
+		return nil, &usageError{message: "there has to be at least one iteration"}
+	}
+
+	if config.Duration != "" {
+
+		duration, err := time.ParseDuration(config.Duration)
+		if err != nil {
+
+			return nil, &usageError{message: err.Error()}
+		}
+
+		training.Duration = duration
+	}
+
+	switch config.Failures {
+	case "abort":
+		training.FailureMode = monoikos.AbortOnFailure
+	case "skip":
+		training.FailureMode = monoikos.SkipOnFailure
+	default:
+		return nil, &usageError{message: "failures has to be abort or skip"}
+	}
+
+	return training, nil
+}
+
+// printLearningCurve prints a table of iterations with a bar for the average reward of each of them,
+// scaled between the lowest and the highest.
+func printLearningCurve(w io.Writer, iterations []*monoikos.IterationReport) {
+
+	if len(iterations) == 0 {
+
+		return
+	}
+
+	min := iterations[0].AverageReward
+	max := iterations[0].AverageReward
+	for _, iteration := range iterations {
+
+		if iteration.AverageReward < min {
+
+			min = iteration.AverageReward
+		}
+
+		if iteration.AverageReward > max {
+
+			max = iteration.AverageReward
+		}
+	}
+
+	fmt.Fprintf(w, "%9v  %4v  %11v  %8v  %14v\n", "iteration", "rate", "experiments", "failures", "average reward")
+	for _, iteration := range iterations {
+
+		width := 40
+		if max > min {
+
+			width = 1 + int((iteration.AverageReward-min)/(max-min)*39)
+		}
+
+		fmt.Fprintf(w, "%9v  %4v  %11v  %8v  %14.4f  %v\n", iteration.Iteration, iteration.RandomizationRate, iteration.Experiments, iteration.Failures, iteration.AverageReward, strings.Repeat("#", width))
+	}
+}
+
+// startRecording returns hooks that record every episode they're told about to a directory, if there
+// is one, with what the run is called, or the time if it isn't called anything, until the function
+// that's returned is called.
+func startRecording(directory string, run string, version string) ([]monoikos.EpisodeHook, func() error, error) {
+
+	if directory == "" {
+
+		return []monoikos.EpisodeHook{}, func() error { return nil }, nil
+	}
+
+	recorder, err := monoikos.NewTrajectoryRecorder(directory)
+	if err != nil {
+
+		return nil, nil, err
+	}
+
+	if run == "" {
+
+		run = time.Now().UTC().Format(time.RFC3339Nano)
+	}
+
+	recorder.Run = run
+	recorder.PolicyVersion = version
+	return []monoikos.EpisodeHook{recorder}, recorder.Close, nil
+}
+
+// stopRecording stops recording episodes, and returns the error that was passed in if there was one,
+// or the error from recording otherwise.
+func stopRecording(stop func() error, err error) error {
+
+	stopErr := stop()
+	if err != nil {
+
+		return err
+	}
+
+	return stopErr
+}
+
+// eval evaluates a policy file.
+func eval(args []string, stdout io.Writer, stderr io.Writer) error {
+
+	var path, trajectories, run string
+	config, _, err := parseFlags("eval", args, stderr, func(flags *flag.FlagSet, config *Config) {
+
+		flags.IntVar(&config.Experiments, "experiments", config.Experiments, "the number of experiments to run")
+		flags.StringVar(&path, "policy", "", "the policy file to evaluate")
+		flags.StringVar(&trajectories, "trajectories", "", "a directory to record every episode to, if any")
+		flags.StringVar(&run, "run", "", "what to call the run in every episode recorded, which defaults to the time")
+	})
+
+	if err != nil {
+
+		return err
+	}
+
+	environment, file, policy, err := loadPolicy(config, path)
+	if err != nil {
+
+		return err
+	}
+
+	hooks, stop, err := startRecording(trajectories, run, file.Version)
+	if err != nil {
+
+		return err
+	}
+
+	runner := monoikos.NewEpisodeRunner()
+	runner.Hooks = hooks
+	evaluation := monoikos.EvaluatePolicyWithRunner(environment, policy, config.Experiments, runner)
+	err = stopRecording(stop, nil)
+	if err != nil {
+
+		return err
+	}
+
+	fmt.Fprintf(stdout, "version %v: average reward %.4f ± %.4f over %v experiments (%v failed)\n", file.Version, evaluation.AverageReward, evaluation.StandardError, evaluation.Experiments, evaluation.Failures)
+	return nil
+}
+
+// show prints the preferred action in every state of a policy file, along with the value and visits of
+// each action that has them.  Gridworld policies are drawn on the map.
+func show(args []string, stdout io.Writer, stderr io.Writer) error {
+
+	var path string
+	config, _, err := parseFlags("show", args, stderr, func(flags *flag.FlagSet, config *Config) {
+
+		flags.StringVar(&path, "policy", "", "the policy file to show")
+	})
+
+	if err != nil {
+
+		return err
+	}
+
+	environment, file, policy, err := loadPolicy(config, path)
+	if err != nil {
+
+		return err
+	}
+
+	fmt.Fprintf(stdout, "version %v with %v states\n", file.Version, len(file.States))
+	if gridworld, ok := environment.(*monoikos.Gridworld); ok {
+
+		fmt.Fprint(stdout, gridworld.RenderPolicy(policy))
+		return nil
+	}
+
+	for _, s := range file.States {
+
+		line := s.Id + " => " + s.Preferred
+		actions := make([]string, 0)
+		for action := range s.Values {
+
+			actions = append(actions, action)
+		}
+		sort.Strings(actions)
+
+		for _, action := range actions {
+
+			line += fmt.Sprintf("  [%v %.3f n=%v]", action, s.Values[action], s.Visits[action])
+		}
+
+		fmt.Fprintln(stdout, line)
+	}
+
+	return nil
+}
+
+// compare evaluates policy files side by side, along with a random policy as a baseline.
+func compare(args []string, stdout io.Writer, stderr io.Writer) error {
+
+	config, flags, err := parseFlags("compare", args, stderr, func(flags *flag.FlagSet, config *Config) {
+
+		flags.IntVar(&config.Experiments, "experiments", config.Experiments, "the number of experiments to run for each policy")
+	})
+
+	if err != nil {
+
+		return err
+	}
+
+	paths := flags.Args()
+	if len(paths) == 0 {
+
+		return &usageError{message: "compare needs at least one policy file"}
+	}
+
+	fmt.Fprintf(stdout, "%-30v  %14v  %14v\n", "policy", "average reward", "standard error")
+	var environment monoikos.Environment
+	for _, path := range paths {
+
+		e, _, policy, err := loadPolicy(config, path)
+		if err != nil {
+
+			return err
+		}
+
+		environment = e
+		evaluation := monoikos.EvaluatePolicy(environment, policy, config.Experiments)
+		fmt.Fprintf(stdout, "%-30v  %14.4f  %14.4f\n", path, evaluation.AverageReward, evaluation.StandardError)
+	}
+
+	// A random policy that keeps randomizing shows how much better the policies are than chance.
+	random := environment.CreateRandomPolicy()
+	random.SetRandomizationRate(100)
+	evaluation := monoikos.EvaluatePolicy(environment, random, config.Experiments)
+	fmt.Fprintf(stdout, "%-30v  %14.4f  %14.4f\n", "random", evaluation.AverageReward, evaluation.StandardError)
+	return nil
+}
+
+// serve serves a policy file over HTTP until the server fails.
+func serve(args []string, stdout io.Writer, stderr io.Writer) error {
+
+	var path, address string
+	_, _, err := parseFlags("serve", args, stderr, func(flags *flag.FlagSet, config *Config) {
+
+		flags.StringVar(&path, "policy", "", "the policy file to serve")
+		flags.StringVar(&address, "address", ":8080", "the address to listen on")
+	})
+
+	if err != nil {
+
+		return err
+	}
+
+	if path == "" {
+
+		return &usageError{message: "a policy file has to be given"}
+	}
+
+	s, err := server.LoadServer(path)
+	if err != nil {
+
+		return err
+	}
+
+	fmt.Fprintf(stdout, "serving version %v with %v states on %v\n", s.File.Version, len(s.File.States), address)
+	return http.ListenAndServe(address, s)
+}
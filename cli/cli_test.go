@@ -0,0 +1,190 @@
+package cli_test
+
+import (
+	"bytes"
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/tysont/monoikos"
+	"github.com/tysont/monoikos/cli"
+)
+
+var tabularJSON = `{
+	"states": ["start", "middle", "done"],
+	"actions": ["stop", "walk", "gamble"],
+	"start": {"start": 1.0},
+	"terminal": ["done"],
+	"transitions": [
+		{"state": "start", "action": "stop", "next": "done", "probability": 1.0, "reward": 1},
+		{"state": "start", "action": "walk", "next": "middle", "probability": 1.0, "reward": 0},
+		{"state": "middle", "action": "stop", "next": "done", "probability": 1.0, "reward": 3},
+		{"state": "middle", "action": "gamble", "next": "done", "probability": 0.5, "reward": 10},
+		{"state": "middle", "action": "gamble", "next": "middle", "probability": 0.5, "reward": -2}
+	]
+}`
+
+// run runs the command and returns the exit code along with what was written.
+func run(args ...string) (int, string, string) {
+
+	var stdout, stderr bytes.Buffer
+	code := cli.Run(args, &stdout, &stderr)
+	return code, stdout.String(), stderr.String()
+}
+
+func TestTrainEvalShowCompare(t *testing.T) {
+
+	dir, err := ioutil.TempDir("", "monoikos")
+	if err != nil {
+
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	mdp := filepath.Join(dir, "mdp.json")
+	err = ioutil.WriteFile(mdp, []byte(tabularJSON), 0644)
+	if err != nil {
+
+		t.Fatal(err)
+	}
+
+	policy := filepath.Join(dir, "policy.json")
+	report := filepath.Join(dir, "report.json")
+	code, stdout, stderr := run("train", "-env", "tabular", "-option", "file="+mdp, "-experiments", "500", "-iterations", "4", "-out", policy, "-report", report, "-version", "v1")
+	if code != 0 {
+		t.Fatalf("Expected training to succeed, got '%v': %v", code, stderr)
+	}
+
+	if !strings.Contains(stdout, "iteration") || !strings.Contains(stdout, "#") || strings.Count(stdout, "\n") < 5 {
+		t.Errorf("Expected a learning curve, got:\n%v", stdout)
+	}
+
+	// The policy file records where it came from, and walks and then gambles.
+	file, err := monoikos.ReadPolicyFile(policy)
+	if err != nil {
+
+		t.Fatal(err)
+	}
+
+	if file.Version != "v1" || file.Environment != "tabular" || file.Options["file"] != mdp {
+		t.Errorf("Expected the policy file to record its version and environment, got '%v' '%v' '%v'.", file.Version, file.Environment, file.Options)
+	}
+
+	b, err := ioutil.ReadFile(report)
+	if err != nil {
+
+		t.Fatal(err)
+	}
+
+	r := new(cli.Report)
+	err = json.Unmarshal(b, r)
+	if err != nil || len(r.Training.Iterations) != 4 || r.Evaluation == nil || r.Evaluation.AverageReward < 3 {
+		t.Errorf("Expected a report with every iteration and an evaluation, got:\n%v", string(b))
+	}
+
+	// Everything else finds the environment from the policy file.
+	code, stdout, stderr = run("eval", "-policy", policy, "-experiments", "200")
+	if code != 0 || !strings.Contains(stdout, "v1") || !strings.Contains(stdout, "±") {
+		t.Errorf("Expected an evaluation, got '%v': %v%v", code, stdout, stderr)
+	}
+
+	// Evaluating can record every episode, along with what the run is called.
+	trajectories := filepath.Join(dir, "trajectories")
+	code, _, stderr = run("eval", "-policy", policy, "-experiments", "20", "-run", "nightly", "-trajectories", trajectories)
+	records, err := monoikos.ReadTrajectories(filepath.Join(trajectories, "trajectories-000001.jsonl"))
+	if code != 0 || err != nil || len(records) != 20 || records[0].Run != "nightly" || records[0].PolicyVersion != "v1" {
+		t.Errorf("Expected every episode to be recorded, got '%v' '%v': %v", code, err, stderr)
+	}
+
+	code, stdout, stderr = run("show", "-policy", policy)
+	if code != 0 || !strings.Contains(stdout, "=> walk") {
+		t.Errorf("Expected the policy to be shown, got '%v': %v%v", code, stdout, stderr)
+	}
+
+	code, stdout, stderr = run("compare", "-experiments", "200", policy, policy)
+	if code != 0 || strings.Count(stdout, policy) != 2 || !strings.Contains(stdout, "random") {
+		t.Errorf("Expected a comparison with a random baseline, got '%v': %v%v", code, stdout, stderr)
+	}
+}
+
+func TestShowGridworld(t *testing.T) {
+
+	dir, err := ioutil.TempDir("", "monoikos")
+	if err != nil {
+
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	policy := filepath.Join(dir, "policy.json")
+	code, _, stderr := run("train", "-env", "gridworld", "-experiments", "200", "-out", policy)
+	if code != 0 {
+		t.Fatalf("Expected training to succeed, got '%v': %v", code, stderr)
+	}
+
+	code, stdout, stderr := run("show", "-policy", policy)
+	if code != 0 || !strings.Contains(stdout, "G") || !strings.ContainsAny(stdout, "^v<>") {
+		t.Errorf("Expected the gridworld policy to be drawn, got '%v': %v%v", code, stdout, stderr)
+	}
+}
+
+func TestConfig(t *testing.T) {
+
+	dir, err := ioutil.TempDir("", "monoikos")
+	if err != nil {
+
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	config := filepath.Join(dir, "config.yaml")
+	err = ioutil.WriteFile(config, []byte("environment: bandit\noptions:\n  probabilities: \"0.1,0.9\"\nexperiments: 100\niterations: 3\n"), 0644)
+	if err != nil {
+
+		t.Fatal(err)
+	}
+
+	loaded, err := cli.LoadConfig(config)
+	if err != nil || loaded.Environment != "bandit" || loaded.Iterations != 3 || loaded.InitialRandomizationRate != 40 {
+		t.Errorf("Expected the config to be loaded over the defaults, got '%v' '%v'.", loaded, err)
+	}
+
+	// Flags override the config file wherever they are.
+	policy := filepath.Join(dir, "policy.json")
+	report := filepath.Join(dir, "report.json")
+	code, stdout, stderr := run("train", "-iterations", "2", "-config", config, "-out", policy, "-report", report)
+	if code != 0 || strings.Count(stdout, "\n") != 4 {
+		t.Errorf("Expected two iterations of training, got '%v': %v%v", code, stdout, stderr)
+	}
+
+	file, err := monoikos.ReadPolicyFile(policy)
+	if err != nil || file.Environment != "bandit" || file.Options["probabilities"] != "0.1,0.9" {
+		t.Errorf("Expected the environment to come from the config, got '%v'.", err)
+	}
+}
+
+func TestUsage(t *testing.T) {
+
+	if code, _, _ := run(); code != 2 {
+		t.Errorf("Expected no command to be a usage error, got '%v'.", code)
+	}
+
+	if code, _, _ := run("fly"); code != 2 {
+		t.Errorf("Expected an unknown command to be a usage error, got '%v'.", code)
+	}
+
+	if code, _, _ := run("train", "-failures", "sometimes", "-env", "bandit"); code != 2 {
+		t.Errorf("Expected a bad flag value to be a usage error, got '%v'.", code)
+	}
+
+	if code, _, stderr := run("train", "-env", "missing"); code != 1 || !strings.Contains(stderr, "missing") {
+		t.Errorf("Expected an unknown environment to fail, got '%v'.", code)
+	}
+
+	if code, _, _ := run("eval"); code != 2 {
+		t.Errorf("Expected eval without a policy to be a usage error, got '%v'.", code)
+	}
+}
@@ -0,0 +1,13 @@
+// Command monoikos trains, evaluates, shows and compares policies for the registered environments.
+package main
+
+import (
+	"os"
+
+	"github.com/tysont/monoikos/cli"
+)
+
+func main() {
+
+	os.Exit(cli.Run(os.Args[1:], os.Stdout, os.Stderr))
+}
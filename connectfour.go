@@ -0,0 +1,167 @@
+package monoikos
+
+import (
+	"strconv"
+	"strings"
+)
+
+// ConnectFour is the game of dropping counters into the columns of an upright board for two players,
+// where the first player to get enough in a row across, down or diagonally wins.  The board can be
+// smaller than the usual six rows and seven columns, and the number in a row to win can be changed,
+// so that it can be solved exactly by a MinimaxSolver.  The board is a string of cells in rows from
+// the bottom left.  A win pays out one to the winner and minus one to the loser, and a draw pays out
+// nothing.
+type ConnectFour struct {
+	Rows    int
+	Columns int
+	Connect int
+}
+
+// NewConnectFour should be used to create a ConnectFour; a board of four rows and five columns with
+// four in a row to win is small enough to solve in seconds.
+func NewConnectFour(rows int, columns int, connect int) *ConnectFour {
+
+	game := new(ConnectFour)
+	game.Rows = rows
+	game.Columns = columns
+	game.Connect = connect
+	return game
+}
+
+// GetPlayers returns two.
+func (this *ConnectFour) GetPlayers() int {
+
+	return 2
+}
+
+// CreateMatch creates a match on an empty board.
+func (this *ConnectFour) CreateMatch() Match {
+
+	return NewDeterministicMatch(this)
+}
+
+// GetLegalActions returns a move for every column that isn't full, starting from the middle since
+// those moves tend to be best, which makes searching faster.
+func (this *ConnectFour) GetLegalActions(state State) []Action {
+
+	board := state.GetContext()[BoardContextKey]
+	actions := make([]Action, 0)
+	for i := 0; i < this.Columns; i++ {
+
+		// Alternate either side of the middle column.
+		column := this.Columns/2 + (i+1)/2*(1-2*(i%2))
+		if board[(this.Rows-1)*this.Columns+column] == BoardEmpty {
+
+			actions = append(actions, &ConnectFourAction{Column: column})
+		}
+	}
+
+	return actions
+}
+
+// GetInitialState returns an empty board with the first player to play.
+func (this *ConnectFour) GetInitialState() State {
+
+	return createBoardState(strings.Repeat(string(BoardEmpty), this.Rows*this.Columns), 0, false)
+}
+
+// GetNextState returns the board after the player whose turn it is drops a counter into a column.
+func (this *ConnectFour) GetNextState(state State, action Action) State {
+
+	board := []byte(state.GetContext()[BoardContextKey])
+	player := this.GetCurrentPlayer(state)
+	column := action.(*ConnectFourAction).Column
+	for row := 0; row < this.Rows; row++ {
+
+		if board[row*this.Columns+column] == BoardEmpty {
+
+			board[row*this.Columns+column] = boardMarks[player]
+			break
+		}
+	}
+
+	terminal := this.getWinner(string(board)) != BoardEmpty || !strings.ContainsRune(string(board), BoardEmpty)
+	return createBoardState(string(board), 1-player, terminal)
+}
+
+// GetCurrentPlayer returns the player whose turn it is.
+func (this *ConnectFour) GetCurrentPlayer(state State) int {
+
+	player, _ := strconv.Atoi(state.GetContext()[TurnContextKey])
+	return player
+}
+
+// GetRewards returns one for the winner and minus one for the loser, or nothing for a draw.
+func (this *ConnectFour) GetRewards(state State) []int {
+
+	return getBoardRewards(this.getWinner(state.GetContext()[BoardContextKey]))
+}
+
+// getWinner returns the mark of the player with enough in a row, if there is one.
+func (this *ConnectFour) getWinner(board string) byte {
+
+	directions := [][2]int{{0, 1}, {1, 0}, {1, 1}, {1, -1}}
+	for row := 0; row < this.Rows; row++ {
+		for column := 0; column < this.Columns; column++ {
+
+			mark := board[row*this.Columns+column]
+			if mark == BoardEmpty {
+
+				continue
+			}
+
+			for _, direction := range directions {
+
+				n := 1
+				r, c := row+direction[0], column+direction[1]
+				for n < this.Connect && r >= 0 && r < this.Rows && c >= 0 && c < this.Columns && board[r*this.Columns+c] == mark {
+
+					n++
+					r, c = r+direction[0], c+direction[1]
+				}
+
+				if n == this.Connect {
+
+					return mark
+				}
+			}
+		}
+	}
+
+	return BoardEmpty
+}
+
+// Render draws the board with the top row first.
+func (this *ConnectFour) Render(state State) string {
+
+	board := state.GetContext()[BoardContextKey]
+	s := ""
+	for row := this.Rows - 1; row >= 0; row-- {
+
+		s += board[row*this.Columns:(row+1)*this.Columns] + "\n"
+	}
+
+	return s
+}
+
+// ConnectFourAction drops a counter into a column, numbered from zero on the left.
+type ConnectFourAction struct {
+	Column int
+}
+
+// GetId returns an identifier for the column.
+func (this *ConnectFourAction) GetId() string {
+
+	return "Column " + strconv.Itoa(this.Column)
+}
+
+// Run does nothing, since moves are made by the match.
+func (this *ConnectFourAction) Run(context map[string]interface{}) {
+}
+
+// GetSymmetries returns the reflection of the board from left to right, which is the only symmetry
+// that gravity allows.
+func (this *ConnectFour) GetSymmetries() []Symmetry {
+
+	return []Symmetry{&boardSymmetry{dihedral{rows: this.Rows, columns: this.Columns, flipColumns: true}}}
+}
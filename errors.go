@@ -0,0 +1,161 @@
+package monoikos
+
+import (
+	"fmt"
+)
+
+// IllegalActionError is returned when an action is taken in a state where it isn't one of the legal
+// actions.
+type IllegalActionError struct {
+	State  State
+	Action Action
+}
+
+// Error describes the action and the state it was taken in.
+func (this *IllegalActionError) Error() string {
+
+	return "monoikos: action '" + this.Action.GetId() + "' isn't legal in state " + this.State.GetId()
+}
+
+// NoLegalActionsError is returned when an action is needed for a state that has no legal actions.
+type NoLegalActionsError struct {
+	State State
+}
+
+// Error describes the state without any legal actions.
+func (this *NoLegalActionsError) Error() string {
+
+	return "monoikos: no legal actions in state " + this.State.GetId()
+}
+
+// ActionError is returned when running an action fails, including when it panics, such as when the
+// context it's run against doesn't hold what it expects.
+type ActionError struct {
+	Action Action
+	Err    error
+}
+
+// Error describes the action that failed and why.
+func (this *ActionError) Error() string {
+
+	return "monoikos: action '" + this.Action.GetId() + "' failed: " + this.Err.Error()
+}
+
+// Unwrap returns the reason that the action failed.
+func (this *ActionError) Unwrap() error {
+
+	return this.Err
+}
+
+// FallibleAction is an action that can report that it failed rather than panicking.
+type FallibleAction interface {
+	Action
+	TryRun(map[string]interface{}) error
+}
+
+// FallibleStepExperiment is a step experiment that can report that applying an action failed.
+type FallibleStepExperiment interface {
+	StepExperiment
+	TryApply(Action) error
+}
+
+// FallibleExperiment is an experiment that can report that it failed rather than panicking.
+type FallibleExperiment interface {
+	Experiment
+	TryRun(Policy) ([]Outcome, error)
+	TryForceRun(Action, Policy) ([]Outcome, error)
+}
+
+// FalliblePolicy is a policy that can report that it couldn't pick an action rather than panicking.
+type FalliblePolicy interface {
+	Policy
+	TryGetAction(State) (Action, error)
+}
+
+// RunAction runs an action against a context and returns an ActionError if it fails.  Actions that
+// can report failure are asked to, and a panic from any other action is turned into an error.
+func RunAction(action Action, context map[string]interface{}) (err error) {
+
+	if fallible, ok := action.(FallibleAction); ok {
+
+		err = fallible.TryRun(context)
+		if err != nil {
+
+			return &ActionError{Action: action, Err: err}
+		}
+
+		return nil
+	}
+
+	defer func() {
+
+		if r := recover(); r != nil {
+
+			err = &ActionError{Action: action, Err: recoveredError(r)}
+		}
+	}()
+
+	action.Run(context)
+	return nil
+}
+
+// TryRunExperiment follows the policy until a terminal state is reached, or the experiment is
+// truncated, and returns an error instead of panicking if the experiment fails.
+func TryRunExperiment(experiment Experiment, policy Policy) ([]Outcome, error) {
+
+	if fallible, ok := experiment.(FallibleExperiment); ok {
+
+		return fallible.TryRun(policy)
+	}
+
+	return tryRun(func() []Outcome { return experiment.Run(policy) })
+}
+
+// TryForceRunExperiment takes an action and then follows the policy until a terminal state is
+// reached, or the experiment is truncated, and returns an error instead of panicking if the
+// experiment fails.
+func TryForceRunExperiment(experiment Experiment, action Action, policy Policy) ([]Outcome, error) {
+
+	if fallible, ok := experiment.(FallibleExperiment); ok {
+
+		return fallible.TryForceRun(action, policy)
+	}
+
+	return tryRun(func() []Outcome { return experiment.ForceRun(action, policy) })
+}
+
+// tryRun runs an experiment and turns a panic into an error.
+func tryRun(run func() []Outcome) (outcomes []Outcome, err error) {
+
+	defer func() {
+
+		if r := recover(); r != nil {
+
+			outcomes = nil
+			err = recoveredError(r)
+		}
+	}()
+
+	return run(), nil
+}
+
+// recoveredError returns the error that caused a panic, or an error describing it if it wasn't one.
+func recoveredError(r interface{}) error {
+
+	if err, ok := r.(error); ok {
+
+		return err
+	}
+
+	return fmt.Errorf("monoikos: %v", r)
+}
+
+// FailureMode decides what training does when an experiment fails.
+type FailureMode int
+
+// Training either stops at the first failed experiment and returns the error, or leaves the failed
+// experiment out and carries on.
+const (
+	AbortOnFailure FailureMode = iota
+	SkipOnFailure
+)
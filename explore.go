@@ -0,0 +1,146 @@
+package monoikos
+
+// Exploration decides how actions that haven't been tried are treated when a policy is created from
+// values.  Optimistic values assume that every untried action is worth the optimistic value, so an
+// untried action is preferred over anything that's been tried and found to be worth less, and untried
+// actions are amongst the other actions that get picked when randomizing.  Exploring unexplored
+// actions first makes the policy take every untried action once before anything else whenever it's
+// randomizing.  By default neither is done, and untried actions are left out.
+type Exploration struct {
+	Optimistic      bool
+	OptimisticValue float64
+	UnexploredFirst bool
+}
+
+// ExploringEnvironment is an environment that improves policies in a way of its own, rather than from
+// the average rewards, and can still treat untried actions as an exploration says when it does.
+type ExploringEnvironment interface {
+	Environment
+	CreateExploringPolicy([]Outcome, Exploration) Policy
+}
+
+// CreateExploringImprovedPolicy creates an improved policy from a set of outcomes, treating untried
+// actions as the exploration says.  Without any exploration it's the environment's own improved
+// policy; otherwise environments that can explore are asked to, and the policy is created from the
+// average rewards for the rest.
+func CreateExploringImprovedPolicy(environment Environment, outcomes []Outcome, exploration Exploration) Policy {
+
+	if exploration == (Exploration{}) {
+
+		return environment.CreateImprovedPolicy(outcomes)
+	}
+
+	if exploring, ok := environment.(ExploringEnvironment); ok {
+
+		return exploring.CreateExploringPolicy(outcomes, exploration)
+	}
+
+	return CreateExploringPolicyFromValues(environment, GetAverageRewards(outcomes), exploration)
+}
+
+// GetUntriedActions returns the legal actions in a state that don't have a value.
+func GetUntriedActions(environment Environment, state State, rewards map[string]float64) []Action {
+
+	untried := make([]Action, 0)
+	actions := environment.GetLegalActions(state)
+	for i, id := range getActionIds(state, actions) {
+
+		if _, ok := rewards[id]; !ok {
+
+			untried = append(untried, actions[i])
+		}
+	}
+
+	return untried
+}
+
+// GetOptimisticAction returns the preferred action for a state in the same way as GetOptimalAction,
+// except that untried actions are assumed to be worth the optimistic value rather than being left out.
+// It returns nil if none of the actions have been tried, since they'd all be worth the same.
+func GetOptimisticAction(environment Environment, state State, rewards map[string]float64, optimisticValue float64) (Action, []Action) {
+
+	tried := false
+	max := 0.0
+
+	var preferredAction Action
+	var otherActions []Action
+
+	// Iterate over actions to find the one with the highest reward, trying each action in turn.
+	actions := environment.GetLegalActions(state)
+	for i, id := range getActionIds(state, actions) {
+
+		reward, ok := rewards[id]
+		if !ok {
+
+			reward = optimisticValue
+		}
+
+		tried = tried || ok
+		if preferredAction == nil || reward > max {
+
+			if preferredAction != nil {
+
+				otherActions = append(otherActions, preferredAction)
+			}
+
+			max = reward
+			preferredAction = actions[i]
+
+		} else {
+
+			otherActions = append(otherActions, actions[i])
+		}
+	}
+
+	if !tried {
+
+		return nil, nil
+	}
+
+	return preferredAction, otherActions
+}
+
+// CreateExploringPolicyFromValues creates a policy that prefers the action with the highest value in
+// each known state in the same way as CreatePolicyFromValues, treating untried actions as the
+// exploration says.  Known states without any values are given a random preferred action, and known
+// states without any legal actions are left out.  The untried actions in each state are kept track of
+// in the policy.
+func CreateExploringPolicyFromValues(environment Environment, rewards map[string]float64, exploration Exploration) Policy {
+
+	policy := NewBasicPolicy()
+	policy.Environment = environment
+	policy.UnexploredFirst = exploration.UnexploredFirst
+
+	// For each state, add it to the policy with a preferred or randomized action.  Terminal states
+	// are skipped since no action is ever taken in them.
+	for _, state := range environment.GetKnownStates() {
+
+		if state.IsTerminal() {
+
+			continue
+		}
+
+		var preferredAction Action
+		var otherActions []Action
+		if exploration.Optimistic {
+
+			preferredAction, otherActions = GetOptimisticAction(environment, state, rewards, exploration.OptimisticValue)
+
+		} else {
+
+			preferredAction, otherActions = GetOptimalAction(environment, state, rewards)
+		}
+
+		if preferredAction == nil {
+
+			policy.TryAddRandomState(state)
+
+		} else {
+
+			policy.AddState(state, preferredAction, otherActions)
+			policy.UntriedActions[state.GetId()] = GetUntriedActions(environment, state, rewards)
+		}
+	}
+
+	return policy
+}
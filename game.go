@@ -0,0 +1,574 @@
+package monoikos
+
+import (
+	"math"
+	"math/rand"
+	"sync"
+)
+
+// Game is a domain where several players take turns, each with their own policy and their own reward.
+// It creates matches, and knows which actions are legal in a state, where states are observed from
+// the point of view of the player whose turn it is.
+type Game interface {
+	GetPlayers() int
+	CreateMatch() Match
+	GetLegalActions(State) []Action
+}
+
+// Match is a single game being played out.  It provides the state from the point of view of the
+// player whose turn it is, which is terminal once the match is over, along with the reward that's
+// been paid out to every player so far.  Play makes a move for the player whose turn it is.
+type Match interface {
+	ObserveState() State
+	GetCurrentPlayer() int
+	GetRewards() []int
+	Play(Action)
+}
+
+// PlayerState is a state seen by one of the players in a match, which reports the reward that's been
+// paid out to that player rather than to anyone else.
+type PlayerState struct {
+	State
+	Reward int
+}
+
+// GetReward returns the reward that's been paid out to the player so far.
+func (this *PlayerState) GetReward() int {
+
+	return this.Reward
+}
+
+// PlayMatch plays a match out with a policy for every player, and returns the outcomes of the moves
+// that each player made along with the rewards each of them was paid.  The next state of an outcome
+// is the state when it was next the same player's turn, so other players' moves are treated as part
+// of the environment.
+func PlayMatch(match Match, policies []Policy) ([][]Outcome, []int) {
+
+	return playMatch(match, policies, -1, nil)
+}
+
+// playMatch plays a match out, forcing the first move of a player if an action is given.
+func playMatch(match Match, policies []Policy, player int, first Action) ([][]Outcome, []int) {
+
+	basicOutcomes := make([][]*BasicOutcome, len(policies))
+	pending := make([]*BasicOutcome, len(policies))
+
+	state := match.ObserveState()
+	for !state.IsTerminal() {
+
+		p := match.GetCurrentPlayer()
+		seen := &PlayerState{State: state, Reward: match.GetRewards()[p]}
+		if pending[p] != nil {
+
+			pending[p].NextState = seen
+		}
+
+		// Take the forced action on the player's first move, and follow the policies after that.
+		action := first
+		if p != player || action == nil {
+
+			action = policies[p].GetAction(seen)
+
+		} else {
+
+			first = nil
+		}
+
+		match.Play(action)
+
+		outcome := new(BasicOutcome)
+		outcome.InitialState = seen
+		outcome.ActionTaken = action
+		basicOutcomes[p] = append(basicOutcomes[p], outcome)
+		pending[p] = outcome
+
+		state = match.ObserveState()
+	}
+
+	// Every player sees the final state with their own reward.
+	rewards := match.GetRewards()
+	outcomes := make([][]Outcome, len(policies))
+	for p := range policies {
+
+		final := &PlayerState{State: state, Reward: rewards[p]}
+		outcomes[p] = make([]Outcome, 0)
+		for _, outcome := range basicOutcomes[p] {
+
+			if outcome.NextState == nil {
+
+				outcome.NextState = final
+			}
+
+			outcome.FinalState = final
+			outcomes[p] = append(outcomes[p], outcome)
+		}
+	}
+
+	return outcomes, rewards
+}
+
+// MatchReport is the summary of a set of matches; the number each player won outright, the number
+// that were drawn, and the average reward paid out to each player.
+type MatchReport struct {
+	Matches int
+	Wins    []int
+	Draws   int
+	Rewards []float64
+}
+
+// EvaluateMatches plays a number of matches with a policy for every player, and reports how each
+// player did.  A player wins a match when they're paid more than every other player, and a match is
+// drawn otherwise.
+func EvaluateMatches(game Game, policies []Policy, matches int) *MatchReport {
+
+	report := new(MatchReport)
+	report.Matches = matches
+	report.Wins = make([]int, len(policies))
+	report.Rewards = make([]float64, len(policies))
+
+	for i := 0; i < matches; i++ {
+
+		_, rewards := PlayMatch(game.CreateMatch(), policies)
+		winner := 0
+		for p, reward := range rewards {
+
+			report.Rewards[p] += float64(reward)
+			if reward > rewards[winner] {
+
+				winner = p
+			}
+		}
+
+		// It's only a win if nobody else was paid as much.
+		won := true
+		for p, reward := range rewards {
+
+			if p != winner && reward == rewards[winner] {
+
+				won = false
+			}
+		}
+
+		if won {
+
+			report.Wins[winner]++
+
+		} else {
+
+			report.Draws++
+		}
+	}
+
+	for p := range report.Rewards {
+
+		report.Rewards[p] /= float64(matches)
+	}
+
+	return report
+}
+
+// GameSeat is an Environment for one of the players in a game, where every other player follows
+// their own policy.  It lets the single agent machinery be used for games, whether that's optimizing
+// a policy against fixed opponents or improving every seat against each other in self play.  Since
+// the states of a game usually can't be listed up front, the known states are the states that have
+// been visited so far from this seat.
+type GameSeat struct {
+	Game        Game
+	Player      int
+	Policies    []Policy
+	KnownStates map[string]State
+	mutex       sync.Mutex
+}
+
+// NewGameSeat should be used to create a GameSeat; the policy for the seat itself is ignored, since
+// it's passed in when an experiment is run.
+func NewGameSeat(game Game, player int, policies []Policy) *GameSeat {
+
+	seat := new(GameSeat)
+	seat.Game = game
+	seat.Player = player
+	seat.Policies = policies
+	seat.KnownStates = make(map[string]State)
+	return seat
+}
+
+// CreateRandomPolicy creates a random policy for the seat.
+func (this *GameSeat) CreateRandomPolicy() Policy {
+
+	return CreateRandomPolicy(this)
+}
+
+// CreateImprovedPolicy creates an improved policy for the seat from a set of outcomes.
+func (this *GameSeat) CreateImprovedPolicy(outcomes []Outcome) Policy {
+
+	return CreateImprovedPolicy(this, outcomes)
+}
+
+// CreateOptimizedPolicy creates an optimized policy for the seat against the other players' policies.
+func (this *GameSeat) CreateOptimizedPolicy(initialRandomizationRate int, experimentsPerIteration int, iterations int) Policy {
+
+	return CreateOptimizedPolicy(this, initialRandomizationRate, experimentsPerIteration, iterations)
+}
+
+// CreateExperiment creates a new match to be played from the seat.
+func (this *GameSeat) CreateExperiment() Experiment {
+
+	experiment := new(GameExperiment)
+	experiment.Seat = this
+	experiment.Match = this.Game.CreateMatch()
+	return experiment
+}
+
+// GetLegalActions returns the legal actions in the game.
+func (this *GameSeat) GetLegalActions(state State) []Action {
+
+	return this.Game.GetLegalActions(state)
+}
+
+// GetKnownStates returns the states that have been visited from the seat so far.
+func (this *GameSeat) GetKnownStates() []State {
+
+	this.mutex.Lock()
+	defer this.mutex.Unlock()
+
+	states := make([]State, 0)
+	for _, state := range this.KnownStates {
+
+		states = append(states, state)
+	}
+
+	return states
+}
+
+// addKnownStates records the states that were visited in a set of outcomes.
+func (this *GameSeat) addKnownStates(outcomes []Outcome) {
+
+	this.mutex.Lock()
+	defer this.mutex.Unlock()
+
+	for _, outcome := range outcomes {
+
+		state := outcome.GetInitialState()
+		if _, ok := this.KnownStates[state.GetId()]; !ok {
+
+			this.KnownStates[state.GetId()] = state
+		}
+	}
+}
+
+// GameExperiment is a single match played from one of the seats.
+type GameExperiment struct {
+	Seat  *GameSeat
+	Match Match
+}
+
+// ObserveState returns the state of the match, from the point of view of whoever's turn it is.
+func (this *GameExperiment) ObserveState() State {
+
+	return this.Match.ObserveState()
+}
+
+// Run plays the match out, following the policy for the seat and the other players' policies for
+// everyone else, and returns the outcomes of the seat's moves.
+func (this *GameExperiment) Run(policy Policy) []Outcome {
+
+	return this.ForceRun(nil, policy)
+}
+
+// ForceRun plays the match out in the same way as Run, except that the seat's first move is forced.
+func (this *GameExperiment) ForceRun(action Action, policy Policy) []Outcome {
+
+	policies := make([]Policy, len(this.Seat.Policies))
+	copy(policies, this.Seat.Policies)
+	policies[this.Seat.Player] = policy
+
+	outcomes, _ := playMatch(this.Match, policies, this.Seat.Player, action)
+	this.Seat.addKnownStates(outcomes[this.Seat.Player])
+	return outcomes[this.Seat.Player]
+}
+
+// SelfPlay trains a policy for every seat in a game by having them play each other, and improving
+// every seat from the outcomes of its own moves after each iteration.
+type SelfPlay struct {
+	Game     Game
+	Seats    []*GameSeat
+	Policies []Policy
+}
+
+// NewSelfPlay should be used to create a SelfPlay; every seat starts out with a random policy.
+func NewSelfPlay(game Game) *SelfPlay {
+
+	selfPlay := new(SelfPlay)
+	selfPlay.Game = game
+	selfPlay.Seats = make([]*GameSeat, game.GetPlayers())
+	selfPlay.Policies = make([]Policy, game.GetPlayers())
+	for p := range selfPlay.Seats {
+
+		selfPlay.Seats[p] = NewGameSeat(game, p, selfPlay.Policies)
+		selfPlay.Policies[p] = selfPlay.Seats[p].CreateRandomPolicy()
+	}
+
+	return selfPlay
+}
+
+// Train runs iterations of matches between the seats with a randomization rate that decreases with
+// each iteration, in the same way as CreateOptimizedPolicy, and returns the improved policies.
+func (this *SelfPlay) Train(initialRandomizationRate int, matchesPerIteration int, iterations int) []Policy {
+
+	// The -1 is because we always want an extra iteration at 0 randomization, which is the only
+	// iteration if there's just one.
+	for i := (iterations - 1); i >= 0; i-- {
+
+		randomizationRate := 0
+		if iterations > 1 {
+
+			randomizationRate = int(float64(initialRandomizationRate) * (float64(i) / float64(iterations-1)))
+		}
+
+		for _, policy := range this.Policies {
+
+			policy.SetRandomizationRate(randomizationRate)
+		}
+
+		// Play matches and keep track of the outcomes for every seat.
+		outcomes := make([][]Outcome, len(this.Seats))
+		for j := 0; j < matchesPerIteration; j++ {
+
+			matchOutcomes, _ := PlayMatch(this.Game.CreateMatch(), this.Policies)
+			for p, o := range matchOutcomes {
+
+				outcomes[p] = append(outcomes[p], o...)
+			}
+		}
+
+		// Improve every seat against the policies the others were playing.  States that weren't
+		// visited in this iteration keep the action they had, rather than being given a random one,
+		// since the seats soon stop visiting states that good opponents steer away from.
+		for p, seat := range this.Seats {
+
+			seat.addKnownStates(outcomes[p])
+			rewards := GetAverageRewards(outcomes[p])
+			policy := CreatePolicyFromValues(seat, rewards)
+			for _, state := range seat.GetKnownStates() {
+
+				preferredAction := this.Policies[p].GetPreferredAction(state)
+				if action, _ := GetOptimalAction(seat, state, rewards); action == nil && preferredAction != nil {
+
+					policy.AddState(state, preferredAction, getOtherActions(seat, state, preferredAction))
+				}
+			}
+
+			this.Policies[p] = policy
+		}
+	}
+
+	for _, policy := range this.Policies {
+
+		policy.SetRandomizationRate(0)
+	}
+
+	return this.Policies
+}
+
+// getOtherActions returns the legal actions in a state other than the given action.
+func getOtherActions(environment Environment, state State, action Action) []Action {
+
+	actions := make([]Action, 0)
+	for _, other := range environment.GetLegalActions(state) {
+
+		if other.GetId() != action.GetId() {
+
+			actions = append(actions, other)
+		}
+	}
+
+	return actions
+}
+
+// UniformRandomPolicy is a fixed policy that picks a legal action uniformly at random every time,
+// which makes a useful opponent to evaluate trained policies against.
+type UniformRandomPolicy struct {
+	Game Game
+}
+
+// NewUniformRandomPolicy should be used to create a UniformRandomPolicy.
+func NewUniformRandomPolicy(game Game) *UniformRandomPolicy {
+
+	policy := new(UniformRandomPolicy)
+	policy.Game = game
+	return policy
+}
+
+// GetAction returns a random legal action.
+func (this *UniformRandomPolicy) GetAction(state State) Action {
+
+	actions := this.Game.GetLegalActions(state)
+	return actions[rand.Intn(len(actions))]
+}
+
+// GetPreferredAction returns a random legal action, since no action is preferred.
+func (this *UniformRandomPolicy) GetPreferredAction(state State) Action {
+
+	return this.GetAction(state)
+}
+
+// AddRandomState does nothing, since the policy doesn't keep track of states.
+func (this *UniformRandomPolicy) AddRandomState(state State) {
+}
+
+// AddState does nothing, since the policy doesn't keep track of states.
+func (this *UniformRandomPolicy) AddState(state State, preferredAction Action, otherActions []Action) {
+}
+
+// SetRandomizationRate does nothing, since the policy is always random.
+func (this *UniformRandomPolicy) SetRandomizationRate(randomizationRate int) {
+}
+
+// GetRandomizationRate returns 100, since the policy is always random.
+func (this *UniformRandomPolicy) GetRandomizationRate() int {
+
+	return 100
+}
+
+// MinimaxQ learns the value of every action in a two player, zero sum game by playing against itself,
+// using Q-learning where the value of a state is from the point of view of the player whose turn it
+// is, so that a state where the other player is to move is worth the negative of their best action.
+// Since it learns off-policy, exploring at random still converges on the values of perfect play, and
+// one policy can play either seat as long as the state records whose turn it is.  Values are keyed by
+// outcome identifier, in the same way as the average rewards.  If the game has symmetries, values are
+// learned for canonical states, so that equivalent positions share what's been learned about them.
+type MinimaxQ struct {
+	Game         Game
+	Symmetries   []Symmetry
+	Values       map[string]float64
+	KnownStates  map[string]State
+	LearningRate float64
+}
+
+// NewMinimaxQ should be used to create a MinimaxQ; it handles instantiating members appropriately.
+// The learning rate defaults to one, which learns fastest in deterministic games.
+func NewMinimaxQ(game Game) *MinimaxQ {
+
+	learner := new(MinimaxQ)
+	learner.Game = game
+	learner.Values = make(map[string]float64)
+	learner.KnownStates = make(map[string]State)
+	learner.LearningRate = 1
+	if symmetric, ok := game.(SymmetricEnvironment); ok {
+
+		learner.Symmetries = symmetric.GetSymmetries()
+	}
+
+	return learner
+}
+
+// Train plays a number of matches against itself, picking a random action at the randomization rate
+// and the best known action otherwise, and learns from every move once each match is over.
+func (this *MinimaxQ) Train(matches int, randomizationRate int) {
+
+	for i := 0; i < matches; i++ {
+
+		match := this.Game.CreateMatch()
+		states := []State{match.ObserveState()}
+		players := make([]int, 0)
+		actions := make([]Action, 0)
+		for !states[len(states)-1].IsTerminal() {
+
+			state := states[len(states)-1]
+			action := this.getBestAction(state)
+			if rand.Intn(100) < randomizationRate {
+
+				legal := this.Game.GetLegalActions(state)
+				action = legal[rand.Intn(len(legal))]
+			}
+
+			players = append(players, match.GetCurrentPlayer())
+			actions = append(actions, action)
+			match.Play(action)
+			states = append(states, match.ObserveState())
+		}
+
+		// Learn backwards from the end of the match, so that the result is felt all the way back.
+		rewards := match.GetRewards()
+		for j := len(actions) - 1; j >= 0; j-- {
+
+			state, symmetry := Canonicalize(states[j], this.Symmetries)
+			this.KnownStates[state.GetId()] = state
+			next := states[j+1]
+
+			target := float64(rewards[players[j]])
+			if !next.IsTerminal() {
+
+				target = this.GetMaxValue(next)
+				if j+1 < len(players) && players[j+1] != players[j] {
+
+					target = -target
+				}
+			}
+
+			id := getOutcomeId(state, symmetry.TransformAction(actions[j]))
+			this.Values[id] += this.LearningRate * (target - this.Values[id])
+		}
+	}
+}
+
+// GetMaxValue returns the value of the best action in a state for the player whose turn it is, where
+// actions that haven't been tried yet are worth zero.
+func (this *MinimaxQ) GetMaxValue(state State) float64 {
+
+	state, _ = Canonicalize(state, this.Symmetries)
+	max := math.Inf(-1)
+	for _, id := range getActionIds(state, this.Game.GetLegalActions(state)) {
+
+		max = math.Max(max, this.Values[id])
+	}
+
+	return max
+}
+
+// getBestAction returns the best known action in a state, picked at random when there are several.
+func (this *MinimaxQ) getBestAction(state State) Action {
+
+	state, symmetry := Canonicalize(state, this.Symmetries)
+	best := make([]Action, 0)
+	max := math.Inf(-1)
+	actions := this.Game.GetLegalActions(state)
+	for i, id := range getActionIds(state, actions) {
+
+		action := actions[i]
+		value := this.Values[id]
+		if value > max {
+
+			max = value
+			best = []Action{action}
+
+		} else if value == max {
+
+			best = append(best, action)
+		}
+	}
+
+	return symmetry.Invert().TransformAction(best[rand.Intn(len(best))])
+}
+
+// CreatePolicy creates a policy that prefers the best known action in every state that's been visited,
+// which can play either seat.
+func (this *MinimaxQ) CreatePolicy() Policy {
+
+	seat := NewGameSeat(this.Game, 0, nil)
+	policy := NewBasicPolicy()
+	policy.Environment = seat
+	policy.SetRandomizationRate(0)
+	for _, state := range this.KnownStates {
+
+		preferredAction, otherActions := GetOptimalAction(seat, state, this.Values)
+		policy.AddState(state, preferredAction, otherActions)
+	}
+
+	if len(this.Symmetries) > 0 {
+
+		return &SymmetricPolicy{Policy: policy, Symmetries: this.Symmetries}
+	}
+
+	return policy
+}
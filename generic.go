@@ -0,0 +1,286 @@
+package monoikos
+
+import (
+	"fmt"
+	"strconv"
+	"sync"
+)
+
+// TypedStateContextKey is the key used in the context of a typed state for the state itself, formatted
+// as a string.
+var TypedStateContextKey = "state"
+
+// TypedEnvironment is an environment whose states and actions are the environment's own types rather
+// than states with string contexts and actions that are run against an untyped context, so that
+// mistakes in the environment are caught when it's compiled rather than part way thru training.
+// States and actions have to be comparable, and are identified by how they're formatted with %v, so
+// they should be values rather than pointers.  The known states are the non-terminal states that can
+// be listed up front, which may be none at all.  An EnvironmentAdapter turns a typed environment into
+// an Environment, so that it can be trained in all the usual ways.
+type TypedEnvironment[S comparable, A comparable] interface {
+	CreateExperiment() TypedExperiment[S, A]
+	GetLegalActions(S) []A
+	GetKnownStates() []S
+}
+
+// TypedExperiment is a single walk thru a typed environment.  It reports the current state, whether
+// it's terminal and the reward that's been paid out so far, and it applies actions, returning an error
+// if an action can't be applied.
+type TypedExperiment[S comparable, A comparable] interface {
+	ObserveState() S
+	IsTerminal() bool
+	GetReward() int
+	Apply(A) error
+}
+
+// TypedState is a State that holds the state of a typed environment.
+type TypedState[S comparable] struct {
+	Value    S
+	Terminal bool
+	Reward   int
+}
+
+// GetId returns an identifier made up of the formatted state and whether it's terminal.
+func (this *TypedState[S]) GetId() string {
+
+	return "[" + fmt.Sprintf("%v", this.Value) + " terminal:" + strconv.FormatBool(this.Terminal) + "]"
+}
+
+// IsTerminal returns whether the state is terminal.
+func (this *TypedState[S]) IsTerminal() bool {
+
+	return this.Terminal
+}
+
+// GetContext returns a context with the formatted state.
+func (this *TypedState[S]) GetContext() map[string]string {
+
+	return map[string]string{TypedStateContextKey: fmt.Sprintf("%v", this.Value)}
+}
+
+// GetReward returns the reward that's been paid out so far.
+func (this *TypedState[S]) GetReward() int {
+
+	return this.Reward
+}
+
+// TypedAction is an Action that holds the action of a typed environment.  Running it does nothing,
+// since typed actions are applied by the experiment.
+type TypedAction[A comparable] struct {
+	Value A
+}
+
+// GetId returns the formatted action.
+func (this *TypedAction[A]) GetId() string {
+
+	return fmt.Sprintf("%v", this.Value)
+}
+
+// Run does nothing, since the action is applied by the experiment.
+func (this *TypedAction[A]) Run(context map[string]interface{}) {
+}
+
+// EnvironmentAdapter is an Environment for a typed environment.  Since the states of a typed
+// environment may not all be listed up front, the known states are the listed states along with the
+// states that have been visited so far.
+type EnvironmentAdapter[S comparable, A comparable] struct {
+	Environment TypedEnvironment[S, A]
+	KnownStates map[string]State
+	mutex       sync.Mutex
+}
+
+// NewEnvironmentAdapter should be used to create an EnvironmentAdapter; it handles instantiating
+// members appropriately.
+func NewEnvironmentAdapter[S comparable, A comparable](environment TypedEnvironment[S, A]) *EnvironmentAdapter[S, A] {
+
+	adapter := new(EnvironmentAdapter[S, A])
+	adapter.Environment = environment
+	adapter.KnownStates = make(map[string]State)
+	for _, state := range environment.GetKnownStates() {
+
+		adapter.addKnownState(&TypedState[S]{Value: state})
+	}
+
+	return adapter
+}
+
+// CreateRandomPolicy creates a random policy.
+func (this *EnvironmentAdapter[S, A]) CreateRandomPolicy() Policy {
+
+	return CreateRandomPolicy(this)
+}
+
+// CreateImprovedPolicy creates an improved policy from a set of outcomes.
+func (this *EnvironmentAdapter[S, A]) CreateImprovedPolicy(outcomes []Outcome) Policy {
+
+	return CreateImprovedPolicy(this, outcomes)
+}
+
+// CreateOptimizedPolicy creates an optimized policy.
+func (this *EnvironmentAdapter[S, A]) CreateOptimizedPolicy(initialRandomizationRate int, experimentsPerIteration int, iterations int) Policy {
+
+	return CreateOptimizedPolicy(this, initialRandomizationRate, experimentsPerIteration, iterations)
+}
+
+// CreateExperiment creates an experiment in the typed environment.
+func (this *EnvironmentAdapter[S, A]) CreateExperiment() Experiment {
+
+	return NewExperiment(&ExperimentAdapter[S, A]{Adapter: this, Experiment: this.Environment.CreateExperiment()})
+}
+
+// GetLegalActions returns the legal actions of the typed environment for a typed state.  A state that
+// doesn't hold a typed state has no legal actions, so policies report a NoLegalActionsError for it
+// rather than acting on the zero value.
+func (this *EnvironmentAdapter[S, A]) GetLegalActions(state State) []Action {
+
+	actions := make([]Action, 0)
+	typed, ok := this.GetTypedState(state)
+	if !ok {
+
+		return actions
+	}
+
+	for _, action := range this.Environment.GetLegalActions(typed) {
+
+		actions = append(actions, &TypedAction[A]{Value: action})
+	}
+
+	return actions
+}
+
+// GetKnownStates returns the listed states along with the states that have been visited so far.
+func (this *EnvironmentAdapter[S, A]) GetKnownStates() []State {
+
+	this.mutex.Lock()
+	defer this.mutex.Unlock()
+
+	states := make([]State, 0)
+	for _, state := range this.KnownStates {
+
+		states = append(states, state)
+	}
+
+	return states
+}
+
+// GetTypedState returns the typed state that a state holds, and whether it holds one.  States that
+// were created somewhere else, such as ones that were loaded, are matched to the known states by
+// identifier; a state that can't be matched comes back as the zero value and false.
+func (this *EnvironmentAdapter[S, A]) GetTypedState(state State) (S, bool) {
+
+	if typed, ok := state.(*TypedState[S]); ok {
+
+		return typed.Value, true
+	}
+
+	this.mutex.Lock()
+	defer this.mutex.Unlock()
+
+	var value S
+	typed, ok := this.KnownStates[state.GetId()].(*TypedState[S])
+	if ok {
+
+		value = typed.Value
+	}
+
+	return value, ok
+}
+
+// CreateTypedPolicy wraps a policy for the adapted environment so that it can be asked about typed
+// states.
+func (this *EnvironmentAdapter[S, A]) CreateTypedPolicy(policy Policy) *TypedPolicy[S, A] {
+
+	return &TypedPolicy[S, A]{Policy: policy}
+}
+
+// addKnownState records a state that's been visited.
+func (this *EnvironmentAdapter[S, A]) addKnownState(state *TypedState[S]) {
+
+	this.mutex.Lock()
+	defer this.mutex.Unlock()
+
+	if _, ok := this.KnownStates[state.GetId()]; !ok {
+
+		this.KnownStates[state.GetId()] = state
+	}
+}
+
+// ExperimentAdapter is a StepExperiment for a typed experiment.
+type ExperimentAdapter[S comparable, A comparable] struct {
+	Adapter    *EnvironmentAdapter[S, A]
+	Experiment TypedExperiment[S, A]
+}
+
+// ObserveState returns the current state of the typed experiment, and records it as known if it isn't
+// terminal.
+func (this *ExperimentAdapter[S, A]) ObserveState() State {
+
+	state := &TypedState[S]{Value: this.Experiment.ObserveState(), Terminal: this.Experiment.IsTerminal(), Reward: this.Experiment.GetReward()}
+	if !state.Terminal {
+
+		this.Adapter.addKnownState(state)
+	}
+
+	return state
+}
+
+// Apply applies a typed action to the typed experiment, and panics if it fails.
+func (this *ExperimentAdapter[S, A]) Apply(action Action) {
+
+	err := this.TryApply(action)
+	if err != nil {
+
+		panic(err)
+	}
+}
+
+// TryApply applies a typed action to the typed experiment, and returns an ActionError if it fails.  An
+// action that isn't typed for the environment can't be applied at all.
+func (this *ExperimentAdapter[S, A]) TryApply(action Action) error {
+
+	typed, ok := action.(*TypedAction[A])
+	if !ok {
+
+		return &IllegalActionError{State: this.ObserveState(), Action: action}
+	}
+
+	err := this.Experiment.Apply(typed.Value)
+	if err != nil {
+
+		return &ActionError{Action: action, Err: err}
+	}
+
+	return nil
+}
+
+// TypedPolicy is a policy for a typed environment that's asked about typed states and answers with
+// typed actions.  It wraps a policy for the adapted environment.
+type TypedPolicy[S comparable, A comparable] struct {
+	Policy Policy
+}
+
+// GetAction returns an action for a state, which may be randomized in the same way as the wrapped
+// policy.
+func (this *TypedPolicy[S, A]) GetAction(state S) A {
+
+	return this.getValue(this.Policy.GetAction(&TypedState[S]{Value: state}))
+}
+
+// GetPreferredAction returns the preferred action for a state, and whether there is one.
+func (this *TypedPolicy[S, A]) GetPreferredAction(state S) (A, bool) {
+
+	action := this.Policy.GetPreferredAction(&TypedState[S]{Value: state})
+	return this.getValue(action), action != nil
+}
+
+// getValue returns the typed action that an action holds, or the zero value if it doesn't hold one.
+func (this *TypedPolicy[S, A]) getValue(action Action) A {
+
+	var value A
+	if typed, ok := action.(*TypedAction[A]); ok {
+
+		value = typed.Value
+	}
+
+	return value
+}
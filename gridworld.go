@@ -0,0 +1,492 @@
+package monoikos
+
+import (
+	"errors"
+	"math/rand"
+	"strconv"
+	"strings"
+)
+
+var gridworldContextKey = "gridworld"
+var gridworldRowContextKey = "row"
+var gridworldColumnContextKey = "column"
+var gridworldRewardContextKey = "reward"
+
+// Gridworld tiles, as they appear in the ASCII map.
+const (
+	GridFloor    = '.'
+	GridWall     = '#'
+	GridStart    = 'S'
+	GridGoal     = 'G'
+	GridPit      = 'P'
+	GridSlippery = '~'
+)
+
+// Gridworld is the standard grid navigation environment.  An agent moves up, down, left or right on a
+// grid described by an ASCII map, where walls block movement, goals and pits end the experiment, and
+// slippery tiles make moves less reliable.  Every move costs the step cost, reaching a goal pays the
+// goal reward and falling into a pit pays the pit reward.  Any move can go sideways with the noise
+// probability, and moves from slippery tiles go sideways with the slip probability on top of that.
+// Experiments are truncated after the maximum number of steps so that a policy that walks into a wall
+// forever doesn't run forever; zero means no limit.
+type Gridworld struct {
+	Tiles            [][]rune
+	StepCost         int
+	GoalReward       int
+	PitReward        int
+	NoiseProbability float64
+	SlipProbability  float64
+	MaxSteps         int
+}
+
+// NewGridworld should be used to create a Gridworld; it parses the ASCII map and sets reasonable
+// defaults for everything else.  Rows are separated by new lines, and blank lines and surrounding
+// whitespace are ignored.  The map must be rectangular and needs at least one goal, along with a start
+// tile or a floor tile to start from.
+func NewGridworld(m string) (*Gridworld, error) {
+
+	gridworld := new(Gridworld)
+	gridworld.Tiles = make([][]rune, 0)
+	gridworld.StepCost = 1
+	gridworld.GoalReward = 10
+	gridworld.PitReward = -10
+	gridworld.SlipProbability = 0.5
+	gridworld.MaxSteps = 1000
+
+	goals := 0
+	starts := 0
+	for _, line := range strings.Split(m, "\n") {
+
+		line = strings.TrimSpace(line)
+		if line == "" {
+
+			continue
+		}
+
+		row := []rune(line)
+		if len(gridworld.Tiles) > 0 && len(row) != len(gridworld.Tiles[0]) {
+
+			return nil, errors.New("monoikos: gridworld map isn't rectangular")
+		}
+
+		for _, tile := range row {
+
+			switch tile {
+			case GridGoal:
+				goals++
+			case GridStart, GridFloor, GridSlippery:
+				starts++
+			case GridWall, GridPit:
+			default:
+				return nil, errors.New("monoikos: unknown gridworld tile '" + string(tile) + "'")
+			}
+		}
+
+		gridworld.Tiles = append(gridworld.Tiles, row)
+	}
+
+	if goals == 0 {
+
+		return nil, errors.New("monoikos: gridworld map has no goal")
+	}
+
+	if starts == 0 {
+
+		return nil, errors.New("monoikos: gridworld map has nowhere to start")
+	}
+
+	return gridworld, nil
+}
+
+// CreateRandomPolicy creates a random policy for the gridworld.
+func (this *Gridworld) CreateRandomPolicy() Policy {
+
+	return CreateRandomPolicy(this)
+}
+
+// CreateImprovedPolicy creates an improved policy for the gridworld from a set of outcomes.
+func (this *Gridworld) CreateImprovedPolicy(outcomes []Outcome) Policy {
+
+	return CreateImprovedPolicy(this, outcomes)
+}
+
+// CreateOptimizedPolicy creates an optimized policy for the gridworld by running iterations of experiments.
+func (this *Gridworld) CreateOptimizedPolicy(initialRandomizationRate int, experimentsPerIteration int, iterations int) Policy {
+
+	return CreateOptimizedPolicy(this, initialRandomizationRate, experimentsPerIteration, iterations)
+}
+
+// CreateExperiment creates an experiment that starts on a random start tile, or on any random tile
+// that isn't a wall, goal or pit if the map doesn't have a start tile.
+func (this *Gridworld) CreateExperiment() Experiment {
+
+	starts := make([][2]int, 0)
+	others := make([][2]int, 0)
+	for i, row := range this.Tiles {
+		for j, tile := range row {
+
+			if tile == GridStart {
+
+				starts = append(starts, [2]int{i, j})
+
+			} else if tile == GridFloor || tile == GridSlippery {
+
+				others = append(others, [2]int{i, j})
+			}
+		}
+	}
+
+	if len(starts) == 0 {
+
+		starts = others
+	}
+
+	start := starts[rand.Intn(len(starts))]
+
+	experiment := new(GridworldExperiment)
+	experiment.Context = make(map[string]interface{})
+	experiment.Context[gridworldContextKey] = this
+	experiment.Context[gridworldRowContextKey] = start[0]
+	experiment.Context[gridworldColumnContextKey] = start[1]
+	experiment.Context[gridworldRewardContextKey] = 0
+
+	return experiment
+}
+
+// GetLegalActions returns the four moves, which are legal everywhere; moving into a wall or off the
+// edge of the map just leaves the agent where it is.
+func (this *Gridworld) GetLegalActions(state State) []Action {
+
+	actions := make([]Action, 4)
+	actions[0] = &GridAction{Direction: GridUp}
+	actions[1] = &GridAction{Direction: GridDown}
+	actions[2] = &GridAction{Direction: GridLeft}
+	actions[3] = &GridAction{Direction: GridRight}
+	return actions
+}
+
+// GetKnownStates returns a state for every tile that isn't a wall.
+func (this *Gridworld) GetKnownStates() []State {
+
+	states := make([]State, 0)
+	for i, row := range this.Tiles {
+		for j, tile := range row {
+
+			if tile != GridWall {
+
+				states = append(states, this.createState(i, j, 0))
+			}
+		}
+	}
+
+	return states
+}
+
+// GetSuccessors returns the tiles that a move may end up on, with the probability of each and the
+// reward for getting there.
+func (this *Gridworld) GetSuccessors(state State, action Action) []Successor {
+
+	row, _ := strconv.Atoi(state.GetContext()[gridworldRowContextKey])
+	column, _ := strconv.Atoi(state.GetContext()[gridworldColumnContextKey])
+	direction := action.(*GridAction).Direction
+
+	// Add up the probabilities for each tile, since several moves can end up on the same one.
+	probabilities := make(map[[2]int]float64)
+	order := make([][2]int, 0)
+	for _, move := range this.getMoves(row, column, direction) {
+
+		if move.probability == 0 {
+
+			continue
+		}
+
+		destination := [2]int{move.row, move.column}
+		if _, ok := probabilities[destination]; !ok {
+
+			order = append(order, destination)
+		}
+
+		probabilities[destination] += move.probability
+	}
+
+	successors := make([]Successor, 0)
+	for _, destination := range order {
+
+		successor := Successor{State: this.createState(destination[0], destination[1], 0)}
+		successor.Probability = probabilities[destination]
+		successor.Reward = float64(this.getReward(destination[0], destination[1]))
+		successors = append(successors, successor)
+	}
+
+	return successors
+}
+
+// Render returns the map as text, one row per line.
+func (this *Gridworld) Render() string {
+
+	lines := make([]string, 0)
+	for _, row := range this.Tiles {
+
+		lines = append(lines, string(row))
+	}
+
+	return strings.Join(lines, "\n") + "\n"
+}
+
+// RenderPolicy returns the map as text with the preferred action of a policy drawn as an arrow on every
+// tile that isn't a wall, goal or pit.  Tiles that the policy doesn't know about are left as they are.
+func (this *Gridworld) RenderPolicy(policy Policy) string {
+
+	lines := make([]string, 0)
+	for i, row := range this.Tiles {
+
+		line := make([]rune, len(row))
+		for j, tile := range row {
+
+			line[j] = tile
+			if tile == GridWall || tile == GridGoal || tile == GridPit {
+
+				continue
+			}
+
+			action := policy.GetPreferredAction(this.createState(i, j, 0))
+			if action, ok := action.(*GridAction); ok {
+
+				line[j] = action.Direction.GetArrow()
+			}
+		}
+
+		lines = append(lines, string(line))
+	}
+
+	return strings.Join(lines, "\n") + "\n"
+}
+
+// gridMove is a possible result of a move, and how likely it is.
+type gridMove struct {
+	row         int
+	column      int
+	probability float64
+}
+
+// getMoves returns where a move in a direction may end up from a tile.  The intended direction is
+// taken most of the time, and the rest of the time the move goes to either side of it.
+func (this *Gridworld) getMoves(row int, column int, direction GridDirection) []gridMove {
+
+	sideways := this.NoiseProbability
+	if this.Tiles[row][column] == GridSlippery {
+
+		sideways += this.SlipProbability
+	}
+
+	if sideways > 1 {
+
+		sideways = 1
+	}
+
+	moves := make([]gridMove, 0)
+	r, c := this.getDestination(row, column, direction)
+	moves = append(moves, gridMove{row: r, column: c, probability: 1 - sideways})
+	for _, side := range direction.GetPerpendicular() {
+
+		r, c = this.getDestination(row, column, side)
+		moves = append(moves, gridMove{row: r, column: c, probability: sideways / 2})
+	}
+
+	return moves
+}
+
+// getDestination returns the tile that a move in a direction reaches, which is the same tile if the
+// move would hit a wall or leave the map.
+func (this *Gridworld) getDestination(row int, column int, direction GridDirection) (int, int) {
+
+	r := row
+	c := column
+	switch direction {
+	case GridUp:
+		r--
+	case GridDown:
+		r++
+	case GridLeft:
+		c--
+	case GridRight:
+		c++
+	}
+
+	if r < 0 || r >= len(this.Tiles) || c < 0 || c >= len(this.Tiles[r]) || this.Tiles[r][c] == GridWall {
+
+		return row, column
+	}
+
+	return r, c
+}
+
+// getReward returns the reward for moving onto a tile, which includes the step cost.
+func (this *Gridworld) getReward(row int, column int) int {
+
+	reward := -this.StepCost
+	switch this.Tiles[row][column] {
+	case GridGoal:
+		reward += this.GoalReward
+	case GridPit:
+		reward += this.PitReward
+	}
+
+	return reward
+}
+
+// createState creates the state for a tile, which reports the reward that's been paid out so far.
+func (this *Gridworld) createState(row int, column int, reward int) *BasicState {
+
+	tile := this.Tiles[row][column]
+
+	state := NewBasicState()
+	state.Context[gridworldRowContextKey] = strconv.Itoa(row)
+	state.Context[gridworldColumnContextKey] = strconv.Itoa(column)
+	state.Terminal = tile == GridGoal || tile == GridPit
+	state.Reward = reward
+
+	return state
+}
+
+// GridworldExperiment is a single walk thru a Gridworld.
+type GridworldExperiment struct {
+	Context map[string]interface{}
+	runnable
+}
+
+// ObserveState returns the current state of the experiment.  The state is terminal once a goal or pit
+// has been reached.
+func (this *GridworldExperiment) ObserveState() State {
+
+	gridworld := this.Context[gridworldContextKey].(*Gridworld)
+	row := this.Context[gridworldRowContextKey].(int)
+	column := this.Context[gridworldColumnContextKey].(int)
+	reward := this.Context[gridworldRewardContextKey].(int)
+
+	return gridworld.createState(row, column, reward)
+}
+
+// Run follows the policy until a terminal state is reached, or the experiment is truncated.
+func (this *GridworldExperiment) Run(policy Policy) []Outcome {
+
+	return this.createRunner().Run(this, policy)
+}
+
+// ForceRun takes an action and then follows the policy until a terminal state is reached, or the
+// experiment is truncated.
+func (this *GridworldExperiment) ForceRun(action Action, policy Policy) []Outcome {
+
+	return this.createRunner().ForceRun(this, action, policy)
+}
+
+// Apply runs an action against the experiment context.
+func (this *GridworldExperiment) Apply(action Action) {
+
+	action.Run(this.Context)
+}
+
+// createRunner creates a copy of the runner that the experiment runs with, which truncates the
+// experiment after the gridworld's maximum number of steps.
+func (this *GridworldExperiment) createRunner() *EpisodeRunner {
+
+	runner := *this.getRunner()
+	runner.MaxSteps = this.Context[gridworldContextKey].(*Gridworld).MaxSteps
+	return &runner
+}
+
+// GridDirection is one of the four directions that an agent can move in.
+type GridDirection int
+
+// Gridworld directions.
+const (
+	GridUp GridDirection = iota
+	GridDown
+	GridLeft
+	GridRight
+)
+
+// GetArrow returns the character used to draw the direction.
+func (this GridDirection) GetArrow() rune {
+
+	return []rune("^v<>")[this]
+}
+
+// GetPerpendicular returns the two directions at right angles to the direction.
+func (this GridDirection) GetPerpendicular() []GridDirection {
+
+	if this == GridUp || this == GridDown {
+
+		return []GridDirection{GridLeft, GridRight}
+	}
+
+	return []GridDirection{GridUp, GridDown}
+}
+
+// String returns the name of the direction.
+func (this GridDirection) String() string {
+
+	return []string{"Up", "Down", "Left", "Right"}[this]
+}
+
+// GridAction is a move in a direction.
+type GridAction struct {
+	Direction GridDirection
+}
+
+// GetId returns the name of the direction.
+func (this *GridAction) GetId() string {
+
+	return this.Direction.String()
+}
+
+// Run moves the agent, picking where it ends up from the possible moves, and pays out the reward.
+func (this *GridAction) Run(context map[string]interface{}) {
+
+	gridworld := context[gridworldContextKey].(*Gridworld)
+	row := context[gridworldRowContextKey].(int)
+	column := context[gridworldColumnContextKey].(int)
+
+	moves := gridworld.getMoves(row, column, this.Direction)
+	move := moves[len(moves)-1]
+	r := rand.Float64()
+	for _, m := range moves {
+
+		r -= m.probability
+		if r < 0 {
+
+			move = m
+			break
+		}
+	}
+
+	context[gridworldRowContextKey] = move.row
+	context[gridworldColumnContextKey] = move.column
+	context[gridworldRewardContextKey] = context[gridworldRewardContextKey].(int) + gridworld.getReward(move.row, move.column)
+}
+
+// GetSymmetries returns the rotations and reflections that leave the map exactly as it is.
+func (this *Gridworld) GetSymmetries() []Symmetry {
+
+	rows := len(this.Tiles)
+	columns := len(this.Tiles[0])
+	symmetries := make([]Symmetry, 0)
+	for _, d := range getDihedrals(rows, columns) {
+
+		symmetric := true
+		for i := 0; i < rows && symmetric; i++ {
+			for j := 0; j < columns && symmetric; j++ {
+
+				row, column := d.mapPoint(i, j)
+				symmetric = this.Tiles[i][j] == this.Tiles[row][column]
+			}
+		}
+
+		if symmetric {
+
+			symmetries = append(symmetries, &gridSymmetry{d})
+		}
+	}
+
+	return symmetries
+}
@@ -0,0 +1,354 @@
+package monoikos
+
+import (
+	"errors"
+	"sync"
+)
+
+// GymEnvironment is an environment in the reset and step shape that most reinforcement learning code
+// expects.  Reset starts a new episode and returns the first observation along with any extra
+// information, and Step takes an action and reports what happened.  Unlike the states of an
+// Experiment, observations don't need to report whether they're terminal or the reward so far, since
+// steps report those instead.  Legal actions should only depend on the observation, not on the episode
+// in progress.
+type GymEnvironment interface {
+	Reset() (State, map[string]interface{})
+	Step(Action) *GymStep
+	GetLegalActions(State) []Action
+}
+
+// FallibleGymEnvironment is a gym environment that can report that a step failed rather than panicking.
+type FallibleGymEnvironment interface {
+	GymEnvironment
+	TryStep(Action) (*GymStep, error)
+}
+
+// GymStep is what happens when an action is taken in a gym environment; the next observation, the
+// reward for just this step, whether the episode has ended in a terminal state or been cut short, and
+// any extra information.
+type GymStep struct {
+	Observation State
+	Reward      int
+	Terminated  bool
+	Truncated   bool
+	Info        map[string]interface{}
+}
+
+// GymState is the state of an experiment in a gym environment.  It has the identifier and context of
+// the observation, and it's terminal if the last step said the episode was over.  Its reward is the sum
+// of the rewards for every step so far.
+type GymState struct {
+	Observation State
+	Terminal    bool
+	Reward      int
+	Info        map[string]interface{}
+}
+
+// GetId returns the identifier of the observation.
+func (this *GymState) GetId() string {
+
+	return this.Observation.GetId()
+}
+
+// IsTerminal returns whether the episode was over.
+func (this *GymState) IsTerminal() bool {
+
+	return this.Terminal
+}
+
+// GetContext returns the context of the observation.
+func (this *GymState) GetContext() map[string]string {
+
+	return this.Observation.GetContext()
+}
+
+// GetReward returns the sum of the rewards so far.
+func (this *GymState) GetReward() int {
+
+	return this.Reward
+}
+
+// GymAdapter is an Environment for a gym environment, so that it can be trained in all the usual ways.
+// Every experiment gets a gym environment of its own, and legal actions are answered by one more that's
+// kept for the purpose.  The known states are the non-terminal states that have been visited so far.
+type GymAdapter struct {
+	Create      func() GymEnvironment
+	Legal       GymEnvironment
+	KnownStates map[string]State
+	mutex       sync.Mutex
+}
+
+// NewGymAdapter should be used to create a GymAdapter; it handles instantiating members appropriately.
+func NewGymAdapter(create func() GymEnvironment) *GymAdapter {
+
+	adapter := new(GymAdapter)
+	adapter.Create = create
+	adapter.Legal = create()
+	adapter.KnownStates = make(map[string]State)
+
+	return adapter
+}
+
+// CreateRandomPolicy creates a random policy.
+func (this *GymAdapter) CreateRandomPolicy() Policy {
+
+	return CreateRandomPolicy(this)
+}
+
+// CreateImprovedPolicy creates an improved policy from a set of outcomes.
+func (this *GymAdapter) CreateImprovedPolicy(outcomes []Outcome) Policy {
+
+	return CreateImprovedPolicy(this, outcomes)
+}
+
+// CreateOptimizedPolicy creates an optimized policy.
+func (this *GymAdapter) CreateOptimizedPolicy(initialRandomizationRate int, experimentsPerIteration int, iterations int) Policy {
+
+	return CreateOptimizedPolicy(this, initialRandomizationRate, experimentsPerIteration, iterations)
+}
+
+// CreateExperiment creates an experiment with a gym environment of its own, which is reset when its
+// state is first observed.
+func (this *GymAdapter) CreateExperiment() Experiment {
+
+	return NewExperiment(&GymExperiment{Adapter: this, Environment: this.Create()})
+}
+
+// GetLegalActions returns the legal actions for the observation a state holds.
+func (this *GymAdapter) GetLegalActions(state State) []Action {
+
+	if gymState, ok := state.(*GymState); ok {
+
+		state = gymState.Observation
+	}
+
+	return this.Legal.GetLegalActions(state)
+}
+
+// GetKnownStates returns the non-terminal states that have been visited so far.
+func (this *GymAdapter) GetKnownStates() []State {
+
+	this.mutex.Lock()
+	defer this.mutex.Unlock()
+
+	states := make([]State, 0)
+	for _, state := range this.KnownStates {
+
+		states = append(states, state)
+	}
+
+	return states
+}
+
+// addKnownState records a state that's been visited.
+func (this *GymAdapter) addKnownState(state State) {
+
+	this.mutex.Lock()
+	defer this.mutex.Unlock()
+
+	if _, ok := this.KnownStates[state.GetId()]; !ok {
+
+		this.KnownStates[state.GetId()] = state
+	}
+}
+
+// GymExperiment is a StepExperiment for a gym environment.  It cuts itself short when a step says the
+// episode was truncated.
+type GymExperiment struct {
+	Adapter     *GymAdapter
+	Environment GymEnvironment
+	State       *GymState
+	Truncated   bool
+}
+
+// ObserveState returns the current state, resetting the gym environment first if this is the start of
+// the experiment.
+func (this *GymExperiment) ObserveState() State {
+
+	if this.State == nil {
+
+		observation, info := this.Environment.Reset()
+		this.State = &GymState{Observation: observation, Info: info}
+		this.Adapter.addKnownState(this.State)
+	}
+
+	return this.State
+}
+
+// IsTruncated returns whether the last step said the episode was cut short.
+func (this *GymExperiment) IsTruncated() bool {
+
+	return this.Truncated
+}
+
+// Apply takes a step in the gym environment, and panics if it fails.
+func (this *GymExperiment) Apply(action Action) {
+
+	err := this.TryApply(action)
+	if err != nil {
+
+		panic(err)
+	}
+}
+
+// TryApply takes a step in the gym environment, and returns an ActionError if it fails.
+func (this *GymExperiment) TryApply(action Action) error {
+
+	state := this.ObserveState().(*GymState)
+	var step *GymStep
+	if fallible, ok := this.Environment.(FallibleGymEnvironment); ok {
+
+		var err error
+		step, err = fallible.TryStep(action)
+		if err != nil {
+
+			return &ActionError{Action: action, Err: err}
+		}
+
+	} else {
+
+		err := func() (err error) {
+
+			defer func() {
+
+				if r := recover(); r != nil {
+
+					err = recoveredError(r)
+				}
+			}()
+
+			step = this.Environment.Step(action)
+			return nil
+		}()
+
+		if err != nil {
+
+			return &ActionError{Action: action, Err: err}
+		}
+	}
+
+	this.State = &GymState{Observation: step.Observation, Terminal: step.Terminated, Reward: state.Reward + step.Reward, Info: step.Info}
+	this.Truncated = step.Truncated && !step.Terminated
+	if !this.State.Terminal {
+
+		this.Adapter.addKnownState(this.State)
+	}
+
+	return nil
+}
+
+// ExperimentGym is a GymEnvironment for an Environment, so that agents written for the reset and step
+// shape can be driven by its experiments.  Experiments have to be step experiments, which most of the
+// built in ones are; belief experiments, such as the registered tiger, and game experiments, such as
+// the registered tictactoe, aren't.  Rewards for each step are worked out from the rewards that
+// states report, and an episode is truncated after the maximum number of steps, if there is one.  The
+// info for every observation holds its legal actions.
+type ExperimentGym struct {
+	Environment Environment
+	MaxSteps    int
+	experiment  StepExperiment
+	state       State
+	steps       int
+}
+
+// NewExperimentGym should be used to create an ExperimentGym; it starts with the default step limit.
+func NewExperimentGym(environment Environment) *ExperimentGym {
+
+	gym := new(ExperimentGym)
+	gym.Environment = environment
+	gym.MaxSteps = DefaultMaxSteps
+
+	return gym
+}
+
+// Reset starts a new episode with a new experiment, and panics if the experiment can't be stepped.
+func (this *ExperimentGym) Reset() (State, map[string]interface{}) {
+
+	state, info, err := this.TryReset()
+	if err != nil {
+
+		panic(err)
+	}
+
+	return state, info
+}
+
+// TryReset starts a new episode in the same way as Reset, but returns an error if the experiment can't
+// be stepped.
+func (this *ExperimentGym) TryReset() (State, map[string]interface{}, error) {
+
+	experiment := this.Environment.CreateExperiment()
+	step, ok := experiment.(StepExperiment)
+	if !ok {
+
+		return nil, nil, errors.New("monoikos: the experiment can't be stepped")
+	}
+
+	this.experiment = step
+	this.state = step.ObserveState()
+	this.steps = 0
+	return this.state, this.getInfo(this.state), nil
+}
+
+// Step takes an action in the current experiment, and panics if it fails.
+func (this *ExperimentGym) Step(action Action) *GymStep {
+
+	step, err := this.TryStep(action)
+	if err != nil {
+
+		panic(err)
+	}
+
+	return step
+}
+
+// TryStep takes an action in the current experiment, and returns an error if there isn't an episode
+// in progress, the action isn't legal or it fails.
+func (this *ExperimentGym) TryStep(action Action) (*GymStep, error) {
+
+	if this.experiment == nil || this.state.IsTerminal() {
+
+		return nil, errors.New("monoikos: there's no episode in progress to step")
+	}
+
+	if !isLegalAction(this.Environment, this.state, action) {
+
+		return nil, &IllegalActionError{State: this.state, Action: action}
+	}
+
+	err := new(EpisodeRunner).apply(this.experiment, this.state, action)
+	if err != nil {
+
+		return nil, err
+	}
+
+	next := this.experiment.ObserveState()
+	step := &GymStep{Observation: next, Reward: next.GetReward() - this.state.GetReward(), Terminated: next.IsTerminal(), Info: this.getInfo(next)}
+	this.state = next
+	this.steps++
+	if !step.Terminated && this.MaxSteps > 0 && this.steps >= this.MaxSteps {
+
+		step.Truncated = true
+		this.experiment = nil
+	}
+
+	return step, nil
+}
+
+// GetLegalActions returns the legal actions in a state.
+func (this *ExperimentGym) GetLegalActions(state State) []Action {
+
+	return this.Environment.GetLegalActions(state)
+}
+
+// getInfo returns the info for an observation, which holds its legal actions if it isn't terminal.
+func (this *ExperimentGym) getInfo(state State) map[string]interface{} {
+
+	info := make(map[string]interface{})
+	if !state.IsTerminal() {
+
+		info["legal_actions"] = this.Environment.GetLegalActions(state)
+	}
+
+	return info
+}
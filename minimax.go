@@ -0,0 +1,336 @@
+package monoikos
+
+import (
+	"math"
+	"math/rand"
+)
+
+// DeterministicGame is a game where every move has a single, known result, so that it can be searched
+// ahead of time.  States are expected to record whose turn it is, so that the current player and the
+// rewards can be worked out from the state alone.  Rewards only need to be paid out in terminal states.
+type DeterministicGame interface {
+	Game
+	GetInitialState() State
+	GetNextState(State, Action) State
+	GetCurrentPlayer(State) int
+	GetRewards(State) []int
+}
+
+// DeterministicMatch is a match of a deterministic game, which just keeps track of the current state.
+type DeterministicMatch struct {
+	Game  DeterministicGame
+	State State
+}
+
+// NewDeterministicMatch should be used to create a DeterministicMatch that starts from the initial state.
+func NewDeterministicMatch(game DeterministicGame) *DeterministicMatch {
+
+	match := new(DeterministicMatch)
+	match.Game = game
+	match.State = game.GetInitialState()
+	return match
+}
+
+// ObserveState returns the current state.
+func (this *DeterministicMatch) ObserveState() State {
+
+	return this.State
+}
+
+// GetCurrentPlayer returns the player whose turn it is.
+func (this *DeterministicMatch) GetCurrentPlayer() int {
+
+	return this.Game.GetCurrentPlayer(this.State)
+}
+
+// GetRewards returns the rewards once the match is over, and nothing before that.
+func (this *DeterministicMatch) GetRewards() []int {
+
+	if !this.State.IsTerminal() {
+
+		return make([]int, this.Game.GetPlayers())
+	}
+
+	return this.Game.GetRewards(this.State)
+}
+
+// Play makes a move for the player whose turn it is.
+func (this *DeterministicMatch) Play(action Action) {
+
+	this.State = this.Game.GetNextState(this.State, action)
+}
+
+// minimaxEntry is a value in the transposition table, which may only be a bound on the true value if
+// the search that found it was cut off.
+type minimaxEntry struct {
+	value float64
+	lower bool
+	upper bool
+}
+
+// MinimaxSolver searches a two player, zero sum, deterministic game with alpha-beta pruning.  Values
+// are the reward to the first player, which the first player maximizes and the second minimizes.  With
+// no maximum depth the search is exact and values are kept in a transposition table, so that repeated
+// searches are cheap; otherwise the heuristic (zero if there isn't one) is used at the maximum depth.
+type MinimaxSolver struct {
+	Game      DeterministicGame
+	MaxDepth  int
+	Heuristic func(State) float64
+	table     map[string]minimaxEntry
+}
+
+// NewMinimaxSolver should be used to create a MinimaxSolver; it searches exhaustively by default.
+func NewMinimaxSolver(game DeterministicGame) *MinimaxSolver {
+
+	solver := new(MinimaxSolver)
+	solver.Game = game
+	solver.table = make(map[string]minimaxEntry)
+	return solver
+}
+
+// GetValue returns the value of a state to the first player, assuming both players play perfectly.
+func (this *MinimaxSolver) GetValue(state State) float64 {
+
+	return this.search(state, 0, math.Inf(-1), math.Inf(1))
+}
+
+// GetActionValues returns the value of every legal action in a state to the first player, keyed by
+// action identifier.
+func (this *MinimaxSolver) GetActionValues(state State) map[string]float64 {
+
+	values := make(map[string]float64)
+	for _, action := range this.Game.GetLegalActions(state) {
+
+		values[action.GetId()] = this.search(this.Game.GetNextState(state, action), 1, math.Inf(-1), math.Inf(1))
+	}
+
+	return values
+}
+
+// GetBestActions returns every legal action in a state that's as good as any other for the player
+// whose turn it is.
+func (this *MinimaxSolver) GetBestActions(state State) []Action {
+
+	sign := 1.0
+	if this.Game.GetCurrentPlayer(state) != 0 {
+
+		sign = -1.0
+	}
+
+	values := this.GetActionValues(state)
+	best := math.Inf(-1)
+	for _, value := range values {
+
+		best = math.Max(best, sign*value)
+	}
+
+	actions := make([]Action, 0)
+	for _, action := range this.Game.GetLegalActions(state) {
+
+		if sign*values[action.GetId()] == best {
+
+			actions = append(actions, action)
+		}
+	}
+
+	return actions
+}
+
+// search returns the value of a state to the first player with alpha-beta pruning.
+func (this *MinimaxSolver) search(state State, depth int, alpha float64, beta float64) float64 {
+
+	if state.IsTerminal() {
+
+		return float64(this.Game.GetRewards(state)[0])
+	}
+
+	if this.MaxDepth > 0 && depth >= this.MaxDepth {
+
+		if this.Heuristic == nil {
+
+			return 0
+		}
+
+		return this.Heuristic(state)
+	}
+
+	// Exact values can be reused as they are, and bounds can narrow the window.
+	id := state.GetId()
+	exact := this.MaxDepth <= 0
+	if entry, ok := this.table[id]; ok && exact {
+
+		if !entry.lower && !entry.upper {
+
+			return entry.value
+		}
+
+		if entry.lower {
+
+			alpha = math.Max(alpha, entry.value)
+
+		} else {
+
+			beta = math.Min(beta, entry.value)
+		}
+
+		if alpha >= beta {
+
+			return entry.value
+		}
+	}
+
+	a, b := alpha, beta
+	maximizing := this.Game.GetCurrentPlayer(state) == 0
+	value := math.Inf(1)
+	if maximizing {
+
+		value = math.Inf(-1)
+	}
+
+	for _, action := range this.Game.GetLegalActions(state) {
+
+		v := this.search(this.Game.GetNextState(state, action), depth+1, a, b)
+		if maximizing {
+
+			value = math.Max(value, v)
+			a = math.Max(a, value)
+
+		} else {
+
+			value = math.Min(value, v)
+			b = math.Min(b, value)
+		}
+
+		if a >= b {
+
+			break
+		}
+	}
+
+	if exact {
+
+		this.table[id] = minimaxEntry{value: value, lower: value >= beta, upper: value <= alpha}
+	}
+
+	return value
+}
+
+// GetGuaranteedValue returns the value that a policy is guaranteed when it plays one of the seats,
+// whatever the other player does.  The policy's preferred action is followed on its turns, and every
+// reply is searched on the other player's turns, so this proves how a policy does against a perfect
+// opponent rather than estimating it.  A state without a preferred action counts as negative infinity,
+// since the policy doesn't know what to do there.  Values are the reward to the player in the seat.
+func (this *MinimaxSolver) GetGuaranteedValue(policy Policy, player int) float64 {
+
+	values := make(map[string]float64)
+	return this.guarantee(this.Game.GetInitialState(), policy, player, values)
+}
+
+// IsNeverLosing returns whether a policy is guaranteed not to lose when it plays one of the seats,
+// for games where a loss pays out less than zero.
+func (this *MinimaxSolver) IsNeverLosing(policy Policy, player int) bool {
+
+	return this.GetGuaranteedValue(policy, player) >= 0
+}
+
+// guarantee returns the worst case value of a state to the player when following the policy.
+func (this *MinimaxSolver) guarantee(state State, policy Policy, player int, values map[string]float64) float64 {
+
+	if state.IsTerminal() {
+
+		return float64(this.Game.GetRewards(state)[player])
+	}
+
+	id := state.GetId()
+	if value, ok := values[id]; ok {
+
+		return value
+	}
+
+	var value float64
+	if this.Game.GetCurrentPlayer(state) == player {
+
+		action := policy.GetPreferredAction(state)
+		if action == nil {
+
+			value = math.Inf(-1)
+
+		} else {
+
+			value = this.guarantee(this.Game.GetNextState(state, action), policy, player, values)
+		}
+
+	} else {
+
+		value = math.Inf(1)
+		for _, action := range this.Game.GetLegalActions(state) {
+
+			value = math.Min(value, this.guarantee(this.Game.GetNextState(state, action), policy, player, values))
+		}
+	}
+
+	values[id] = value
+	return value
+}
+
+// CreatePolicy creates a policy that always plays one of the best actions, picked at random when
+// there are several.
+func (this *MinimaxSolver) CreatePolicy() *MinimaxPolicy {
+
+	policy := new(MinimaxPolicy)
+	policy.Solver = this
+	return policy
+}
+
+// MinimaxPolicy is a fixed policy that plays perfectly, which makes the strongest possible opponent
+// to evaluate trained policies against.  Some of the time it can play a random legal action instead,
+// which makes it a weaker opponent that's still mostly perfect.
+type MinimaxPolicy struct {
+	Solver            *MinimaxSolver
+	RandomizationRate int
+}
+
+// GetAction returns one of the best actions, or a random legal action if randomization is triggered
+// based on the randomization rate.
+func (this *MinimaxPolicy) GetAction(state State) Action {
+
+	if rand.Intn(100) < this.RandomizationRate {
+
+		actions := this.Solver.Game.GetLegalActions(state)
+		return actions[rand.Intn(len(actions))]
+	}
+
+	return this.GetPreferredAction(state)
+}
+
+// GetPreferredAction returns one of the best actions.
+func (this *MinimaxPolicy) GetPreferredAction(state State) Action {
+
+	actions := this.Solver.GetBestActions(state)
+	if len(actions) == 0 {
+
+		return nil
+	}
+
+	return actions[rand.Intn(len(actions))]
+}
+
+// AddRandomState does nothing, since the policy is worked out by searching.
+func (this *MinimaxPolicy) AddRandomState(state State) {
+}
+
+// AddState does nothing, since the policy is worked out by searching.
+func (this *MinimaxPolicy) AddState(state State, preferredAction Action, otherActions []Action) {
+}
+
+// SetRandomizationRate sets the rate where a random action will be played instead of a best action.
+func (this *MinimaxPolicy) SetRandomizationRate(randomizationRate int) {
+
+	this.RandomizationRate = randomizationRate
+}
+
+// GetRandomizationRate gets the rate where a random action will be played instead of a best action.
+func (this *MinimaxPolicy) GetRandomizationRate() int {
+
+	return this.RandomizationRate
+}
@@ -0,0 +1,319 @@
+package monoikos
+
+import (
+	"math"
+	"math/rand"
+)
+
+// Successor is a state that may follow taking an action in another state, along with the probability
+// that it does and the reward that's paid out for getting there.
+type Successor struct {
+	State       State
+	Probability float64
+	Reward      float64
+}
+
+// EmpiricalModel is an estimate of how an environment behaves that's built up from observed transitions.
+// For each state and action pair it keeps track of which states followed and what reward was paid out,
+// so that it can estimate the probability of each successor and the expected reward, and simulate
+// further experience without running more experiments.
+type EmpiricalModel struct {
+	States     map[string]State
+	Actions    map[string]Action
+	Occurences map[string]int
+	Successors map[string]map[string]int
+	Rewards    map[string]map[string]int
+	Terminal   map[string]bool
+	observed   []string
+	initialIds map[string]string
+}
+
+// NewEmpiricalModel should be used to create an EmpiricalModel; it handles instantiating members appropriately.
+func NewEmpiricalModel() *EmpiricalModel {
+
+	model := new(EmpiricalModel)
+	model.States = make(map[string]State)
+	model.Actions = make(map[string]Action)
+	model.Occurences = make(map[string]int)
+	model.Successors = make(map[string]map[string]int)
+	model.Rewards = make(map[string]map[string]int)
+	model.Terminal = make(map[string]bool)
+	model.observed = make([]string, 0)
+	model.initialIds = make(map[string]string)
+
+	return model
+}
+
+// Observe adds a single transition to the model.
+func (this *EmpiricalModel) Observe(transition *Transition) {
+
+	id := getOutcomeId(transition.State, transition.Action)
+	next := transition.NextState.GetId()
+
+	// Remember the state and action so that they can be handed back out when simulating.
+	if _, ok := this.Occurences[id]; !ok {
+
+		this.Occurences[id] = 0
+		this.Successors[id] = make(map[string]int)
+		this.Rewards[id] = make(map[string]int)
+		this.Actions[id] = transition.Action
+		this.initialIds[id] = transition.State.GetId()
+		this.observed = append(this.observed, id)
+	}
+
+	this.States[transition.State.GetId()] = transition.State
+	this.States[next] = transition.NextState
+	this.Terminal[next] = transition.Terminal
+
+	this.Occurences[id] = this.Occurences[id] + 1
+	this.Successors[id][next] = this.Successors[id][next] + 1
+	this.Rewards[id][next] = this.Rewards[id][next] + transition.Reward
+}
+
+// ObserveOutcomes breaks the outcomes of a single experiment run into transitions and adds each of them.
+func (this *EmpiricalModel) ObserveOutcomes(outcomes []Outcome) {
+
+	for _, transition := range GetTransitions(outcomes) {
+
+		this.Observe(transition)
+	}
+}
+
+// GetSuccessors returns the states that have been observed to follow an action in a state, with the
+// fraction of the time that each of them did and the average reward paid out for reaching them.  It
+// returns an empty list if the action has never been observed in the state.
+func (this *EmpiricalModel) GetSuccessors(state State, action Action) []Successor {
+
+	successors := make([]Successor, 0)
+	id := getOutcomeId(state, action)
+	n, ok := this.Occurences[id]
+	if !ok {
+
+		return successors
+	}
+
+	for next, count := range this.Successors[id] {
+
+		successor := Successor{State: this.States[next]}
+		successor.Probability = float64(count) / float64(n)
+		successor.Reward = float64(this.Rewards[id][next]) / float64(count)
+		successors = append(successors, successor)
+	}
+
+	return successors
+}
+
+// GetExpectedReward returns the average reward that's been paid out for taking an action in a state.
+func (this *EmpiricalModel) GetExpectedReward(state State, action Action) float64 {
+
+	reward := 0.0
+	for _, successor := range this.GetSuccessors(state, action) {
+
+		reward += successor.Probability * successor.Reward
+	}
+
+	return reward
+}
+
+// Sample simulates taking an action in a state by picking one of the observed successors in proportion
+// to how often it occured.  Transitions pay out whole rewards, so the reward is the average reward for
+// reaching the successor rounded to the nearest whole number.  It returns nil if the action has never
+// been observed in the state.
+func (this *EmpiricalModel) Sample(state State, action Action) *Transition {
+
+	id := getOutcomeId(state, action)
+	n, ok := this.Occurences[id]
+	if !ok {
+
+		return nil
+	}
+
+	// Walk the successors until the running count passes a random point.
+	k := rand.Intn(n)
+	for next, count := range this.Successors[id] {
+
+		k -= count
+		if k < 0 {
+
+			transition := new(Transition)
+			transition.State = state
+			transition.Action = action
+			transition.Reward = int(math.Round(float64(this.Rewards[id][next]) / float64(count)))
+			transition.NextState = this.States[next]
+			transition.Terminal = this.Terminal[next]
+			return transition
+		}
+	}
+
+	return nil
+}
+
+// SampleObserved simulates a transition from a state and action pair picked at random from the ones
+// that have been observed.  It returns nil if nothing has been observed yet.
+func (this *EmpiricalModel) SampleObserved() *Transition {
+
+	if len(this.observed) == 0 {
+
+		return nil
+	}
+
+	id := this.observed[rand.Intn(len(this.observed))]
+	return this.Sample(this.States[this.initialIds[id]], this.Actions[id])
+}
+
+// DynaQ is a learner that mixes real experience with simulated experience.  Each real transition is
+// used to update action values directly with Q-learning, and to refine an empirical model of the
+// environment, which is then sampled for a number of planning updates.  Values are keyed by outcome
+// identifier so that they can be turned into a policy in the same way as average rewards.
+type DynaQ struct {
+	Environment   Environment
+	Model         *EmpiricalModel
+	Values        map[string]float64
+	LearningRate  float64
+	Discount      float64
+	PlanningSteps int
+}
+
+// NewDynaQ should be used to create a DynaQ; it handles instantiating members appropriately.
+func NewDynaQ(environment Environment) *DynaQ {
+
+	learner := new(DynaQ)
+	learner.Environment = environment
+	learner.Model = NewEmpiricalModel()
+	learner.Values = make(map[string]float64)
+	learner.LearningRate = 0.1
+	learner.Discount = 1.0
+	learner.PlanningSteps = 10
+
+	return learner
+}
+
+// Update applies a single Q-learning update for a transition, moving the value of the action towards
+// the reward plus the discounted value of the best action in the next state.  Only terminal states are
+// worth nothing from then on; the next state of a truncated step still is, since the experiment was
+// just cut short.
+func (this *DynaQ) Update(transition *Transition) {
+
+	target := float64(transition.Reward)
+	if !transition.Terminal {
+
+		target += this.Discount * this.GetMaxValue(transition.NextState)
+	}
+
+	id := getOutcomeId(transition.State, transition.Action)
+	this.Values[id] = this.Values[id] + this.LearningRate*(target-this.Values[id])
+}
+
+// GetMaxValue returns the value of the best legal action in a state, treating actions that haven't been
+// valued yet as zero.
+func (this *DynaQ) GetMaxValue(state State) float64 {
+
+	set := false
+	max := 0.0
+	for _, action := range this.Environment.GetLegalActions(state) {
+
+		value := this.Values[getOutcomeId(state, action)]
+		if !set || value > max {
+
+			max = value
+			set = true
+		}
+	}
+
+	return max
+}
+
+// Learn uses a real transition to update both the values and the model, and then runs planning updates.
+func (this *DynaQ) Learn(transition *Transition) {
+
+	this.Update(transition)
+	this.Model.Observe(transition)
+	this.Plan(this.PlanningSteps)
+}
+
+// LearnOutcomes breaks the outcomes of a single experiment run into transitions and learns from each.
+func (this *DynaQ) LearnOutcomes(outcomes []Outcome) {
+
+	for _, transition := range GetTransitions(outcomes) {
+
+		this.Learn(transition)
+	}
+}
+
+// Plan runs n updates using transitions simulated from the model rather than real experience.
+func (this *DynaQ) Plan(n int) {
+
+	for i := 0; i < n; i++ {
+
+		transition := this.Model.SampleObserved()
+		if transition == nil {
+
+			return
+		}
+
+		this.Update(transition)
+	}
+}
+
+// CreatePolicy returns a policy that prefers the action with the highest learned value in each state.
+func (this *DynaQ) CreatePolicy() Policy {
+
+	return CreatePolicyFromValues(this.Environment, this.Values)
+}
+
+// CreateDynaQPolicy is a utility function that trains a policy with Dyna-Q in the same iterative way as
+// CreateOptimizedPolicy; experiments are run with a policy whose randomization rate decreases with each
+// iteration, and the policy is refreshed from the learned values at the end of each iteration.  Since
+// every real transition is followed by planning updates, far fewer experiments are typically needed.
+func CreateDynaQPolicy(environment Environment, initialRandomizationRate int, experimentsPerIteration int, iterations int, planningSteps int) Policy {
+
+	learner := NewDynaQ(environment)
+	learner.PlanningSteps = planningSteps
+	policy := environment.CreateRandomPolicy()
+
+	// The -1 is because we always want an extra iteration at 0 randomization, which is the only
+	// iteration if there's just one.
+	for i := (iterations - 1); i >= 0; i-- {
+
+		// Set a randomization rate that decreases with each iteration.
+		randomizationRate := 0
+		if iterations > 1 {
+
+			randomizationRate = int(float64(initialRandomizationRate) * (float64(i) / float64(iterations-1)))
+		}
+		policy.SetRandomizationRate(randomizationRate)
+
+		// Learn from each experiment as soon as it's been run.
+		for j := 0; j < experimentsPerIteration; j++ {
+
+			experiment := environment.CreateExperiment()
+			learner.LearnOutcomes(experiment.Run(policy))
+		}
+
+		policy = learner.CreatePolicy()
+	}
+
+	policy.SetRandomizationRate(0)
+	return policy
+}
+
+// getOutcomeId returns the identifier that an outcome for an action taken in a state would have.
+func getOutcomeId(state State, action Action) string {
+
+	outcome := BasicOutcome{InitialState: state, ActionTaken: action}
+	return outcome.GetId()
+}
+
+// getActionIds returns the outcome identifiers for taking each of a set of actions in a state, which
+// only works out the identifier of the state once.
+func getActionIds(state State, actions []Action) []string {
+
+	prefix := "[" + state.GetId() + " => "
+	ids := make([]string, len(actions))
+	for i, action := range actions {
+
+		ids[i] = prefix + action.GetId() + "]"
+	}
+
+	return ids
+}
@@ -1,6 +1,7 @@
 package monoikos
 
 import (
+	"context"
 	"math/rand"
 	"sort"
 	"strconv"
@@ -129,12 +130,17 @@ type Policy interface {
 }
 
 // BasicPolicy is a straightforward and fairly generic implementation of a policy with broad applicability.
+// It keeps track of the legal actions that haven't been tried in each state, and if it explores
+// unexplored actions first, it takes each of them once whenever it's randomizing at all before it
+// falls back to the preferred action and other actions.
 type BasicPolicy struct {
 	RandomizationRate int
+	UnexploredFirst   bool
 	Environment       Environment
 	KnownStates       map[string]State
 	PreferredAction   map[string]Action
 	OtherActions      map[string][]Action
+	UntriedActions    map[string][]Action
 }
 
 // NewBasicPolicy should be used to create a BasicPolicy; it handles instantiating members appropriately.
@@ -145,19 +151,48 @@ func NewBasicPolicy() *BasicPolicy {
 	policy.KnownStates = make(map[string]State)
 	policy.PreferredAction = make(map[string]Action)
 	policy.OtherActions = make(map[string][]Action)
+	policy.UntriedActions = make(map[string][]Action)
 
 	return policy
 }
 
 // GetAction returns an action for a given state that could either be the preferred action, or
-// another random action if randomization is triggered based on the randomization rate.
+// another random action if randomization is triggered based on the randomization rate.  It panics with
+// a NoLegalActionsError if the state hasn't been seen before and has no legal actions.
 func (this *BasicPolicy) GetAction(state State) Action {
 
+	action, err := this.TryGetAction(state)
+	if err != nil {
+
+		panic(err)
+	}
+
+	return action
+}
+
+// TryGetAction returns an action for a given state in the same way as GetAction, or a
+// NoLegalActionsError if the state hasn't been seen before and has no legal actions.
+func (this *BasicPolicy) TryGetAction(state State) (Action, error) {
+
 	// If the state hasn't been seen before, add it with a random action associated to it.
 	id := state.GetId()
 	if _, ok := this.KnownStates[id]; !ok {
 
-		this.AddRandomState(state)
+		err := this.TryAddRandomState(state)
+		if err != nil {
+
+			return nil, err
+		}
+	}
+
+	// Take an untried action first if there is one and we're exploring at all.
+	untried := this.UntriedActions[id]
+	if this.UnexploredFirst && this.RandomizationRate > 0 && len(untried) > 0 {
+
+		m := rand.Intn(len(untried))
+		action := untried[m]
+		this.UntriedActions[id] = append(append([]Action{}, untried[:m]...), untried[m+1:]...)
+		return action, nil
 	}
 
 	// Pick a random number to see whether we should randomize.
@@ -168,11 +203,11 @@ func (this *BasicPolicy) GetAction(state State) Action {
 	if l > 0 && k < this.RandomizationRate {
 
 		m := rand.Intn(l)
-		return this.OtherActions[id][m]
+		return this.OtherActions[id][m], nil
 	}
 
 	// Otherwise return the preferred action.
-	return this.PreferredAction[id]
+	return this.PreferredAction[id], nil
 }
 
 // GetPreferredAction returns the preferred action, and never uses any randomization.
@@ -181,10 +216,86 @@ func (this *BasicPolicy) GetPreferredAction(state State) Action {
 	return this.PreferredAction[state.GetId()]
 }
 
+// GetActionProbabilities returns how likely GetAction is to return each action in a state as things
+// stand, keyed by action identifier.  A state that hasn't been seen before would be given a random
+// preferred action, so every legal action is as likely as any other; if there's no environment to ask
+// for them, it returns nil.
+func (this *BasicPolicy) GetActionProbabilities(state State) map[string]float64 {
+
+	id := state.GetId()
+	if _, ok := this.KnownStates[id]; !ok {
+
+		if this.Environment == nil {
+
+			return nil
+		}
+
+		return getUniformProbabilities(this.Environment.GetLegalActions(state))
+	}
+
+	// Untried actions are taken first, one at a time, if we're exploring at all.
+	untried := this.UntriedActions[id]
+	if this.UnexploredFirst && this.RandomizationRate > 0 && len(untried) > 0 {
+
+		return getUniformProbabilities(untried)
+	}
+
+	// Otherwise the randomization rate is shared between the other actions.
+	probabilities := make(map[string]float64)
+	others := this.OtherActions[id]
+	randomized := 0.0
+	if len(others) > 0 {
+
+		randomized = float64(this.RandomizationRate) / 100
+		for _, action := range others {
+
+			probabilities[action.GetId()] += randomized / float64(len(others))
+		}
+	}
+
+	if preferred := this.PreferredAction[id]; preferred != nil {
+
+		probabilities[preferred.GetId()] += 1 - randomized
+	}
+
+	return probabilities
+}
+
+// getUniformProbabilities returns an equal probability for each action, keyed by action identifier.
+func getUniformProbabilities(actions []Action) map[string]float64 {
+
+	probabilities := make(map[string]float64)
+	for _, action := range actions {
+
+		probabilities[action.GetId()] += 1 / float64(len(actions))
+	}
+
+	return probabilities
+}
+
 // AddRandomState adds a state to the policy and picks a random action as the state preferred action.
+// None of the legal actions have been tried yet, so they're all untried.  It panics with a
+// NoLegalActionsError if the state has no legal actions.
 func (this *BasicPolicy) AddRandomState(state State) {
 
+	err := this.TryAddRandomState(state)
+	if err != nil {
+
+		panic(err)
+	}
+}
+
+// TryAddRandomState adds a state to the policy in the same way as AddRandomState, or returns a
+// NoLegalActionsError if the state has no legal actions.
+func (this *BasicPolicy) TryAddRandomState(state State) error {
+
 	actions := this.Environment.GetLegalActions(state)
+	if len(actions) == 0 {
+
+		return &NoLegalActionsError{State: state}
+	}
+
+	this.UntriedActions[state.GetId()] = append([]Action{}, actions...)
 
 	// Select a random action from the list, and remove it from the other actions list.
 	k := rand.Intn(len(actions))
@@ -193,9 +304,11 @@ func (this *BasicPolicy) AddRandomState(state State) {
 
 	// Add the state with the randomly selected preferred action plus other actions.
 	this.AddState(state, action, actions)
+	return nil
 }
 
-// AddState adds a state to the policy and uses the specified action as the state preferred action.
+// AddState adds a state to the policy and uses the specified action as the state preferred action.  It
+// leaves the untried actions for the state alone.
 func (this *BasicPolicy) AddState(state State, preferredAction Action, otherActions []Action) {
 
 	id := state.GetId()
@@ -229,11 +342,68 @@ type Outcome interface {
 	GetFinalState() State
 }
 
+// ActionOutcome is an outcome that can report the action that was taken from its initial state, which
+// is what methods that learn from individual steps need to know.
+type ActionOutcome interface {
+	Outcome
+	GetActionTaken() Action
+}
+
+// GetActionTaken returns the action that was taken from an outcome's initial state, or nil if the
+// outcome can't say.
+func GetActionTaken(outcome Outcome) Action {
+
+	if action, ok := outcome.(ActionOutcome); ok {
+
+		return action.GetActionTaken()
+	}
+
+	return nil
+}
+
+// TransitionOutcome is an outcome that also records the state that immediately followed the action and
+// the reward paid out for that single step, so that it doubles as a true transition for methods that
+// learn from individual steps.
+type TransitionOutcome interface {
+	Outcome
+	GetNextState() State
+	GetImmediateReward() int
+}
+
+// GetNextState returns the state that immediately followed an outcome's action, or nil if the outcome
+// can't say.
+func GetNextState(outcome Outcome) State {
+
+	if transition, ok := outcome.(TransitionOutcome); ok {
+
+		return transition.GetNextState()
+	}
+
+	return nil
+}
+
+// GetImmediateReward returns the reward that was paid out for an outcome's single step, or zero if the
+// outcome can't say.
+func GetImmediateReward(outcome Outcome) int {
+
+	if transition, ok := outcome.(TransitionOutcome); ok {
+
+		return transition.GetImmediateReward()
+	}
+
+	return 0
+}
+
 // BasicOutcome is a simple implementation of Outcome that is broadly applicable.
 type BasicOutcome struct {
 	InitialState State
 	ActionTaken  Action
+	NextState    State
 	FinalState   State
+	Truncated    bool
+
+	// BehaviorProbability is how likely the policy was to take the action, or zero if it couldn't say.
+	BehaviorProbability float64
 }
 
 // GetId returns an identifier that uniquely identifies the outcome by concatenating the identifier
@@ -264,12 +434,53 @@ func (this *BasicOutcome) GetInitialState() State {
 	return this.InitialState
 }
 
+// GetActionTaken returns the action that was taken from the initial state.
+func (this *BasicOutcome) GetActionTaken() Action {
+
+	return this.ActionTaken
+}
+
+// GetNextState returns the state that immediately followed the action taken from the initial state,
+// which is only the final state for the last step of an experiment.  It may be nil for experiments
+// that only record the final state.
+func (this *BasicOutcome) GetNextState() State {
+
+	return this.NextState
+}
+
+// GetImmediateReward returns the reward that was paid out for this single step.  States report the
+// reward that has been paid out so far, so this is the difference between what the next state and
+// the initial state report.  It's zero if the next state wasn't recorded.
+func (this *BasicOutcome) GetImmediateReward() int {
+
+	if this.NextState == nil {
+
+		return 0
+	}
+
+	return this.NextState.GetReward() - this.InitialState.GetReward()
+}
+
 // GetFinalState returns the final state for the particular outcome.
 func (this *BasicOutcome) GetFinalState() State {
 
 	return this.FinalState
 }
 
+// IsTruncated returns whether the experiment was cut short before the final state, in which case the
+// final state isn't terminal and the reward is only what was paid out before it was.
+func (this *BasicOutcome) IsTruncated() bool {
+
+	return this.Truncated
+}
+
+// GetBehaviorProbability returns how likely the policy was to take the action, or zero if it couldn't
+// say.
+func (this *BasicOutcome) GetBehaviorProbability() float64 {
+
+	return this.BehaviorProbability
+}
+
 // CreateRandomPolicy is a utility function for crafting a random policy.  The current implementation
 // is light enough that it may not warrant it's own function, but it's likely that more will be
 // added here in the future.
@@ -281,15 +492,27 @@ func CreateRandomPolicy(environment Environment) Policy {
 }
 
 // GetAverageRewards returns the average reward for each state represented in a set of outcomes.
-// In the future it may make sense to check for things like the least dense states in the list of
-// outcomes since those averages may not mean much.
+// Outcomes from experiments that were truncated only paid out part of what the experiment would have,
+// so they're bootstrapped; they're credited with what was paid out plus the value of the state that
+// the experiment was cut short in, as far as the outcomes that weren't truncated can tell.  If none of
+// them went on from that state the truncated outcomes are left out, rather than making actions that
+// never finish look better or worse than they are.  In the future it may make sense to check for
+// things like the least dense states in the list of outcomes since those averages may not mean much.
 func GetAverageRewards(outcomes []Outcome) map[string]float64 {
 
-	// Get the raw occurences and total rewards for each state and action pair.
+	// Get the raw occurences and total rewards for each state and action pair, leaving the truncated
+	// outcomes until everything else has been counted.
 	occurences := make(map[string]int)
-	totalRewards := make(map[string]int)
+	totalRewards := make(map[string]float64)
+	truncated := make([]Outcome, 0)
 	for _, outcome := range outcomes {
 
+		if IsTruncated(outcome) {
+
+			truncated = append(truncated, outcome)
+			continue
+		}
+
 		id := outcome.GetId()
 		if _, ok := occurences[id]; !ok {
 
@@ -298,19 +521,76 @@ func GetAverageRewards(outcomes []Outcome) map[string]float64 {
 		}
 
 		occurences[id] = occurences[id] + 1
-		totalRewards[id] = totalRewards[id] + outcome.GetReward()
+		totalRewards[id] = totalRewards[id] + float64(outcome.GetReward())
+	}
+
+	// Bootstrap the truncated outcomes from the states they were cut short in, if there are any, since
+	// working out what every state is worth means going back thru all of the outcomes.
+	if len(truncated) > 0 {
+
+		values := getRemainingValues(outcomes)
+		for _, outcome := range truncated {
+
+			value, ok := values[outcome.GetFinalState().GetId()]
+			if !ok {
+
+				continue
+			}
+
+			id := outcome.GetId()
+			occurences[id] = occurences[id] + 1
+			totalRewards[id] = totalRewards[id] + float64(outcome.GetReward()) + value
+		}
 	}
 
 	// Go back thru and calculate the average rewards for each state and action pair.
 	averageRewards := make(map[string]float64)
 	for id, _ := range occurences {
 
-		averageRewards[id] = float64(totalRewards[id]) / float64(occurences[id])
+		averageRewards[id] = totalRewards[id] / float64(occurences[id])
 	}
 
 	return averageRewards
 }
 
+// getRemainingValues returns the value of each state that outcomes which weren't truncated went on
+// from, which is the best average of what was paid out after the state over the actions taken in it.
+// States report the reward that has been paid out so far, so what was paid out after a state is the
+// difference between the final state and it.
+func getRemainingValues(outcomes []Outcome) map[string]float64 {
+
+	// Get the occurences and total remaining rewards for each state and action pair, along with the
+	// state each pair was in.
+	occurences := make(map[string]int)
+	totalRewards := make(map[string]int)
+	states := make(map[string]string)
+	for _, outcome := range outcomes {
+
+		if IsTruncated(outcome) {
+
+			continue
+		}
+
+		id := outcome.GetId()
+		occurences[id]++
+		totalRewards[id] += outcome.GetFinalState().GetReward() - outcome.GetInitialState().GetReward()
+		states[id] = outcome.GetInitialState().GetId()
+	}
+
+	// Keep the best average for each state.
+	values := make(map[string]float64)
+	for id, state := range states {
+
+		average := float64(totalRewards[id]) / float64(occurences[id])
+		if value, ok := values[state]; !ok || average > value {
+
+			values[state] = average
+		}
+	}
+
+	return values
+}
+
 // GetOptimalAction returns the optimal preferred action for a state based on a set of rewards for
 // outcomes, along with the other possible actions for the state.
 func GetOptimalAction(environment Environment, state State, rewards map[string]float64) (Action, []Action) {
@@ -365,67 +645,37 @@ func GetOptimalAction(environment Environment, state State, rewards map[string]f
 // policy and a set of outcomes.
 func CreateImprovedPolicy(environment Environment, outcomes []Outcome) Policy {
 
-	rewards := GetAverageRewards(outcomes)
-	policy := NewBasicPolicy()
-	policy.Environment = environment
-
-	// For each state, add it to the policy with a preferred or randomized action.
-	for _, state := range environment.GetKnownStates() {
-
-		preferredAction, otherActions := GetOptimalAction(environment, state, rewards)
-		if preferredAction == nil {
-
-			policy.AddRandomState(state)
+	return CreatePolicyFromValues(environment, GetAverageRewards(outcomes))
+}
 
-		} else {
+// CreatePolicyFromValues is a utility function for creating a policy that prefers the action with the
+// highest value in each known state, where values are keyed by outcome identifier in the same way as
+// the average rewards.  Untried actions are left out.
+func CreatePolicyFromValues(environment Environment, rewards map[string]float64) Policy {
 
-			policy.AddState(state, preferredAction, otherActions)
-		}
-	}
-
-	return policy
+	return CreateExploringPolicyFromValues(environment, rewards, Exploration{})
 }
 
 // CreateOptimizedPolicy is a utility function for running iterations of generating a random policy,
 // testing the policy and keeping track of outcomes, and then iterating again and generating a
 // better policy.  The policy that is returned should be fairly optimized, assuming that the environment
-// and state space was defined correctly, and the tuning parameters were reasonable.
+// and state space was defined correctly, and the tuning parameters were reasonable.  It panics if an
+// experiment fails.
 func CreateOptimizedPolicy(environment Environment, initialRandomizationRate int, experimentsPerIteration int, iterations int) Policy {
 
-	policy := environment.CreateRandomPolicy()
-
-	// Loop for the number of desired iterations.
-	// The -1 is because we always want an extra iteration at 0 randomization.
-	for i := (iterations - 1); i >= 0; i-- {
-
-		n := 0
-		t := 0
-
-		// Set a randomization rate that decreases with each iteration.
-		randomizationRate := int(float64(initialRandomizationRate) * (float64(i) / float64(iterations-1)))
-		policy.SetRandomizationRate(randomizationRate)
-
-		// Run experiments to generate sets of outcomes to improve the policy.
-		outcomes := []Outcome{}
-		for j := 0; j < experimentsPerIteration; j++ {
+	policy, err := TryCreateOptimizedPolicy(environment, initialRandomizationRate, experimentsPerIteration, iterations, AbortOnFailure)
+	if err != nil {
 
-			r := 0
-			experiment := environment.CreateExperiment()
-			for _, outcome := range experiment.Run(policy) {
-
-				outcomes = append(outcomes, outcome)
-				r = outcome.GetReward()
-			}
-
-			n++
-			t += r
-		}
-
-		// Create the improved policy and use it moving forward.
-		policy = environment.CreateImprovedPolicy(outcomes)
+		panic(err)
 	}
 
-	// Set the final randomization rate to zero and return the policy.
-	policy.SetRandomizationRate(0)
 	return policy
 }
+
+// TryCreateOptimizedPolicy optimizes a policy in the same way as CreateOptimizedPolicy, but returns an
+// error instead of panicking when an experiment fails.  Depending on the failure mode it either stops
+// and returns the error straight away, or leaves the failed experiment out and carries on.
+func TryCreateOptimizedPolicy(environment Environment, initialRandomizationRate int, experimentsPerIteration int, iterations int, mode FailureMode) (Policy, error) {
+
+	return CreateOptimizedPolicyContext(context.Background(), environment, initialRandomizationRate, experimentsPerIteration, iterations, mode)
+}
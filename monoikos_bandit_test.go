@@ -0,0 +1,91 @@
+package monoikos_test
+
+import (
+	"testing"
+
+	"github.com/tysont/monoikos"
+)
+
+func createBernoulliBandit() *monoikos.Bandit {
+
+	return monoikos.NewBandit(
+		&monoikos.BernoulliArm{Probability: 0.2},
+		&monoikos.BernoulliArm{Probability: 0.5},
+		&monoikos.BernoulliArm{Probability: 0.8},
+	)
+}
+
+func TestBanditAgentsBeatRandom(t *testing.T) {
+
+	pulls := 5000
+	random := monoikos.RunBandit(createBernoulliBandit(), monoikos.NewEpsilonGreedyAgent(3, 1.0), pulls)
+
+	agents := map[string]monoikos.BanditAgent{
+		"epsilon greedy": monoikos.NewEpsilonGreedyAgent(3, 0.1),
+		"UCB1":           monoikos.NewUCB1Agent(3),
+		"Thompson":       monoikos.NewThompsonAgent(3),
+		"EXP3":           monoikos.NewEXP3Agent(3, 0.1),
+	}
+
+	for name, agent := range agents {
+
+		report := monoikos.RunBandit(createBernoulliBandit(), agent, pulls)
+		if report.GetRegret() > random.GetRegret()/3 {
+
+			t.Errorf("Expected %v regret to be well under random regret of '%v', got '%v'.", name, random.GetRegret(), report.GetRegret())
+		}
+
+		n := 0
+		for _, arm := range report.Arms[pulls/2:] {
+
+			if arm == 2 {
+
+				n++
+			}
+		}
+
+		if n < pulls/4 {
+
+			t.Errorf("Expected %v to mostly pull the best arm by the end, got '%v' of '%v'.", name, n, pulls/2)
+		}
+	}
+}
+
+func TestNonStationaryBandit(t *testing.T) {
+
+	bandit := monoikos.NewBandit(
+		&monoikos.DriftingArm{Mean: 0, StandardDeviation: 1, Drift: 0.05},
+		&monoikos.DriftingArm{Mean: 0, StandardDeviation: 1, Drift: 0.05},
+	)
+
+	report := monoikos.RunBandit(bandit, monoikos.NewEpsilonGreedyAgent(2, 0.1), 1000)
+	if len(report.Regret) != 1000 {
+
+		t.Fatalf("Expected regret to be tracked for every pull, got '%v'.", len(report.Regret))
+	}
+
+	for i := 1; i < len(report.Regret); i++ {
+
+		if report.Regret[i] < report.Regret[i-1] {
+
+			t.Errorf("Expected cumulative regret to never decrease, but it did at pull '%v'.", i)
+			break
+		}
+	}
+}
+
+func TestOptimizeBanditPolicy(t *testing.T) {
+
+	bandit := monoikos.NewBandit(
+		&monoikos.GaussianArm{Mean: 1, StandardDeviation: 1},
+		&monoikos.GaussianArm{Mean: 5, StandardDeviation: 1},
+		&monoikos.GaussianArm{Mean: 3, StandardDeviation: 1},
+	)
+
+	policy := bandit.CreateOptimizedPolicy(40, 1000, 3)
+	action := policy.GetPreferredAction(bandit.GetKnownStates()[0])
+	if action.GetId() != "Arm 1" {
+
+		t.Errorf("Expected optimized policy to pull the best arm, got '%v'.", action.GetId())
+	}
+}
@@ -0,0 +1,114 @@
+package monoikos_test
+
+import (
+	"strconv"
+	"testing"
+
+	"github.com/tysont/monoikos"
+)
+
+func TestHiLoIsBalanced(t *testing.T) {
+
+	system := monoikos.NewHiLoSystem()
+	shoe := monoikos.NewShoe(2, 1.0)
+	for i := 0; i < 104; i++ {
+
+		shoe.Draw()
+	}
+
+	count := system.GetRunningCount(shoe.GetDealt())
+	if count != 0 {
+		t.Errorf("Expected the running count of a fully dealt shoe to be zero, got '%v'.", count)
+	}
+}
+
+func TestCustomCountingSystem(t *testing.T) {
+
+	system := monoikos.NewCountingSystem("Aces", map[int]int{1: -4})
+	shoe := monoikos.NewShoe(0, 1.0)
+	shoe.Cards = make([]int, 52)
+	for i := range shoe.Cards {
+
+		shoe.Cards[i] = 5
+	}
+
+	shoe.Cards[0] = 1
+	shoe.Cards[1] = 1
+	shoe.Draw()
+	shoe.Draw()
+
+	count := system.GetTrueCount(shoe)
+	if count != -8 {
+		t.Errorf("Expected two aces with a deck left to be a true count of -8, got '%v'.", count)
+	}
+}
+
+func TestCountingShoePersistsAcrossExperiments(t *testing.T) {
+
+	environment := monoikos.NewCountingBlackjackEnvironment(nil, nil)
+	policy := environment.CreateRandomPolicy()
+
+	state := environment.CreateExperiment().ObserveState()
+	if state.GetContext()[monoikos.BlackjackBettingContextKey] != "true" || len(environment.GetLegalActions(state)) != 4 {
+		t.Fatalf("Expected each round to start with a choice of bets, got '%v'.", state.GetId())
+	}
+
+	environment.CreateExperiment().Run(policy)
+	position := environment.Shoe.Position
+	if position < 4 {
+		t.Fatalf("Expected a round to deal at least four cards, got '%v'.", position)
+	}
+
+	environment.CreateExperiment().Run(policy)
+	if environment.Shoe.Position <= position {
+		t.Errorf("Expected the next round to carry on from the same shoe.")
+	}
+}
+
+func TestBetSizing(t *testing.T) {
+
+	// Player gets 10 and 10 against a dealer 17.
+	environment := monoikos.NewCountingBlackjackEnvironment(nil, nil)
+	environment.Shoe.Cards = []int{10, 10, 10, 7, 5, 5, 5, 5}
+	environment.Shoe.Position = 0
+
+	experiment := environment.CreateExperiment().(*monoikos.CountingBlackjackExperiment)
+	(&monoikos.BlackjackBetAction{Units: 4}).Run(experiment.Context)
+
+	state := experiment.ObserveState()
+	if state.GetContext()[monoikos.BlackjackCountContextKey] != "0" || state.GetContext()[monoikos.BlackjackPlayerContextKey] != "20" {
+		t.Fatalf("Expected a hand of 20 with the count from the time of the bet, got '%v'.", state.GetId())
+	}
+
+	(&monoikos.BlackjackAction{Move: monoikos.BlackjackStand}).Run(experiment.Context)
+	state = experiment.ObserveState()
+	if !state.IsTerminal() || state.GetReward() != 4*environment.Rules.Bet {
+		t.Errorf("Expected a win to pay four times the regular bet, got '%v'.", state.GetReward())
+	}
+}
+
+func TestOptimizeCountingBlackjackPolicy(t *testing.T) {
+
+	// A single deck dealt deep makes the count swing a long way, and a generous blackjack payout makes a
+	// high count worth betting big on while a low count still isn't, so the difference is easy to learn.
+	rules := monoikos.NewBlackjackRules()
+	rules.Decks = 1
+	rules.Penetration = 0.9
+	rules.BlackjackPayout = 8
+	environment := monoikos.NewCountingBlackjackEnvironment(rules, nil)
+	environment.BetSpread = []int{1, 8}
+	policy := environment.CreateOptimizedPolicy(40, 40000, 3)
+
+	expected := map[int]string{-environment.MaxTrueCount: "Bet 1", environment.MaxTrueCount: "Bet 8"}
+	for count, bet := range expected {
+
+		state := monoikos.NewBasicState()
+		state.Context[monoikos.BlackjackBettingContextKey] = strconv.FormatBool(true)
+		state.Context[monoikos.BlackjackCountContextKey] = strconv.Itoa(count)
+
+		action := policy.GetPreferredAction(state)
+		if action == nil || action.GetId() != bet {
+			t.Errorf("Expected optimized policy to '%v' at a true count of '%v', got '%v'.", bet, count, action)
+		}
+	}
+}
@@ -4,309 +4,260 @@ import (
 	"strconv"
 	"testing"
 
-	"github.com/tysont/blackjack"
 	"github.com/tysont/monoikos"
 )
 
-var idContextKey = "id"
-var playerContextKey = "player"
-var pairContextKey = "pair"
-var softContextKey = "soft"
-var dealerContextKey = "dealer"
+func createBlackjackState(player int, soft bool, dealer int, double bool, pair bool, surrender bool) *monoikos.BasicState {
+
+	state := monoikos.NewBasicState()
+	state.Context[monoikos.BlackjackPlayerContextKey] = strconv.Itoa(player)
+	state.Context[monoikos.BlackjackSoftContextKey] = strconv.FormatBool(soft)
+	state.Context[monoikos.BlackjackDealerContextKey] = strconv.Itoa(dealer)
+	state.Context[monoikos.BlackjackDoubleContextKey] = strconv.FormatBool(double)
+	state.Context[monoikos.BlackjackPairContextKey] = strconv.FormatBool(pair)
+	state.Context[monoikos.BlackjackSurrenderContextKey] = strconv.FormatBool(surrender)
+	state.Context[monoikos.BlackjackInsuranceContextKey] = strconv.FormatBool(false)
+	return state
+}
+
+func createBlackjackGame(rules *monoikos.BlackjackRules, cards ...int) *monoikos.BlackjackGame {
+
+	shoe := monoikos.NewShoe(0, 1.0)
+	shoe.Cards = cards
+	return monoikos.NewBlackjackGame(rules, shoe)
+}
 
 func TestGetThreeLegalActions(t *testing.T) {
 
-	state := monoikos.NewBasicState()
-	state.Context[playerContextKey] = "10"
-	state.Context[pairContextKey] = "true"
-	state.Context[softContextKey] = "false"
-	state.Context[dealerContextKey] = "15"
+	state := createBlackjackState(10, false, 5, true, false, false)
 
-	environment := BlackjackEnvironment{}
+	environment := monoikos.NewBlackjackEnvironment(nil)
 	actions := environment.GetLegalActions(state)
 	l := len(actions)
 
 	if l != 3 {
-		t.Errorf("Expected 3 legal actions for a pair of cards, got '%v'.", l)
+		t.Errorf("Expected 3 legal actions for a hand that can double, got '%v'.", l)
 	}
 }
 
 func TestGetTwoLegalActions(t *testing.T) {
 
-	state := monoikos.NewBasicState()
-	state.Context[playerContextKey] = "14"
-	state.Context[dealerContextKey] = "15"
-	state.Context[pairContextKey] = "false"
-	state.Context[softContextKey] = "false"
+	state := createBlackjackState(14, false, 5, false, false, false)
 
-	environment := BlackjackEnvironment{}
+	environment := monoikos.NewBlackjackEnvironment(nil)
 	actions := environment.GetLegalActions(state)
 	l := len(actions)
 
 	if l != 2 {
-		t.Errorf("Expected 2 legal actions for a non-pair of cards, got '%v'.", l)
+		t.Errorf("Expected 2 legal actions for a hand that can't double, got '%v'.", l)
 	}
 }
 
-func TestOptimizeBlackjackPolicy(t *testing.T) {
-
-	environment := new(BlackjackEnvironment)
-	policy := environment.CreateOptimizedPolicy(40, 100000, 5)
-
-	var state monoikos.State
-	var action monoikos.Action
+func TestGetFiveLegalActions(t *testing.T) {
 
-	state = monoikos.NewBasicState()
-	state.GetContext()[playerContextKey] = "5"
-	state.GetContext()[dealerContextKey] = "18"
-	state.GetContext()[pairContextKey] = "true"
-	state.GetContext()[softContextKey] = "false"
-
-	action = policy.GetPreferredAction(state)
-	if action.GetId() != "Hit" {
-		t.Errorf("Expected optimized policy to Hit on 5 against 18, got '%v'.", action.GetId())
-	}
+	state := createBlackjackState(16, false, 10, true, true, true)
 
-	state = monoikos.NewBasicState()
-	state.GetContext()[playerContextKey] = "20"
-	state.GetContext()[dealerContextKey] = "15"
-	state.GetContext()[pairContextKey] = "false"
-	state.GetContext()[softContextKey] = "false"
+	environment := monoikos.NewBlackjackEnvironment(nil)
+	actions := environment.GetLegalActions(state)
+	l := len(actions)
 
-	action = policy.GetPreferredAction(state)
-	if action.GetId() != "Stand" {
-		t.Errorf("Expected optimized policy to Stand on 20 against 15, got '%v'.", action.GetId())
+	if l != 5 {
+		t.Errorf("Expected 5 legal actions for a first pair that can surrender, got '%v'.", l)
 	}
-
-	/*
-		// Fails right now, need to debug.
-		state = monoikos.NewBasicState()
-		state.GetContext()[playerContextKey] = "11"
-		state.GetContext()[dealerContextKey] = "16"
-		state.GetContext()[pairContextKey] = "true"
-		state.GetContext()[softContextKey] = "true"
-
-		action = policy.GetPreferredAction(state)
-		if action.GetId() != "Double" {
-			t.Errorf("Expected optimized policy to Double on 11 against 16, got '%v'.", action.GetId())
-		}
-	*/
 }
 
-type BlackjackEnvironment struct{}
+func TestIsPair(t *testing.T) {
 
-func (this *BlackjackEnvironment) CreateRandomPolicy() monoikos.Policy {
-
-	return monoikos.CreateRandomPolicy(this)
-}
-
-func (this *BlackjackEnvironment) CreateImprovedPolicy(outcomes []monoikos.Outcome) monoikos.Policy {
-
-	return monoikos.CreateImprovedPolicy(this, outcomes)
-}
-
-func (this *BlackjackEnvironment) CreateOptimizedPolicy(initialRandomizationRate int, experimentsPerIteration int, iterations int) monoikos.Policy {
-
-	return monoikos.CreateOptimizedPolicy(this, initialRandomizationRate, experimentsPerIteration, iterations)
-}
-
-func (this *BlackjackEnvironment) CreateExperiment() monoikos.Experiment {
-
-	experiment := NewBlackjackExperiment()
-	id := blackjack.GetNextId()
-	experiment.Context[idContextKey] = id
-	blackjack.Deal(id)
-
-	return experiment
-}
-
-func (this *BlackjackEnvironment) GetLegalActions(state monoikos.State) []monoikos.Action {
-
-	s, ok := state.GetContext()[pairContextKey]
-	if !ok {
-		return make([]monoikos.Action, 0)
+	if !monoikos.IsPair([]int{8, 8}) || !monoikos.IsPair([]int{10, 10}) {
+		t.Errorf("Expected two cards of the same value to be a pair.")
 	}
 
-	b, err := strconv.ParseBool(s)
-	if err != nil {
-		return make([]monoikos.Action, 0)
+	if monoikos.IsPair([]int{8, 3}) || monoikos.IsPair([]int{4, 4, 4}) {
+		t.Errorf("Expected different cards, or more than two cards, not to be a pair.")
 	}
+}
 
-	var actions []monoikos.Action
-
-	if !b {
-
-		actions = make([]monoikos.Action, 2)
-		actions[0] = new(HitAction)
-		actions[1] = new(StandAction)
-
-	} else {
+func TestEvaluateBlackjackHand(t *testing.T) {
 
-		actions = make([]monoikos.Action, 3)
-		actions[0] = new(HitAction)
-		actions[1] = new(StandAction)
-		actions[2] = new(DoubleAction)
+	total, soft := monoikos.EvaluateBlackjackHand([]int{1, 6})
+	if total != 17 || !soft {
+		t.Errorf("Expected ace and six to be soft 17, got '%v' and '%v'.", total, soft)
 	}
 
-	return actions
+	total, soft = monoikos.EvaluateBlackjackHand([]int{1, 6, 10})
+	if total != 17 || soft {
+		t.Errorf("Expected ace, six and ten to be hard 17, got '%v' and '%v'.", total, soft)
+	}
 }
 
-func (this *BlackjackEnvironment) GetKnownStates() []monoikos.State {
-
-	states := make([]monoikos.State, 0)
-	for player := 2; player <= 21; player++ {
-		for dealer := 2; dealer <= 21; dealer++ {
-
-			for s := 0; s <= 1; s++ {
-				soft := s != 0
-
-				for p := 0; p <= 1; p++ {
-					pair := p != 0
+func TestDealerSoft17Rule(t *testing.T) {
 
-					state := monoikos.NewBasicState()
-					state.Context[playerContextKey] = strconv.Itoa(player)
-					state.Context[softContextKey] = strconv.FormatBool(soft)
-					state.Context[pairContextKey] = strconv.FormatBool(pair)
-					state.Context[dealerContextKey] = strconv.Itoa(dealer)
-					state.Terminal = false
+	// Player gets 10 and 8, dealer gets ace and 6 and then a 3 if it hits.
+	rules := monoikos.NewBlackjackRules()
+	game := createBlackjackGame(rules, 10, 1, 8, 6, 3)
+	game.DeclineInsurance()
+	game.Stand()
 
-					states = append(states, state)
-				}
-			}
-		}
+	if game.Payout != rules.Bet {
+		t.Errorf("Expected 18 to beat a dealer standing on soft 17, got '%v'.", game.Payout)
 	}
 
-	return states
-}
+	rules.DealerHitsSoft17 = true
+	game = createBlackjackGame(rules, 10, 1, 8, 6, 3)
+	game.DeclineInsurance()
+	game.Stand()
 
-type BlackjackExperiment struct {
-	Context map[string]interface{}
+	if game.Payout != -rules.Bet {
+		t.Errorf("Expected 18 to lose to a dealer hitting soft 17 to 20, got '%v'.", game.Payout)
+	}
 }
 
-func NewBlackjackExperiment() *BlackjackExperiment {
+func TestBlackjackPayout(t *testing.T) {
 
-	experiment := new(BlackjackExperiment)
-	experiment.Context = make(map[string]interface{})
+	rules := monoikos.NewBlackjackRules()
+	rules.BlackjackPayout = 1.2
+	game := createBlackjackGame(rules, 1, 10, 10, 7)
 
-	return experiment
+	if !game.Complete || game.Payout != 12 {
+		t.Errorf("Expected a blackjack to pay 6:5 straight away, got '%v'.", game.Payout)
+	}
 }
 
-func (this *BlackjackExperiment) ObserveState() monoikos.State {
+func TestSplitPlaysHandsInSequence(t *testing.T) {
 
-	game := blackjack.Peek(this.Context[idContextKey].(uint64))
+	// Player gets two eights against a dealer 17, and then draws a three and a ten on the split hands.
+	rules := monoikos.NewBlackjackRules()
+	game := createBlackjackGame(rules, 8, 10, 8, 7, 3, 10, 10)
+	experiment := monoikos.NewBlackjackExperiment(game)
 
-	state := monoikos.NewBasicState()
-
-	player, soft := blackjack.Evaluate(game.Player)
-	state.Context[playerContextKey] = strconv.Itoa(player)
-	state.Context[softContextKey] = strconv.FormatBool(soft)
-
-	pair := len(game.Player) == 2
-	state.Context[pairContextKey] = strconv.FormatBool(pair)
+	if experiment.ObserveState().GetContext()[monoikos.BlackjackPairContextKey] != "true" {
+		t.Fatalf("Expected two eights to be a pair that can be split.")
+	}
 
-	dealer, _ := blackjack.Evaluate(game.Dealer)
-	state.Context[dealerContextKey] = strconv.Itoa(dealer)
+	(&monoikos.BlackjackAction{Move: monoikos.BlackjackSplit}).Run(experiment.Context)
+	if len(game.Hands) != 2 || experiment.ObserveState().GetContext()[monoikos.BlackjackPlayerContextKey] != "11" {
+		t.Fatalf("Expected to be playing the first of two hands with 11, got '%v'.", experiment.ObserveState().GetId())
+	}
 
-	state.Terminal = game.Complete
-	state.Reward = game.Payout
+	(&monoikos.BlackjackAction{Move: monoikos.BlackjackDouble}).Run(experiment.Context)
+	if experiment.ObserveState().GetContext()[monoikos.BlackjackPlayerContextKey] != "18" {
+		t.Fatalf("Expected to move on to the second hand with 18, got '%v'.", experiment.ObserveState().GetId())
+	}
 
-	return state
+	(&monoikos.BlackjackAction{Move: monoikos.BlackjackStand}).Run(experiment.Context)
+	state := experiment.ObserveState()
+	if !state.IsTerminal() || state.GetReward() != 3*rules.Bet {
+		t.Errorf("Expected a doubled win and a regular win to pay three bets, got '%v'.", state.GetReward())
+	}
 }
 
-func (this *BlackjackExperiment) Run(policy monoikos.Policy) []monoikos.Outcome {
-
-	basicOutcomes := make([]*monoikos.BasicOutcome, 0)
-	state := this.ObserveState()
-	for !state.IsTerminal() {
-
-		action := policy.GetAction(state)
-		action.Run(this.Context)
-
-		outcome := new(monoikos.BasicOutcome)
-		outcome.InitialState = state
-		outcome.ActionTaken = action
-		basicOutcomes = append(basicOutcomes, outcome)
+func TestSurrender(t *testing.T) {
 
-		state = this.ObserveState()
+	rules := monoikos.NewBlackjackRules()
+	game := createBlackjackGame(rules, 10, 10, 6, 7)
+	if !game.CanSurrender() {
+		t.Fatalf("Expected to be able to surrender on the first two cards.")
 	}
 
-	outcomes := make([]monoikos.Outcome, 0)
-	for _, outcome := range basicOutcomes {
-
-		outcome.FinalState = state
-		outcomes = append(outcomes, outcome)
+	game.Surrender()
+	if !game.Complete || game.Payout != -rules.Bet/2 {
+		t.Errorf("Expected surrendering to lose half the bet, got '%v'.", game.Payout)
 	}
 
-	return outcomes
+	rules.LateSurrender = false
+	game = createBlackjackGame(rules, 10, 10, 6, 7)
+	if game.CanSurrender() {
+		t.Errorf("Expected not to be able to surrender when the rules don't allow it.")
+	}
 }
 
-func (this *BlackjackExperiment) ForceRun(action monoikos.Action, policy monoikos.Policy) []monoikos.Outcome {
-
-	basicOutcomes := make([]*monoikos.BasicOutcome, 0)
-	state := this.ObserveState()
-
-	action.Run(this.Context)
-	outcome := new(monoikos.BasicOutcome)
-	outcome.InitialState = state
-	outcome.ActionTaken = action
-	basicOutcomes = append(basicOutcomes, outcome)
-
-	state = this.ObserveState()
-	for !state.IsTerminal() {
+func TestInsurance(t *testing.T) {
 
-		action := policy.GetAction(state)
-		action.Run(this.Context)
-
-		outcome := new(monoikos.BasicOutcome)
-		outcome.InitialState = state
-		outcome.ActionTaken = action
-		basicOutcomes = append(basicOutcomes, outcome)
-
-		state = this.ObserveState()
+	// Dealer shows an ace with a ten underneath, against a player 19.
+	rules := monoikos.NewBlackjackRules()
+	game := createBlackjackGame(rules, 10, 1, 9, 10)
+	if !game.Insuring || game.CanDouble() {
+		t.Fatalf("Expected insurance to be offered before anything else.")
 	}
 
-	outcomes := make([]monoikos.Outcome, 0)
-	for _, outcome := range basicOutcomes {
+	game.TakeInsurance()
+	if !game.Complete || game.Payout != 0 {
+		t.Errorf("Expected insurance to cover the lost bet, got '%v'.", game.Payout)
+	}
 
-		outcome.FinalState = state
-		outcomes = append(outcomes, outcome)
+	game = createBlackjackGame(rules, 10, 1, 9, 10)
+	game.DeclineInsurance()
+	if !game.Complete || game.Payout != -rules.Bet {
+		t.Errorf("Expected the bet to be lost without insurance, got '%v'.", game.Payout)
 	}
 
-	return outcomes
+	// Dealer shows an ace with a seven underneath, so insurance is lost and the hand plays on.
+	game = createBlackjackGame(rules, 10, 1, 9, 7)
+	game.TakeInsurance()
+	game.Stand()
+	if game.Payout != rules.Bet-rules.Bet/2 {
+		t.Errorf("Expected to win the hand and lose the insurance, got '%v'.", game.Payout)
+	}
 }
 
-type HitAction struct{}
+func TestBlackjackExperimentsAreIndependent(t *testing.T) {
 
-func (this *HitAction) Run(context map[string]interface{}) {
+	environment := monoikos.NewBlackjackEnvironment(nil)
 
-	blackjack.Hit(context[idContextKey].(uint64))
-}
+	// Play rounds in parallel from the same environment, each with a policy of its own, so that
+	// anything they share other than the environment's rules would be raced over.
+	results := make(chan bool)
+	for i := 0; i < 4; i++ {
 
-func (this *HitAction) GetId() string {
+		policy := environment.CreateRandomPolicy()
+		go func() {
 
-	return "Hit"
-}
+			finished := true
+			for j := 0; j < 100; j++ {
 
-type DoubleAction struct{}
+				for _, outcome := range environment.CreateExperiment().Run(policy) {
 
-func (this *DoubleAction) Run(context map[string]interface{}) {
+					finished = finished && outcome.GetFinalState().IsTerminal()
+				}
+			}
 
-	blackjack.Double(context[idContextKey].(uint64))
-}
+			results <- finished
+		}()
+	}
 
-func (this *DoubleAction) GetId() string {
+	for i := 0; i < 4; i++ {
 
-	return "Double"
+		if !<-results {
+			t.Errorf("Expected every round to be played out to the end.")
+		}
+	}
 }
 
-type StandAction struct{}
+func TestOptimizeBlackjackPolicy(t *testing.T) {
 
-func (this *StandAction) Run(context map[string]interface{}) {
+	// Surrendering would split the first decision five ways, which makes it too noisy to check here.
+	rules := monoikos.NewBlackjackRules()
+	rules.LateSurrender = false
+	environment := monoikos.NewBlackjackEnvironment(rules)
+	policy := environment.CreateOptimizedPolicy(40, 100000, 5)
 
-	blackjack.Stand(context[idContextKey].(uint64))
-}
+	var action monoikos.Action
 
-func (this *StandAction) GetId() string {
+	action = policy.GetPreferredAction(createBlackjackState(11, false, 10, false, false, false))
+	if action.GetId() != "Hit" {
+		t.Errorf("Expected optimized policy to Hit on 11 against 10 once it can't double, got '%v'.", action.GetId())
+	}
 
-	return "Stand"
+	action = policy.GetPreferredAction(createBlackjackState(20, false, 6, false, false, false))
+	if action.GetId() != "Stand" {
+		t.Errorf("Expected optimized policy to Stand on 20 against 6, got '%v'.", action.GetId())
+	}
+
+	// Whether to double on any one hand is too close a call to learn every time, so check that the
+	// policy as a whole plays a lot better than a random one and loses less than a fifth of the bet.
+	evaluation := monoikos.EvaluatePolicy(environment, policy, 100000)
+	random := monoikos.EvaluatePolicy(environment, environment.CreateRandomPolicy(), 100000)
+	if evaluation.AverageReward < -float64(rules.Bet)/5 || evaluation.AverageReward < random.AverageReward+float64(rules.Bet)/5 {
+		t.Errorf("Expected optimized policy to lose less than '%v' a hand, and a lot less than '%v', got '%v'.", float64(rules.Bet)/5, -random.AverageReward, -evaluation.AverageReward)
+	}
 }
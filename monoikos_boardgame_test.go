@@ -0,0 +1,120 @@
+package monoikos_test
+
+import (
+	"testing"
+
+	"github.com/tysont/monoikos"
+)
+
+func createBoardState(board string, turn string) *monoikos.BasicState {
+
+	state := monoikos.NewBasicState()
+	state.Context[monoikos.BoardContextKey] = board
+	state.Context[monoikos.TurnContextKey] = turn
+	return state
+}
+
+func TestSolveTicTacToe(t *testing.T) {
+
+	game := monoikos.NewTicTacToe()
+	solver := monoikos.NewMinimaxSolver(game)
+
+	value := solver.GetValue(game.GetInitialState())
+	if value != 0 {
+		t.Errorf("Expected tic-tac-toe to be a draw with perfect play, got '%v'.", value)
+	}
+
+	// X can win across the top, and O has to block it.
+	actions := solver.GetBestActions(createBoardState("XX.OO....", "0"))
+	if len(actions) != 1 || actions[0].GetId() != "Cell 2" {
+		t.Errorf("Expected X to take the win, got '%v'.", actions)
+	}
+
+	actions = solver.GetBestActions(createBoardState("XX..O....", "1"))
+	if len(actions) != 1 || actions[0].GetId() != "Cell 2" {
+		t.Errorf("Expected O to block, got '%v'.", actions)
+	}
+}
+
+func TestMinimaxNeverLoses(t *testing.T) {
+
+	game := monoikos.NewTicTacToe()
+	solver := monoikos.NewMinimaxSolver(game)
+	minimax := solver.CreatePolicy()
+	random := monoikos.NewUniformRandomPolicy(game)
+
+	if !solver.IsNeverLosing(minimax, 0) || !solver.IsNeverLosing(minimax, 1) {
+		t.Fatalf("Expected perfect play never to lose from either seat.")
+	}
+
+	report := monoikos.EvaluateMatches(game, []monoikos.Policy{random, minimax}, 200)
+	if report.Wins[0] != 0 {
+		t.Errorf("Expected a random first player never to beat perfect play, won '%v'.", report.Wins[0])
+	}
+
+	report = monoikos.EvaluateMatches(game, []monoikos.Policy{minimax, minimax}, 20)
+	if report.Draws != 20 {
+		t.Errorf("Expected perfect play against itself to always draw, drew '%v'.", report.Draws)
+	}
+}
+
+func TestConnectFour(t *testing.T) {
+
+	game := monoikos.NewConnectFour(4, 4, 3)
+	state := game.GetInitialState()
+	for _, column := range []int{1, 1, 2, 2} {
+
+		state = game.GetNextState(state, &monoikos.ConnectFourAction{Column: column})
+	}
+
+	if state.GetContext()[monoikos.BoardContextKey] != ".XX..OO........." || state.IsTerminal() {
+		t.Fatalf("Expected counters to stack up in their columns, got '%v'.", state.GetId())
+	}
+
+	// The first player can win straight away on either side.
+	solver := monoikos.NewMinimaxSolver(game)
+	values := solver.GetActionValues(state)
+	if values["Column 0"] != 1 || values["Column 3"] != 1 {
+		t.Errorf("Expected winning moves on either side, got '%v'.", values)
+	}
+
+	state = game.GetNextState(state, &monoikos.ConnectFourAction{Column: 3})
+	if !state.IsTerminal() || game.GetRewards(state)[0] != 1 {
+		t.Errorf("Expected three in a row to win, got '%v'.", state.GetId())
+	}
+
+	// Three in a row on a small board is a win for the first player, while four in a row on a
+	// board of four by five is a draw.
+	if solver.GetValue(game.GetInitialState()) != 1 {
+		t.Errorf("Expected the first player to win three in a row on four by four.")
+	}
+
+	game = monoikos.NewConnectFour(4, 5, 4)
+	solver = monoikos.NewMinimaxSolver(game)
+	if solver.GetValue(game.GetInitialState()) != 0 {
+		t.Errorf("Expected four in a row on four by five to be a draw.")
+	}
+}
+
+func TestLearnedTicTacToeNeverLoses(t *testing.T) {
+
+	game := monoikos.NewTicTacToe()
+	solver := monoikos.NewMinimaxSolver(game)
+
+	random := monoikos.NewGameSeat(game, 0, nil).CreateRandomPolicy()
+	if solver.IsNeverLosing(random, 0) {
+		t.Fatalf("Expected a random policy to be beatable.")
+	}
+
+	learner := monoikos.NewMinimaxQ(game)
+	learner.Train(100000, 50)
+	policy := learner.CreatePolicy()
+
+	if !solver.IsNeverLosing(policy, 0) {
+		t.Errorf("Expected the learned policy never to lose as X, guaranteed '%v'.", solver.GetGuaranteedValue(policy, 0))
+	}
+
+	if !solver.IsNeverLosing(policy, 1) {
+		t.Errorf("Expected the learned policy never to lose as O, guaranteed '%v'.", solver.GetGuaranteedValue(policy, 1))
+	}
+}
@@ -95,6 +95,68 @@ func TestActionResults(t *testing.T) {
 	}
 }
 
+func TestOutcomesRecordNextState(t *testing.T) {
+
+	environment := new(CountEnvironment)
+	policy := environment.CreateRandomPolicy()
+
+	experiment := NewCountExperiment()
+	experiment.Context[countContextKey] = 1
+	outcomes := experiment.ForceRun(new(IncrementAction), policy)
+
+	for i, outcome := range outcomes {
+
+		if i < len(outcomes)-1 {
+
+			if monoikos.GetNextState(outcome).GetId() != outcomes[i+1].GetInitialState().GetId() {
+
+				t.Errorf("Expected next state '%v' to be the following initial state '%v'.", monoikos.GetNextState(outcome).GetId(), outcomes[i+1].GetInitialState().GetId())
+			}
+
+			if monoikos.GetImmediateReward(outcome) != 0 {
+
+				t.Errorf("Expected no immediate reward before the terminal step, got '%v'.", monoikos.GetImmediateReward(outcome))
+			}
+
+		} else if monoikos.GetNextState(outcome).GetId() != outcome.GetFinalState().GetId() || monoikos.GetImmediateReward(outcome) != outcome.GetReward() {
+
+			t.Errorf("Expected the last step to lead to the final state and pay out the reward.")
+		}
+	}
+}
+
+// minimalOutcome only implements the methods that Outcome requires.
+type minimalOutcome struct {
+	initial monoikos.State
+	final   monoikos.State
+}
+
+func (this *minimalOutcome) GetId() string                   { return this.initial.GetId() }
+func (this *minimalOutcome) GetReward() int                  { return this.final.GetReward() }
+func (this *minimalOutcome) GetInitialState() monoikos.State { return this.initial }
+func (this *minimalOutcome) GetFinalState() monoikos.State   { return this.final }
+
+func TestMinimalOutcomesStillWork(t *testing.T) {
+
+	first := monoikos.NewBasicState()
+	second := monoikos.NewBasicState()
+	final := monoikos.NewBasicState()
+	final.Terminal = true
+	final.Reward = 3
+
+	outcomes := []monoikos.Outcome{&minimalOutcome{first, final}, &minimalOutcome{second, final}}
+	if monoikos.GetActionTaken(outcomes[0]) != nil || monoikos.GetNextState(outcomes[0]) != nil || monoikos.GetImmediateReward(outcomes[0]) != 0 {
+
+		t.Errorf("Expected an outcome that can't say what happened next to report nothing.")
+	}
+
+	transitions := monoikos.GetTransitions(outcomes)
+	if transitions[0].NextState != second || transitions[1].NextState != final || transitions[1].Reward != 3 {
+
+		t.Errorf("Expected transitions to follow on from one outcome to the next.")
+	}
+}
+
 func TestCreatePolicyFromOutcomes(t *testing.T) {
 
 	environment := new(CountEnvironment)
@@ -157,6 +219,12 @@ func TestCreateOptimizedCountPolicy(t *testing.T) {
 	environment := new(CountEnvironment)
 	policy := environment.CreateOptimizedPolicy(40, 100000, 5)
 
+	solution := monoikos.SolveValueIteration(environment, environment)
+	agreement := solution.GetAgreement(policy)
+	if agreement < 0.8 {
+		t.Errorf("Expected optimized policy to mostly agree with the solved policy, got '%v'.", agreement)
+	}
+
 	var state monoikos.State
 	var action monoikos.Action
 
@@ -185,6 +253,41 @@ func TestCreateOptimizedCountPolicy(t *testing.T) {
 	*/
 }
 
+func TestSolveCountPolicy(t *testing.T) {
+
+	environment := new(CountEnvironment)
+	solutions := []*monoikos.Solution{
+		monoikos.SolveValueIteration(environment, environment),
+		monoikos.SolvePolicyIteration(environment, environment),
+		monoikos.NewSolver(environment, environment).ModifiedPolicyIteration(),
+	}
+
+	for _, solution := range solutions {
+
+		for i := 0; i <= max; i++ {
+
+			state := monoikos.NewBasicState()
+			state.GetContext()[countContextKey] = strconv.Itoa(i)
+			state.GetContext()[doneContextKey] = strconv.FormatBool(false)
+
+			expected := "Increment"
+			if i == max {
+
+				expected = "Stop"
+			}
+
+			action := solution.Policy.GetPreferredAction(state)
+			if action.GetId() != expected {
+				t.Errorf("Expected solved policy to %v on '%v', got '%v'.", expected, i, action.GetId())
+			}
+
+			if solution.Values[state.GetId()] != float64(max) {
+				t.Errorf("Expected value of '%v' to be '%v', got '%v'.", i, max, solution.Values[state.GetId()])
+			}
+		}
+	}
+}
+
 type CountEnvironment struct{}
 
 func (this *CountEnvironment) CreateRandomPolicy() monoikos.Policy {
@@ -240,6 +343,28 @@ func (this *CountEnvironment) GetKnownStates() []monoikos.State {
 	return states
 }
 
+func (this *CountEnvironment) GetSuccessors(state monoikos.State, action monoikos.Action) []monoikos.Successor {
+
+	count, _ := strconv.Atoi(state.GetContext()[countContextKey])
+
+	next := monoikos.NewBasicState()
+	next.Context[countContextKey] = strconv.Itoa(count)
+	next.Context[doneContextKey] = strconv.FormatBool(true)
+
+	if action.GetId() == "Increment" {
+
+		next.Context[countContextKey] = strconv.Itoa(count + 1)
+		next.Context[doneContextKey] = strconv.FormatBool(count+1 > max)
+	}
+
+	next.Terminal = next.Context[doneContextKey] == strconv.FormatBool(true)
+	SetReward(next)
+
+	successors := make([]monoikos.Successor, 1)
+	successors[0] = monoikos.Successor{State: next, Probability: 1.0, Reward: float64(next.Reward - state.GetReward())}
+	return successors
+}
+
 type CountExperiment struct {
 	Context map[string]interface{}
 }
@@ -290,66 +415,19 @@ func SetReward(state *monoikos.BasicState) {
 	}
 }
 
-func (this *CountExperiment) Run(policy monoikos.Policy) []monoikos.Outcome {
-
-	basicOutcomes := make([]*monoikos.BasicOutcome, 0)
-	state := this.ObserveState()
-	for !state.IsTerminal() {
-
-		action := policy.GetAction(state)
-		action.Run(this.Context)
-
-		outcome := new(monoikos.BasicOutcome)
-		outcome.InitialState = state
-		outcome.ActionTaken = action
-		basicOutcomes = append(basicOutcomes, outcome)
-
-		state = this.ObserveState()
-	}
+func (this *CountExperiment) Apply(action monoikos.Action) {
 
-	outcomes := make([]monoikos.Outcome, 0)
-	for _, outcome := range basicOutcomes {
+	action.Run(this.Context)
+}
 
-		outcome.FinalState = state
-		outcomes = append(outcomes, outcome)
-	}
+func (this *CountExperiment) Run(policy monoikos.Policy) []monoikos.Outcome {
 
-	return outcomes
+	return monoikos.NewEpisodeRunner().Run(this, policy)
 }
 
 func (this *CountExperiment) ForceRun(action monoikos.Action, policy monoikos.Policy) []monoikos.Outcome {
 
-	basicOutcomes := make([]*monoikos.BasicOutcome, 0)
-	state := this.ObserveState()
-
-	action.Run(this.Context)
-	outcome := new(monoikos.BasicOutcome)
-	outcome.InitialState = state
-	outcome.ActionTaken = action
-	basicOutcomes = append(basicOutcomes, outcome)
-
-	state = this.ObserveState()
-	for !state.IsTerminal() {
-
-		action := policy.GetAction(state)
-		action.Run(this.Context)
-
-		outcome := new(monoikos.BasicOutcome)
-		outcome.InitialState = state
-		outcome.ActionTaken = action
-		basicOutcomes = append(basicOutcomes, outcome)
-
-		state = this.ObserveState()
-	}
-
-	outcomes := make([]monoikos.Outcome, 0)
-	for _, outcome := range basicOutcomes {
-
-		outcome.FinalState = state
-		outcomes = append(outcomes, outcome)
-	}
-
-	return outcomes
+	return monoikos.NewEpisodeRunner().ForceRun(this, action, policy)
 }
 
 type IncrementAction struct{}
@@ -0,0 +1,168 @@
+package monoikos_test
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/tysont/monoikos"
+)
+
+type FaultyCountEnvironment struct {
+	CountEnvironment
+	Actions []monoikos.Action
+}
+
+func (this *FaultyCountEnvironment) CreateRandomPolicy() monoikos.Policy {
+
+	return monoikos.CreateRandomPolicy(this)
+}
+
+func (this *FaultyCountEnvironment) CreateImprovedPolicy(outcomes []monoikos.Outcome) monoikos.Policy {
+
+	return monoikos.CreateImprovedPolicy(this, outcomes)
+}
+
+func (this *FaultyCountEnvironment) CreateOptimizedPolicy(initialRandomizationRate int, experimentsPerIteration int, iterations int) monoikos.Policy {
+
+	return monoikos.CreateOptimizedPolicy(this, initialRandomizationRate, experimentsPerIteration, iterations)
+}
+
+func (this *FaultyCountEnvironment) GetLegalActions(state monoikos.State) []monoikos.Action {
+
+	return append([]monoikos.Action{}, this.Actions...)
+}
+
+type BrokenAction struct{}
+
+func (this *BrokenAction) Run(context map[string]interface{}) {
+
+	context[countContextKey] = context[countContextKey].(string) + "1"
+}
+
+func (this *BrokenAction) GetId() string {
+
+	return "Broken"
+}
+
+type RefusedAction struct{}
+
+func (this *RefusedAction) Run(context map[string]interface{}) {
+}
+
+func (this *RefusedAction) TryRun(context map[string]interface{}) error {
+
+	return errors.New("refused")
+}
+
+func (this *RefusedAction) GetId() string {
+
+	return "Refused"
+}
+
+func TestNoLegalActions(t *testing.T) {
+
+	environment := &FaultyCountEnvironment{Actions: []monoikos.Action{}}
+	policy := environment.CreateRandomPolicy().(*monoikos.BasicPolicy)
+
+	var noLegalActions *monoikos.NoLegalActionsError
+	_, err := policy.TryGetAction(environment.CreateExperiment().ObserveState())
+	if !errors.As(err, &noLegalActions) {
+		t.Fatalf("Expected a state without legal actions to be reported, got '%v'.", err)
+	}
+
+	_, err = monoikos.TryCreateOptimizedPolicy(environment, 40, 10, 2, monoikos.AbortOnFailure)
+	if !errors.As(err, &noLegalActions) {
+		t.Errorf("Expected training to stop with the missing actions, got '%v'.", err)
+	}
+
+	// Known states without legal actions are left out of improved policies rather than panicking.
+	policy = environment.CreateImprovedPolicy([]monoikos.Outcome{}).(*monoikos.BasicPolicy)
+	if len(policy.KnownStates) != 0 {
+		t.Errorf("Expected no states in the policy, got '%v'.", len(policy.KnownStates))
+	}
+}
+
+type SilentPolicy struct {
+	monoikos.Policy
+}
+
+func (this *SilentPolicy) TryGetAction(state monoikos.State) (monoikos.Action, error) {
+
+	return nil, nil
+}
+
+func TestSilentPolicy(t *testing.T) {
+
+	environment := new(CountEnvironment)
+	policy := &SilentPolicy{Policy: environment.CreateRandomPolicy()}
+
+	// A policy that can report failure but picks no action without saying why still has no legal actions.
+	var noLegalActions *monoikos.NoLegalActionsError
+	_, err := monoikos.TryRunExperiment(NewCountExperiment(), policy)
+	if !errors.As(err, &noLegalActions) {
+		t.Errorf("Expected a missing action to be reported, got '%v'.", err)
+	}
+}
+
+func TestFailedActions(t *testing.T) {
+
+	environment := &FaultyCountEnvironment{Actions: []monoikos.Action{new(IncrementAction), new(StopAction), new(BrokenAction)}}
+	policy := environment.CreateRandomPolicy()
+
+	// The broken action panics on the context, which comes back as an error.
+	failed := 0
+	for i := 0; i < 100; i++ {
+
+		_, err := monoikos.TryRunExperiment(environment.CreateExperiment(), policy)
+		var actionError *monoikos.ActionError
+		if errors.As(err, &actionError) && actionError.Action.GetId() == "Broken" {
+
+			failed++
+
+		} else if err != nil {
+
+			t.Fatalf("Expected only the broken action to fail, got '%v'.", err)
+		}
+	}
+
+	if failed == 0 {
+		t.Fatalf("Expected some experiments to take the broken action.")
+	}
+
+	_, err := monoikos.TryCreateOptimizedPolicy(environment, 40, 100, 3, monoikos.AbortOnFailure)
+	if err == nil {
+		t.Errorf("Expected aborting training to return the failure.")
+	}
+
+	policy, err = monoikos.TryCreateOptimizedPolicy(environment, 40, 100, 3, monoikos.SkipOnFailure)
+	if err != nil || policy == nil {
+		t.Errorf("Expected skipping failed experiments to still train a policy, got '%v'.", err)
+	}
+}
+
+func TestIllegalAndRefusedActions(t *testing.T) {
+
+	environment := new(CountEnvironment)
+	policy := environment.CreateRandomPolicy()
+
+	runner := monoikos.NewEpisodeRunner()
+	runner.Environment = environment
+
+	var illegal *monoikos.IllegalActionError
+	_, err := runner.TryForceRun(NewCountExperiment(), new(BrokenAction), policy)
+	if !errors.As(err, &illegal) || illegal.Action.GetId() != "Broken" {
+		t.Errorf("Expected an action that isn't legal to be refused, got '%v'.", err)
+	}
+
+	// Actions that report failure themselves pass on the reason.
+	err = monoikos.RunAction(new(RefusedAction), map[string]interface{}{})
+	var actionError *monoikos.ActionError
+	if !errors.As(err, &actionError) || actionError.Unwrap().Error() != "refused" {
+		t.Errorf("Expected the reason the action failed, got '%v'.", err)
+	}
+
+	_, err = monoikos.TryRunExperiment(monoikos.NewExperiment(NewCountExperiment()), policy)
+	if err != nil {
+		t.Errorf("Expected a normal experiment to succeed, got '%v'.", err)
+	}
+}
@@ -0,0 +1,119 @@
+package monoikos_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/tysont/monoikos"
+)
+
+type StartActionHook struct {
+	Actions map[string]bool
+}
+
+func (this *StartActionHook) OnStep(outcome monoikos.Outcome) {
+}
+
+func (this *StartActionHook) OnEpisode(outcomes []monoikos.Outcome) {
+
+	if len(outcomes) > 0 {
+
+		this.Actions[monoikos.GetActionTaken(outcomes[0]).GetId()] = true
+	}
+}
+
+func TestOptimisticValues(t *testing.T) {
+
+	mdp, err := monoikos.NewTabularMDPFromJSON([]byte(tabularJSON))
+	if err != nil {
+
+		t.Fatal(err)
+	}
+
+	start := mdp.CreateExperiment().ObserveState()
+	stop := mdp.GetLegalActions(start)[0]
+	rewards := map[string]float64{(&monoikos.BasicOutcome{InitialState: start, ActionTaken: stop}).GetId(): 1}
+
+	untried := monoikos.GetUntriedActions(mdp, start, rewards)
+	if len(untried) != 1 || untried[0].GetId() != "walk" {
+		t.Fatalf("Expected walking to be untried, got '%v'.", untried)
+	}
+
+	// Without optimism the untried action is never preferred.
+	policy := monoikos.CreatePolicyFromValues(mdp, rewards).(*monoikos.BasicPolicy)
+	if policy.GetPreferredAction(start).GetId() != "stop" || len(policy.UntriedActions[start.GetId()]) != 1 {
+		t.Errorf("Expected stopping to be preferred with walking untried.")
+	}
+
+	policy = monoikos.CreateExploringPolicyFromValues(mdp, rewards, monoikos.Exploration{Optimistic: true, OptimisticValue: 100}).(*monoikos.BasicPolicy)
+	if policy.GetPreferredAction(start).GetId() != "walk" {
+		t.Errorf("Expected an optimistic policy to prefer walking, got '%v'.", policy.GetPreferredAction(start).GetId())
+	}
+
+	policy = monoikos.CreateExploringPolicyFromValues(mdp, rewards, monoikos.Exploration{Optimistic: true, OptimisticValue: 0}).(*monoikos.BasicPolicy)
+	if policy.GetPreferredAction(start).GetId() != "stop" || len(policy.OtherActions[start.GetId()]) != 1 {
+		t.Errorf("Expected walking to be an other action when it isn't worth more.")
+	}
+}
+
+func TestUnexploredFirst(t *testing.T) {
+
+	mdp, err := monoikos.NewTabularMDPFromJSON([]byte(tabularJSON))
+	if err != nil {
+
+		t.Fatal(err)
+	}
+
+	start := mdp.CreateExperiment().ObserveState()
+	policy := monoikos.CreateExploringPolicyFromValues(mdp, map[string]float64{}, monoikos.Exploration{UnexploredFirst: true}).(*monoikos.BasicPolicy)
+
+	// Each untried action is taken once, however low the randomization rate.
+	policy.SetRandomizationRate(1)
+	taken := map[string]bool{}
+	for i := 0; i < 2; i++ {
+
+		taken[policy.GetAction(start).GetId()] = true
+	}
+
+	if len(taken) != 2 || len(policy.UntriedActions[start.GetId()]) != 0 {
+		t.Errorf("Expected both actions to be taken before anything else, got '%v'.", taken)
+	}
+
+	// A policy that isn't randomizing doesn't explore.
+	policy = monoikos.CreateExploringPolicyFromValues(mdp, map[string]float64{}, monoikos.Exploration{UnexploredFirst: true}).(*monoikos.BasicPolicy)
+	policy.SetRandomizationRate(0)
+	preferred := policy.GetPreferredAction(start).GetId()
+	for i := 0; i < 10; i++ {
+
+		if policy.GetAction(start).GetId() != preferred {
+			t.Fatalf("Expected a policy without randomization to stick to the preferred action.")
+		}
+	}
+}
+
+func TestOptimisticTraining(t *testing.T) {
+
+	mdp, err := monoikos.NewTabularMDPFromJSON([]byte(tabularJSON))
+	if err != nil {
+
+		t.Fatal(err)
+	}
+
+	// Without any randomization, greedy training only ever tries the first action it picks.
+	hook := &StartActionHook{Actions: map[string]bool{}}
+	config := monoikos.NewTrainingConfig()
+	config.InitialRandomizationRate = 0
+	config.ExperimentsPerIteration = 1
+	config.Hooks = []monoikos.EpisodeHook{hook}
+	monoikos.TrainPolicy(context.Background(), mdp, config)
+	if len(hook.Actions) != 1 {
+		t.Errorf("Expected greedy training to only try one action, tried '%v'.", hook.Actions)
+	}
+
+	hook.Actions = map[string]bool{}
+	config.Exploration = monoikos.Exploration{Optimistic: true, OptimisticValue: 100}
+	monoikos.TrainPolicy(context.Background(), mdp, config)
+	if len(hook.Actions) != 2 {
+		t.Errorf("Expected optimism to try both actions, tried '%v'.", hook.Actions)
+	}
+}
@@ -0,0 +1,168 @@
+package monoikos_test
+
+import (
+	"strconv"
+	"testing"
+
+	"github.com/tysont/monoikos"
+)
+
+// NimGame is a game of Nim for two players, where each player takes one to three stones from the pile
+// in turn and whoever takes the last stone wins.  The first player can always win from a pile that
+// isn't a multiple of four by leaving a multiple of four for the other player.
+type NimGame struct {
+	Stones int
+}
+
+func (this *NimGame) GetPlayers() int {
+
+	return 2
+}
+
+func (this *NimGame) CreateMatch() monoikos.Match {
+
+	match := new(NimMatch)
+	match.Stones = this.Stones
+	match.Rewards = make([]int, 2)
+	return match
+}
+
+func (this *NimGame) GetLegalActions(state monoikos.State) []monoikos.Action {
+
+	stones, _ := strconv.Atoi(state.GetContext()["stones"])
+	actions := make([]monoikos.Action, 0)
+	for i := 1; i <= 3 && i <= stones; i++ {
+
+		actions = append(actions, &NimAction{Take: i})
+	}
+
+	return actions
+}
+
+type NimMatch struct {
+	Stones  int
+	Player  int
+	Rewards []int
+}
+
+func (this *NimMatch) ObserveState() monoikos.State {
+
+	state := monoikos.NewBasicState()
+	state.Context["stones"] = strconv.Itoa(this.Stones)
+	state.Terminal = this.Stones == 0
+	return state
+}
+
+func (this *NimMatch) GetCurrentPlayer() int {
+
+	return this.Player
+}
+
+func (this *NimMatch) GetRewards() []int {
+
+	return this.Rewards
+}
+
+func (this *NimMatch) Play(action monoikos.Action) {
+
+	this.Stones -= action.(*NimAction).Take
+	if this.Stones == 0 {
+
+		this.Rewards[this.Player] = 1
+		this.Rewards[1-this.Player] = -1
+		return
+	}
+
+	this.Player = 1 - this.Player
+}
+
+type NimAction struct {
+	Take int
+}
+
+func (this *NimAction) GetId() string {
+
+	return "Take " + strconv.Itoa(this.Take)
+}
+
+func (this *NimAction) Run(context map[string]interface{}) {
+}
+
+func createNimState(stones int) *monoikos.BasicState {
+
+	state := monoikos.NewBasicState()
+	state.Context["stones"] = strconv.Itoa(stones)
+	return state
+}
+
+func TestPlayMatchRecordsEveryPlayer(t *testing.T) {
+
+	game := &NimGame{Stones: 7}
+	random := monoikos.NewUniformRandomPolicy(game)
+	outcomes, rewards := monoikos.PlayMatch(game.CreateMatch(), []monoikos.Policy{random, random})
+
+	if rewards[0]+rewards[1] != 0 || rewards[0] == 0 {
+		t.Fatalf("Expected one player to win and the other to lose, got '%v'.", rewards)
+	}
+
+	for p := range outcomes {
+
+		for i, outcome := range outcomes[p] {
+
+			if outcome.GetReward() != rewards[p] {
+				t.Errorf("Expected every move to be credited with the player's own reward, got '%v'.", outcome.GetReward())
+			}
+
+			if i+1 < len(outcomes[p]) && monoikos.GetNextState(outcome) != outcomes[p][i+1].GetInitialState() {
+				t.Errorf("Expected the next state to be the player's next turn.")
+			}
+		}
+	}
+}
+
+func TestEvaluateMatches(t *testing.T) {
+
+	game := &NimGame{Stones: 1}
+	random := monoikos.NewUniformRandomPolicy(game)
+	report := monoikos.EvaluateMatches(game, []monoikos.Policy{random, random}, 10)
+
+	if report.Wins[0] != 10 || report.Rewards[1] != -1 {
+		t.Errorf("Expected the first player to always take the only stone, got '%v'.", report.Wins)
+	}
+}
+
+func TestSelfPlay(t *testing.T) {
+
+	game := &NimGame{Stones: 7}
+	selfPlay := monoikos.NewSelfPlay(game)
+	policies := selfPlay.Train(40, 2000, 8)
+
+	for _, stones := range []int{7, 3, 2, 1} {
+
+		action := policies[0].GetPreferredAction(createNimState(stones))
+		expected := "Take " + strconv.Itoa(stones%4)
+		if action == nil || action.GetId() != expected {
+			t.Errorf("Expected self play to leave a multiple of four stones from '%v', got '%v'.", stones, action)
+		}
+	}
+
+	random := monoikos.NewUniformRandomPolicy(game)
+	report := monoikos.EvaluateMatches(game, []monoikos.Policy{policies[0], random}, 1000)
+	if report.Wins[0] != 1000 {
+		t.Errorf("Expected the first player to win every match against a random opponent, won '%v'.", report.Wins[0])
+	}
+}
+
+func TestOptimizeSeatAgainstFixedOpponent(t *testing.T) {
+
+	// The second player can only win from eight stones if the first player gets it wrong.
+	game := &NimGame{Stones: 8}
+	random := monoikos.NewUniformRandomPolicy(game)
+	seat := monoikos.NewGameSeat(game, 1, []monoikos.Policy{random, nil})
+	policy := seat.CreateOptimizedPolicy(40, 2000, 5)
+
+	report := monoikos.EvaluateMatches(game, []monoikos.Policy{random, policy}, 1000)
+	if report.Wins[1] < 900 {
+		t.Errorf("Expected the second player to beat a random first player nearly every time, won '%v'.", report.Wins[1])
+	}
+}
@@ -0,0 +1,145 @@
+package monoikos_test
+
+import (
+	"errors"
+	"math/rand"
+	"testing"
+
+	"github.com/tysont/monoikos"
+)
+
+type Counter struct {
+	Count int
+	Done  bool
+}
+
+type CounterMove string
+
+const (
+	CounterIncrement CounterMove = "Increment"
+	CounterStop      CounterMove = "Stop"
+)
+
+type CounterEnvironment struct{}
+
+func (this *CounterEnvironment) CreateExperiment() monoikos.TypedExperiment[Counter, CounterMove] {
+
+	return &CounterExperiment{Counter: Counter{Count: rand.Intn(max)}}
+}
+
+func (this *CounterEnvironment) GetLegalActions(state Counter) []CounterMove {
+
+	return []CounterMove{CounterIncrement, CounterStop}
+}
+
+func (this *CounterEnvironment) GetKnownStates() []Counter {
+
+	states := make([]Counter, 0)
+	for i := 0; i <= max; i++ {
+
+		states = append(states, Counter{Count: i})
+	}
+
+	return states
+}
+
+type CounterExperiment struct {
+	Counter Counter
+}
+
+func (this *CounterExperiment) ObserveState() Counter {
+
+	return this.Counter
+}
+
+func (this *CounterExperiment) IsTerminal() bool {
+
+	return this.Counter.Done
+}
+
+func (this *CounterExperiment) GetReward() int {
+
+	if !this.Counter.Done {
+
+		return 0
+
+	} else if this.Counter.Count > max {
+
+		return -1
+	}
+
+	return this.Counter.Count
+}
+
+func (this *CounterExperiment) Apply(move CounterMove) error {
+
+	switch move {
+	case CounterIncrement:
+		this.Counter.Count++
+		this.Counter.Done = this.Counter.Count > max
+	case CounterStop:
+		this.Counter.Done = true
+	default:
+		return errors.New("unknown move")
+	}
+
+	return nil
+}
+
+func TestOptimizeTypedPolicy(t *testing.T) {
+
+	adapter := monoikos.NewEnvironmentAdapter[Counter, CounterMove](new(CounterEnvironment))
+	if len(adapter.GetKnownStates()) != max+1 {
+		t.Fatalf("Expected the listed states to be known, got '%v'.", len(adapter.GetKnownStates()))
+	}
+
+	policy := adapter.CreateTypedPolicy(adapter.CreateOptimizedPolicy(40, 20000, 5))
+	for i := 1; i < max-1; i++ {
+
+		move, ok := policy.GetPreferredAction(Counter{Count: i})
+		if !ok || move != CounterIncrement {
+			t.Errorf("Expected the typed policy to increment on '%v', got '%v'.", i, move)
+		}
+	}
+
+	if policy.GetAction(Counter{Count: 1}) != CounterIncrement {
+		t.Errorf("Expected a policy without randomization to increment.")
+	}
+}
+
+func TestTypedExperimentErrors(t *testing.T) {
+
+	adapter := monoikos.NewEnvironmentAdapter[Counter, CounterMove](new(CounterEnvironment))
+	experiment := adapter.CreateExperiment()
+	policy := adapter.CreateRandomPolicy()
+
+	var actionError *monoikos.ActionError
+	_, err := monoikos.TryForceRunExperiment(experiment, &monoikos.TypedAction[CounterMove]{Value: "Jump"}, policy)
+	if !errors.As(err, &actionError) || actionError.Unwrap().Error() != "unknown move" {
+		t.Errorf("Expected the typed experiment's error to be passed on, got '%v'.", err)
+	}
+
+	var illegal *monoikos.IllegalActionError
+	_, err = monoikos.TryForceRunExperiment(experiment, new(StopAction), policy)
+	if !errors.As(err, &illegal) {
+		t.Errorf("Expected an untyped action to be refused, got '%v'.", err)
+	}
+
+	outcomes := adapter.CreateExperiment().ForceRun(&monoikos.TypedAction[CounterMove]{Value: CounterStop}, policy)
+	state, ok := adapter.GetTypedState(outcomes[0].GetInitialState())
+	if len(outcomes) != 1 || !ok || outcomes[0].GetReward() != state.Count {
+		t.Errorf("Expected stopping to pay out the count.")
+	}
+
+	// A state that doesn't hold a typed state has no legal actions, so policies can't act on it.
+	unknown := monoikos.NewBasicState()
+	if _, ok := adapter.GetTypedState(unknown); ok {
+		t.Errorf("Expected a state that isn't typed not to be matched.")
+	}
+
+	var noLegalActions *monoikos.NoLegalActionsError
+	_, err = policy.(monoikos.FalliblePolicy).TryGetAction(unknown)
+	if !errors.As(err, &noLegalActions) {
+		t.Errorf("Expected a policy to refuse a state that isn't typed, got '%v'.", err)
+	}
+}
@@ -0,0 +1,103 @@
+package monoikos_test
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/tysont/monoikos"
+)
+
+var gridworldMap = `
+S..G
+.#.P
+....
+`
+
+func TestParseGridworld(t *testing.T) {
+
+	gridworld, err := monoikos.NewGridworld(gridworldMap)
+	if err != nil {
+
+		t.Fatal(err)
+	}
+
+	if gridworld.Render() != "S..G\n.#.P\n....\n" {
+
+		t.Errorf("Expected rendered map to match the original, got '%v'.", gridworld.Render())
+	}
+
+	_, err = monoikos.NewGridworld("S..\n.#")
+	if err == nil {
+
+		t.Errorf("Expected a map that isn't rectangular to be rejected.")
+	}
+
+	_, err = monoikos.NewGridworld("S..\n...")
+	if err == nil {
+
+		t.Errorf("Expected a map without a goal to be rejected.")
+	}
+
+	_, err = monoikos.NewGridworld("G#\n#P")
+	if err == nil {
+
+		t.Errorf("Expected a map with nowhere to start to be rejected.")
+	}
+}
+
+func TestSolveGridworld(t *testing.T) {
+
+	gridworld, err := monoikos.NewGridworld(gridworldMap)
+	if err != nil {
+
+		t.Fatal(err)
+	}
+
+	solution := monoikos.SolveValueIteration(gridworld, gridworld)
+	rendered := gridworld.RenderPolicy(solution.Policy)
+	if !strings.HasPrefix(rendered, ">>>G\n") {
+
+		t.Errorf("Expected solved policy to head straight for the goal, got:\n%v", rendered)
+	}
+}
+
+func TestSolveSlipperyGridworld(t *testing.T) {
+
+	gridworld, err := monoikos.NewGridworld(`
+		.~~~G
+		SPPP.
+		.....
+	`)
+	if err != nil {
+
+		t.Fatal(err)
+	}
+
+	// With ice that nearly always slips, the safe way round is better than the short way across.
+	gridworld.SlipProbability = 1.0
+	gridworld.PitReward = -100
+	solution := monoikos.SolveValueIteration(gridworld, gridworld)
+	rendered := gridworld.RenderPolicy(solution.Policy)
+	if strings.Split(rendered, "\n")[1][0] != 'v' {
+
+		t.Errorf("Expected solved policy to avoid the ice, got:\n%v", rendered)
+	}
+}
+
+func TestOptimizeGridworldPolicy(t *testing.T) {
+
+	// Without a start tile experiments start anywhere, so every tile gets explored.
+	gridworld, err := monoikos.NewGridworld(strings.Replace(gridworldMap, "S", ".", 1))
+	if err != nil {
+
+		t.Fatal(err)
+	}
+
+	solution := monoikos.SolveValueIteration(gridworld, gridworld)
+	policy := gridworld.CreateOptimizedPolicy(40, 5000, 5)
+	agreement := solution.GetAgreement(policy)
+	if agreement < 0.8 {
+
+		t.Errorf("Expected optimized policy to mostly agree with the solved policy, got '%v':\n%v", agreement, gridworld.RenderPolicy(policy))
+	}
+}
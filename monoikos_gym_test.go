@@ -0,0 +1,188 @@
+package monoikos_test
+
+import (
+	"errors"
+	"strconv"
+	"testing"
+
+	"github.com/tysont/monoikos"
+)
+
+// Corridor is a gym environment where the agent starts at zero and walks left or right along a
+// corridor.  Every step costs one, reaching three pays ten and ends the episode, and the episode is
+// cut short after ten steps.
+type Corridor struct {
+	Position int
+	Steps    int
+}
+
+// Reset starts at zero.
+func (this *Corridor) Reset() (monoikos.State, map[string]interface{}) {
+
+	this.Position = 0
+	this.Steps = 0
+	return this.observe(), map[string]interface{}{}
+}
+
+// Step walks left or right.
+func (this *Corridor) Step(action monoikos.Action) *monoikos.GymStep {
+
+	switch action.GetId() {
+	case "left":
+		this.Position--
+	case "right":
+		this.Position++
+	default:
+		panic("can't " + action.GetId())
+	}
+
+	this.Steps++
+	step := &monoikos.GymStep{Observation: this.observe(), Reward: -1, Info: map[string]interface{}{"steps": this.Steps}}
+	if this.Position == 3 {
+
+		step.Reward += 10
+		step.Terminated = true
+	}
+
+	step.Truncated = this.Steps >= 10
+	return step
+}
+
+// GetLegalActions returns left and right.
+func (this *Corridor) GetLegalActions(state monoikos.State) []monoikos.Action {
+
+	return []monoikos.Action{&CorridorMove{Direction: "left"}, &CorridorMove{Direction: "right"}}
+}
+
+// CorridorMove is a move along the corridor, which is taken by the corridor rather than run.
+type CorridorMove struct {
+	Direction string
+}
+
+// GetId returns the direction.
+func (this *CorridorMove) GetId() string {
+
+	return this.Direction
+}
+
+// Run does nothing.
+func (this *CorridorMove) Run(context map[string]interface{}) {
+}
+
+// observe returns the position as an observation.
+func (this *Corridor) observe() monoikos.State {
+
+	state := monoikos.NewBasicState()
+	state.Context["position"] = strconv.Itoa(this.Position)
+	return state
+}
+
+func TestGymAdapter(t *testing.T) {
+
+	environment := monoikos.NewGymAdapter(func() monoikos.GymEnvironment { return new(Corridor) })
+	policy := environment.CreateOptimizedPolicy(40, 500, 5)
+	outcomes := environment.CreateExperiment().Run(policy)
+	if len(outcomes) != 3 || outcomes[0].GetReward() != 7 || monoikos.IsTruncated(outcomes[0]) {
+		t.Errorf("Expected to walk straight to the end, got '%v' outcomes.", len(outcomes))
+	}
+
+	// Walking the wrong way is cut short by the corridor rather than the runner.
+	left := monoikos.NewBasicPolicy()
+	for position := -10; position < 3; position++ {
+
+		state := monoikos.NewBasicState()
+		state.Context["position"] = strconv.Itoa(position)
+		left.AddState(state, &CorridorMove{Direction: "left"}, []monoikos.Action{})
+	}
+
+	outcomes = environment.CreateExperiment().Run(left)
+	if len(outcomes) != 10 || outcomes[0].GetReward() != -10 || !monoikos.IsTruncated(outcomes[0]) {
+		t.Errorf("Expected to be cut short after ten steps, got '%v' outcomes.", len(outcomes))
+	}
+
+	_, err := monoikos.TryForceRunExperiment(environment.CreateExperiment(), &CorridorMove{Direction: "jump"}, policy)
+	actionError := new(monoikos.ActionError)
+	if !errors.As(err, &actionError) {
+		t.Errorf("Expected an action error for a step that panics, got '%v'.", err)
+	}
+}
+
+func TestExperimentGym(t *testing.T) {
+
+	mdp, err := monoikos.NewTabularMDPFromJSON([]byte(tabularJSON))
+	if err != nil {
+
+		t.Fatal(err)
+	}
+
+	gym := monoikos.NewExperimentGym(mdp)
+	observation, info := gym.Reset()
+	if observation.GetContext()["state"] != "start" || len(info["legal_actions"].([]monoikos.Action)) != 2 {
+		t.Errorf("Expected to start at the start with two legal actions, got '%v' '%v'.", observation.GetId(), info)
+	}
+
+	actions := make(map[string]monoikos.Action)
+	for _, action := range mdp.GetLegalActions(observation) {
+
+		actions[action.GetId()] = action
+	}
+
+	step := gym.Step(actions["walk"])
+	if step.Observation.GetContext()["state"] != "middle" || step.Reward != 0 || step.Terminated || step.Truncated {
+		t.Errorf("Expected to walk to the middle, got '%v' '%v'.", step.Observation.GetId(), step.Reward)
+	}
+
+	_, err = gym.TryStep(actions["walk"])
+	illegal := new(monoikos.IllegalActionError)
+	if !errors.As(err, &illegal) {
+		t.Errorf("Expected walking from the middle to be illegal, got '%v'.", err)
+	}
+
+	for _, action := range mdp.GetLegalActions(step.Observation) {
+
+		actions[action.GetId()] = action
+	}
+
+	step = gym.Step(actions["stop"])
+	if step.Reward != 3 || !step.Terminated || step.Truncated {
+		t.Errorf("Expected stopping in the middle to pay three and end the episode, got '%v'.", step.Reward)
+	}
+
+	_, err = gym.TryStep(actions["stop"])
+	if err == nil {
+		t.Errorf("Expected an error for stepping after the episode is over.")
+	}
+
+	// A step limit truncates the episode.
+	gym.MaxSteps = 1
+	gym.Reset()
+	step = gym.Step(actions["walk"])
+	if !step.Truncated || step.Terminated {
+		t.Errorf("Expected the episode to be truncated after a step.")
+	}
+
+	// Experiments that can't be stepped, such as games, can't be reset.
+	game := monoikos.NewTicTacToe()
+	random := monoikos.NewUniformRandomPolicy(game)
+	_, _, err = monoikos.NewExperimentGym(monoikos.NewGameSeat(game, 0, []monoikos.Policy{random, random})).TryReset()
+	if err == nil {
+		t.Errorf("Expected an error for resetting a gym whose experiments can't be stepped.")
+	}
+}
+
+func TestGymRoundTrip(t *testing.T) {
+
+	mdp, err := monoikos.NewTabularMDPFromJSON([]byte(tabularJSON))
+	if err != nil {
+
+		t.Fatal(err)
+	}
+
+	// Train the tabular MDP thru both adapters, and it should still walk and then gamble.
+	environment := monoikos.NewGymAdapter(func() monoikos.GymEnvironment { return monoikos.NewExperimentGym(mdp) })
+	policy := environment.CreateOptimizedPolicy(40, 1000, 5)
+	outcomes := environment.CreateExperiment().Run(policy)
+	if len(outcomes) < 2 || monoikos.GetActionTaken(outcomes[0]).GetId() != "walk" || monoikos.GetActionTaken(outcomes[1]).GetId() != "gamble" {
+		t.Errorf("Expected to walk and then gamble.")
+	}
+}
@@ -0,0 +1,101 @@
+package monoikos_test
+
+import (
+	"strconv"
+	"testing"
+
+	"github.com/tysont/monoikos"
+)
+
+func TestEmpiricalModelEstimatesProbabilities(t *testing.T) {
+
+	s1 := monoikos.NewBasicState()
+	s1.Context[countContextKey] = "1"
+
+	s2 := monoikos.NewBasicState()
+	s2.Context[countContextKey] = "2"
+
+	s3 := monoikos.NewBasicState()
+	s3.Context[countContextKey] = "3"
+	s3.Terminal = true
+	s3.Reward = 4
+
+	model := monoikos.NewEmpiricalModel()
+	action := new(IncrementAction)
+	for i := 0; i < 4; i++ {
+
+		transition := &monoikos.Transition{State: s1, Action: action, NextState: s2}
+		if i == 0 {
+
+			transition = &monoikos.Transition{State: s1, Action: action, Reward: 4, NextState: s3, Terminal: true}
+		}
+
+		model.Observe(transition)
+	}
+
+	for _, successor := range model.GetSuccessors(s1, action) {
+
+		if successor.State.GetId() == s2.GetId() && successor.Probability != 0.75 {
+
+			t.Errorf("Expected probability of 0.75 for '%v', got '%v'.", s2.GetId(), successor.Probability)
+		}
+
+		if successor.State.GetId() == s3.GetId() && successor.Probability != 0.25 {
+
+			t.Errorf("Expected probability of 0.25 for '%v', got '%v'.", s3.GetId(), successor.Probability)
+		}
+	}
+
+	if model.GetExpectedReward(s1, action) != 1.0 {
+
+		t.Errorf("Expected an expected reward of 1, got '%v'.", model.GetExpectedReward(s1, action))
+	}
+
+	if model.Sample(s1, new(StopAction)) != nil {
+
+		t.Errorf("Expected no simulated transition for an action that was never observed.")
+	}
+}
+
+func TestEmpiricalModelSamplesAverageRewards(t *testing.T) {
+
+	s1 := monoikos.NewBasicState()
+	s1.Context[countContextKey] = "1"
+
+	s2 := monoikos.NewBasicState()
+	s2.Context[countContextKey] = "2"
+
+	// Rewards of 1 and 2 for the same successor average out to 1.5, which rounds up rather than down.
+	model := monoikos.NewEmpiricalModel()
+	action := new(IncrementAction)
+	model.Observe(&monoikos.Transition{State: s1, Action: action, Reward: 1, NextState: s2})
+	model.Observe(&monoikos.Transition{State: s1, Action: action, Reward: 2, NextState: s2})
+
+	if model.GetSuccessors(s1, action)[0].Reward != 1.5 {
+
+		t.Errorf("Expected an average reward of 1.5, got '%v'.", model.GetSuccessors(s1, action)[0].Reward)
+	}
+
+	if model.Sample(s1, action).Reward != 2 {
+
+		t.Errorf("Expected a simulated reward of 2, got '%v'.", model.Sample(s1, action).Reward)
+	}
+}
+
+func TestCreateDynaQCountPolicy(t *testing.T) {
+
+	environment := new(CountEnvironment)
+	policy := monoikos.CreateDynaQPolicy(environment, 40, 2000, 5, 10)
+
+	for i := 1; i < max/2; i++ {
+
+		state := monoikos.NewBasicState()
+		state.GetContext()[countContextKey] = strconv.Itoa(i)
+		state.GetContext()[doneContextKey] = strconv.FormatBool(false)
+
+		action := policy.GetPreferredAction(state)
+		if action.GetId() != "Increment" {
+			t.Errorf("Expected Dyna-Q policy to Increment on '%v', got '%v'.", i, action.GetId())
+		}
+	}
+}
@@ -0,0 +1,126 @@
+package monoikos_test
+
+import (
+	"math"
+	"testing"
+
+	"github.com/tysont/monoikos"
+)
+
+func createTigerObservation(heard string) *monoikos.BasicState {
+
+	state := monoikos.NewBasicState()
+	state.Context[monoikos.TigerHeardContextKey] = heard
+	return state
+}
+
+func TestObservationHidesState(t *testing.T) {
+
+	tiger := monoikos.NewTiger()
+	experiment := tiger.CreateExperiment().(monoikos.PartiallyObservableExperiment)
+
+	if _, ok := experiment.ObserveState().GetContext()[monoikos.TigerSideContextKey]; ok {
+		t.Errorf("Expected the side the tiger is on to be hidden from the observation.")
+	}
+
+	if _, ok := experiment.ObserveHiddenState().GetContext()[monoikos.TigerSideContextKey]; !ok {
+		t.Errorf("Expected the side the tiger is on to be part of the hidden state.")
+	}
+
+	game := createBlackjackGame(monoikos.NewBlackjackRules(), 10, 5, 8, 9)
+	blackjack := monoikos.NewBlackjackExperiment(game)
+	if blackjack.ObserveHiddenState().GetContext()[monoikos.BlackjackHoleContextKey] != "9" {
+		t.Errorf("Expected the dealer hole card in the hidden blackjack state, got '%v'.", blackjack.ObserveHiddenState().GetId())
+	}
+}
+
+func TestBayesianBelief(t *testing.T) {
+
+	tiger := monoikos.NewTiger()
+	hidden := tiger.GetHiddenStates()
+	belief := monoikos.NewBayesianBelief(tiger, tiger, hidden)(createTigerObservation(monoikos.TigerNone)).(*monoikos.BayesianBelief)
+
+	if belief.GetProbability(hidden[0]) != 0.5 {
+		t.Fatalf("Expected to start out not knowing where the tiger is, got '%v'.", belief.GetProbability(hidden[0]))
+	}
+
+	listen := &monoikos.TigerAction{Move: monoikos.TigerListen}
+	belief.Update(listen, createTigerObservation(monoikos.TigerLeft))
+	belief.Update(listen, createTigerObservation(monoikos.TigerLeft))
+
+	// After hearing it on the left twice, the odds are 0.85^2 to 0.15^2.
+	p := belief.GetProbability(hidden[0])
+	expected := 0.85 * 0.85 / (0.85*0.85 + 0.15*0.15)
+	if math.Abs(p-expected) > 1e-9 {
+		t.Errorf("Expected the tiger to be on the left with probability '%v', got '%v'.", expected, p)
+	}
+
+	belief.Update(listen, createTigerObservation(monoikos.TigerRight))
+	if math.Abs(belief.GetProbability(hidden[0])-0.85) > 1e-9 {
+		t.Errorf("Expected hearing it on the right to cancel out one of the lefts, got '%v'.", belief.GetProbability(hidden[0]))
+	}
+}
+
+func TestObservationHistory(t *testing.T) {
+
+	history := monoikos.NewObservationHistory(2)(createTigerObservation(monoikos.TigerNone))
+	listen := &monoikos.TigerAction{Move: monoikos.TigerListen}
+	history.Update(listen, createTigerObservation(monoikos.TigerLeft))
+	history.Update(listen, createTigerObservation(monoikos.TigerRight))
+
+	context := history.GetState().GetContext()
+	if context["0."+monoikos.TigerHeardContextKey] != monoikos.TigerRight || context["1."+monoikos.TigerHeardContextKey] != monoikos.TigerLeft {
+		t.Errorf("Expected the last two observations in order, got '%v'.", history.GetState().GetId())
+	}
+
+	if context["0.action"] != monoikos.TigerListen || len(context) != 4 {
+		t.Errorf("Expected the window to hold two observations and the actions that led to them, got '%v'.", history.GetState().GetId())
+	}
+}
+
+func TestBeliefEnvironmentOutcomes(t *testing.T) {
+
+	tiger := monoikos.NewTiger()
+	environment := monoikos.NewBeliefEnvironment(tiger, monoikos.NewObservationHistory(3))
+	policy := environment.CreateRandomPolicy()
+
+	for i := 0; i < 100; i++ {
+
+		outcomes := environment.CreateExperiment().ForceRun(&monoikos.TigerAction{Move: monoikos.TigerListen}, policy)
+		for j, outcome := range outcomes {
+
+			if _, ok := outcome.GetInitialState().(*monoikos.SummaryState); !ok {
+				t.Fatalf("Expected outcomes to be recorded in terms of summaries.")
+			}
+
+			if j > 0 && monoikos.GetNextState(outcomes[j-1]) != outcome.GetInitialState() {
+				t.Fatalf("Expected each summary to follow on from the last.")
+			}
+		}
+
+		final := outcomes[len(outcomes)-1].GetFinalState()
+		if !final.IsTerminal() || final.GetContext()["0.action"] == monoikos.TigerListen {
+			t.Errorf("Expected the final summary to follow opening a door, got '%v'.", final.GetId())
+		}
+	}
+}
+
+func TestOptimizeTigerPolicy(t *testing.T) {
+
+	tiger := monoikos.NewTiger()
+	hidden := tiger.GetHiddenStates()
+	createBelief := monoikos.NewBayesianBelief(tiger, tiger, hidden)
+	environment := monoikos.NewBeliefEnvironment(tiger, createBelief)
+	policy := environment.CreateOptimizedPolicy(40, 5000, 5)
+
+	start := createTigerObservation(monoikos.TigerNone)
+	belief := createBelief(start)
+	action := policy.GetPreferredAction(&monoikos.SummaryState{Summary: belief.GetState(), Observation: start})
+	if action == nil || action.GetId() != monoikos.TigerListen {
+		t.Errorf("Expected optimized policy to listen before opening a door, got '%v'.", action)
+	}
+
+	if len(environment.GetKnownStates()) < 5 {
+		t.Errorf("Expected beliefs either way to have been visited, got '%v'.", len(environment.GetKnownStates()))
+	}
+}
@@ -0,0 +1,207 @@
+package monoikos_test
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/tysont/monoikos"
+)
+
+// TestHelperProcess isn't a real test; it's the stub simulator that the process tests start, which
+// counts up from zero.  Climbing pays one, and stopping ends the episode and pays the count, so the
+// best thing to do is to climb to three and then stop.  Hanging is never answered.
+func TestHelperProcess(t *testing.T) {
+
+	if os.Getenv("MONOIKOS_HELPER_PROCESS") != "1" {
+
+		return
+	}
+
+	type state struct {
+		Context  map[string]string `json:"context"`
+		Terminal bool              `json:"terminal"`
+		Reward   int               `json:"reward"`
+	}
+
+	type request struct {
+		Command string `json:"command"`
+		Action  string `json:"action"`
+		State   *state `json:"state"`
+	}
+
+	count, reward, terminal := 0, 0, false
+	current := func() map[string]interface{} {
+
+		return map[string]interface{}{"state": &state{Context: map[string]string{"count": strconv.Itoa(count)}, Terminal: terminal, Reward: reward}}
+	}
+
+	encoder := json.NewEncoder(os.Stdout)
+	scanner := bufio.NewScanner(os.Stdin)
+	for scanner.Scan() {
+
+		r := new(request)
+		json.Unmarshal(scanner.Bytes(), r)
+		switch r.Command {
+		case "reset":
+			count, reward, terminal = 0, 0, false
+			encoder.Encode(current())
+		case "observe":
+			encoder.Encode(current())
+		case "legal_actions":
+			n, _ := strconv.Atoi(r.State.Context["count"])
+			if n < 3 {
+				encoder.Encode(map[string]interface{}{"actions": []string{"climb", "stop"}})
+			} else {
+				encoder.Encode(map[string]interface{}{"actions": []string{"stop"}})
+			}
+		case "step":
+			if r.Action == "hang" {
+				continue
+			} else if r.Action == "climb" && count < 3 {
+				count++
+				reward++
+				encoder.Encode(current())
+			} else if r.Action == "stop" {
+				reward += count
+				terminal = true
+				encoder.Encode(current())
+			} else {
+				encoder.Encode(map[string]interface{}{"error": fmt.Sprintf("can't %v from %v", r.Action, count)})
+			}
+		case "close":
+			os.Exit(0)
+		}
+	}
+
+	os.Exit(0)
+}
+
+// startHelperProcess starts the stub simulator.
+func startHelperProcess(t *testing.T) *monoikos.ProcessEnvironment {
+
+	return startHelperProcessContext(t, context.Background(), monoikos.DefaultProcessTimeout)
+}
+
+// startHelperProcessContext starts the stub simulator with a context and a timeout, keeping whatever it
+// writes to stderr.
+func startHelperProcessContext(t *testing.T, ctx context.Context, timeout time.Duration) *monoikos.ProcessEnvironment {
+
+	os.Setenv("MONOIKOS_HELPER_PROCESS", "1")
+	defer os.Unsetenv("MONOIKOS_HELPER_PROCESS")
+
+	environment := monoikos.NewProcessEnvironment(os.Args[0], "-test.run=TestHelperProcess")
+	environment.Stderr = new(bytes.Buffer)
+	environment.Timeout = timeout
+	err := environment.Start(ctx)
+	if err != nil {
+
+		t.Fatal(err)
+	}
+
+	return environment
+}
+
+func TestProcessEnvironment(t *testing.T) {
+
+	environment := startHelperProcess(t)
+	defer environment.Close()
+
+	experiment := environment.CreateExperiment()
+	state := experiment.ObserveState()
+	if state.GetContext()["count"] != "0" || state.IsTerminal() {
+		t.Errorf("Expected the process to start at zero, got '%v'.", state.GetId())
+	}
+
+	actions := environment.GetLegalActions(state)
+	if len(actions) != 2 || actions[0].GetId() != "climb" {
+		t.Errorf("Expected to be able to climb or stop, got '%v'.", actions)
+	}
+
+	policy := environment.CreateOptimizedPolicy(40, 200, 4)
+	outcomes := environment.CreateExperiment().Run(policy)
+	if len(outcomes) != 4 || outcomes[0].GetReward() != 6 {
+		t.Errorf("Expected to climb to the top and stop, got '%v' outcomes.", len(outcomes))
+	}
+
+	if len(environment.GetKnownStates()) != 4 {
+		t.Errorf("Expected every count to be known, got '%v'.", len(environment.GetKnownStates()))
+	}
+}
+
+func TestProcessEnvironmentErrors(t *testing.T) {
+
+	environment := startHelperProcess(t)
+
+	// The process refuses an action that isn't legal, and the experiment fails.
+	experiment := environment.CreateExperiment()
+	_, err := monoikos.TryForceRunExperiment(experiment, &monoikos.ProcessAction{Id: "jump"}, environment.CreateRandomPolicy())
+	actionError := new(monoikos.ActionError)
+	if !errors.As(err, &actionError) || actionError.Action.GetId() != "jump" {
+		t.Errorf("Expected an action error, got '%v'.", err)
+	}
+
+	// Once the process has gone, experiments fail rather than panicking.
+	err = environment.Close()
+	if err != nil {
+		t.Errorf("Expected the process to close cleanly, got '%v'.", err)
+	}
+
+	_, err = monoikos.TryRunExperiment(environment.CreateExperiment(), environment.CreateRandomPolicy())
+	if err == nil {
+		t.Errorf("Expected an error from a process that's closed.")
+	}
+}
+
+func TestProcessEnvironmentTimeout(t *testing.T) {
+
+	environment := startHelperProcessContext(t, context.Background(), 100*time.Millisecond)
+	defer environment.Close()
+
+	// A process that doesn't answer in time is given up on, and nothing more is asked of it.
+	start := time.Now()
+	experiment := environment.CreateExperiment()
+	_, err := monoikos.TryForceRunExperiment(experiment, &monoikos.ProcessAction{Id: "hang"}, environment.CreateRandomPolicy())
+	if err == nil || !strings.Contains(err.Error(), "monoikos: the process didn't answer 'step'") {
+		t.Errorf("Expected the process to time out, got '%v'.", err)
+	}
+
+	if time.Since(start) > 2*time.Second {
+		t.Errorf("Expected the process to time out promptly, took '%v'.", time.Since(start))
+	}
+
+	_, err = monoikos.TryRunExperiment(environment.CreateExperiment(), environment.CreateRandomPolicy())
+	if err == nil || !strings.Contains(err.Error(), "didn't answer") {
+		t.Errorf("Expected every request after a timeout to fail, got '%v'.", err)
+	}
+}
+
+func TestProcessEnvironmentContext(t *testing.T) {
+
+	// Without a timeout a process that doesn't answer is only given up on when its context is done,
+	// which kills it.
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+
+	environment := startHelperProcessContext(t, ctx, 0)
+	defer environment.Close()
+
+	start := time.Now()
+	experiment := environment.CreateExperiment()
+	_, err := monoikos.TryForceRunExperiment(experiment, &monoikos.ProcessAction{Id: "hang"}, environment.CreateRandomPolicy())
+	if err == nil || !strings.HasPrefix(err.Error(), "monoikos: ") {
+		t.Errorf("Expected a process that's been killed to fail, got '%v'.", err)
+	}
+
+	if time.Since(start) > 2*time.Second {
+		t.Errorf("Expected the process to be killed promptly, took '%v'.", time.Since(start))
+	}
+}
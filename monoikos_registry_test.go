@@ -0,0 +1,165 @@
+package monoikos_test
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/tysont/monoikos"
+)
+
+func TestRegisteredEnvironments(t *testing.T) {
+
+	names := monoikos.GetEnvironmentNames()
+	for _, name := range []string{"bandit", "blackjack", "counting-blackjack", "gridworld", "tabular", "tiger", "tictactoe"} {
+
+		found := false
+		for _, n := range names {
+
+			found = found || n == name
+		}
+
+		if !found {
+			t.Errorf("Expected '%v' to be registered, got '%v'.", name, names)
+		}
+	}
+
+	monoikos.RegisterEnvironment("count", func(options map[string]string) (monoikos.Environment, error) {
+
+		return new(CountEnvironment), nil
+	})
+
+	environment, err := monoikos.CreateEnvironment("count", nil)
+	if err != nil || environment == nil {
+		t.Errorf("Expected a registered environment to be created, got '%v'.", err)
+	}
+
+	_, err = monoikos.CreateEnvironment("missing", nil)
+	if err == nil {
+		t.Errorf("Expected an error for an environment that isn't registered.")
+	}
+
+	_, err = monoikos.CreateEnvironment("tabular", nil)
+	if err == nil {
+		t.Errorf("Expected an error for a tabular environment without a file.")
+	}
+
+	_, err = monoikos.CreateEnvironment("bandit", map[string]string{"probabilities": "0.1,x"})
+	if err == nil {
+		t.Errorf("Expected an error for a bandit with a bad probability.")
+	}
+}
+
+func TestRegisteredBlackjackRules(t *testing.T) {
+
+	options := map[string]string{"decks": "2", "hits-soft-17": "true", "payout": "1.2", "surrender": "false", "bet": "20"}
+	environment, err := monoikos.CreateEnvironment("blackjack", options)
+	if err != nil {
+
+		t.Fatal(err)
+	}
+
+	rules := environment.(*monoikos.BlackjackEnvironment).Rules
+	if rules.Decks != 2 || !rules.DealerHitsSoft17 || rules.BlackjackPayout != 1.2 || rules.LateSurrender || rules.Bet != 20 {
+		t.Errorf("Expected the house rules to come from the options, got '%+v'.", rules)
+	}
+
+	if rules.Penetration != 0.75 || rules.MaxSplitHands != 4 || !rules.Insurance {
+		t.Errorf("Expected the rules that weren't given to keep their defaults, got '%+v'.", rules)
+	}
+
+	environment, err = monoikos.CreateEnvironment("counting-blackjack", options)
+	if err != nil || environment.(*monoikos.CountingBlackjackEnvironment).Rules.Decks != 2 {
+		t.Errorf("Expected counting blackjack to use the house rules too, got '%v'.", err)
+	}
+
+	for _, bad := range []map[string]string{{"decks": "x"}, {"surrender": "maybe"}, {"decks": "0"}, {"penetration": "1.5"}} {
+
+		_, err = monoikos.CreateEnvironment("blackjack", bad)
+		if err == nil {
+			t.Errorf("Expected an error for blackjack with '%v'.", bad)
+		}
+	}
+}
+
+func TestPolicyFile(t *testing.T) {
+
+	dir, err := ioutil.TempDir("", "monoikos")
+	if err != nil {
+
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	environment, err := monoikos.CreateEnvironment("gridworld", nil)
+	if err != nil {
+
+		t.Fatal(err)
+	}
+
+	config := monoikos.NewTrainingConfig()
+	config.ExperimentsPerIteration = 500
+	policy, report, err := monoikos.TrainPolicy(context.Background(), environment, config)
+	if err != nil {
+
+		t.Fatal(err)
+	}
+
+	if len(report.Iterations) != config.Iterations || report.Iterations[0].Experiments != 500 {
+		t.Errorf("Expected a report for every iteration, got '%v'.", report.Iterations)
+	}
+
+	// Write the policy out, read it back, and check that it does the same thing in every state.
+	file := monoikos.NewPolicyFile(policy.(*monoikos.BasicPolicy), report.Values, report.Visits)
+	file.Version = "1"
+	path := filepath.Join(dir, "policy.json")
+	err = file.Write(path)
+	if err != nil {
+
+		t.Fatal(err)
+	}
+
+	read, err := monoikos.ReadPolicyFile(path)
+	if err != nil {
+
+		t.Fatal(err)
+	}
+
+	if read.Version != "1" || len(read.States) != len(file.States) || len(read.States) == 0 {
+		t.Errorf("Expected the policy file to be read back as it was written.")
+	}
+
+	restored, err := read.CreatePolicy(environment)
+	if err != nil {
+
+		t.Fatal(err)
+	}
+
+	visited := 0
+	for _, state := range environment.GetKnownStates() {
+
+		expected := policy.GetPreferredAction(state)
+		actual := restored.GetPreferredAction(state)
+		if expected != nil && (actual == nil || actual.GetId() != expected.GetId()) {
+			t.Errorf("Expected '%v' in state '%v', got '%v'.", expected.GetId(), state.GetId(), actual)
+		}
+
+		if s := read.GetState(state.GetId()); s != nil && len(s.Visits) > 0 {
+
+			visited++
+		}
+	}
+
+	if visited == 0 {
+		t.Errorf("Expected visits to be stored with the policy.")
+	}
+
+	// The default map isn't slippery, so the restored policy should do exactly as well as the trained one.
+	expected := monoikos.EvaluatePolicy(environment, policy, 10)
+	evaluation := monoikos.EvaluatePolicy(environment, restored, 10)
+	if evaluation.Experiments != 10 || evaluation.Failures != 0 || evaluation.AverageReward != expected.AverageReward {
+		t.Errorf("Expected the restored policy to do as well as the trained one, got '%v' and '%v'.", evaluation, expected)
+	}
+}
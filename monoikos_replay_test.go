@@ -0,0 +1,221 @@
+package monoikos_test
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/tysont/monoikos"
+)
+
+func TestGetTransitionsFromOutcomes(t *testing.T) {
+
+	environment := new(CountEnvironment)
+	policy := environment.CreateRandomPolicy()
+
+	experiment := NewCountExperiment()
+	experiment.Context[countContextKey] = 1
+	outcomes := experiment.ForceRun(new(IncrementAction), policy)
+	transitions := monoikos.GetTransitions(outcomes)
+
+	if len(transitions) != len(outcomes) {
+
+		t.Fatalf("Expected one transition per outcome, got '%v' for '%v'.", len(transitions), len(outcomes))
+	}
+
+	if transitions[0].NextState.GetContext()[countContextKey] != "2" {
+
+		t.Errorf("Expected the first transition to lead to a count of 2, got '%v'.", transitions[0].NextState.GetId())
+	}
+
+	last := transitions[len(transitions)-1]
+	if !last.Terminal || last.Reward != outcomes[0].GetReward() {
+
+		t.Errorf("Expected the last transition to be terminal and pay out the reward, got '%v'.", last.Reward)
+	}
+}
+
+func TestReplayBufferEvictsOldest(t *testing.T) {
+
+	buffer := monoikos.NewReplayBuffer(3)
+	for i := 0; i < 5; i++ {
+
+		transition := new(monoikos.Transition)
+		transition.Reward = i
+		buffer.Add(transition)
+	}
+
+	if buffer.Len() != 3 {
+
+		t.Fatalf("Expected buffer to hold 3 transitions, got '%v'.", buffer.Len())
+	}
+
+	for _, transition := range buffer.Transitions {
+
+		if transition.Reward < 2 {
+
+			t.Errorf("Expected the oldest transitions to be evicted, found reward '%v'.", transition.Reward)
+		}
+	}
+}
+
+func TestReplayBufferPrioritizedSampling(t *testing.T) {
+
+	buffer := monoikos.NewReplayBuffer(10)
+	for i := 0; i < 2; i++ {
+
+		transition := new(monoikos.Transition)
+		transition.Reward = i
+		buffer.Add(transition)
+	}
+
+	buffer.UpdatePriority(0, 0)
+	buffer.UpdatePriority(1, 100)
+
+	n := 0
+	transitions, indexes := buffer.SamplePrioritized(1000)
+	for i, transition := range transitions {
+
+		if transition.Reward == 1 && indexes[i] == 1 {
+
+			n++
+		}
+	}
+
+	if n < 900 {
+
+		t.Errorf("Expected the high priority transition to dominate sampling, got it '%v' times out of 1000.", n)
+	}
+}
+
+func TestReplayBufferSaveAndLoad(t *testing.T) {
+
+	environment := new(CountEnvironment)
+	policy := environment.CreateRandomPolicy()
+
+	buffer := monoikos.NewReplayBuffer(100)
+	for i := 0; i < 5; i++ {
+
+		buffer.AddOutcomes(environment.CreateExperiment().Run(policy))
+	}
+
+	dir, err := ioutil.TempDir("", "monoikos")
+	if err != nil {
+
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	path := filepath.Join(dir, "replay.json")
+	err = buffer.Save(path)
+	if err != nil {
+
+		t.Fatal(err)
+	}
+
+	loaded, err := monoikos.LoadReplayBuffer(environment, path)
+	if err != nil {
+
+		t.Fatal(err)
+	}
+
+	if loaded.Len() != buffer.Len() {
+
+		t.Fatalf("Expected loaded buffer to hold '%v' transitions, got '%v'.", buffer.Len(), loaded.Len())
+	}
+
+	for i, transition := range loaded.Transitions {
+
+		if transition.State.GetId() != buffer.Transitions[i].State.GetId() || transition.Action.GetId() != buffer.Transitions[i].Action.GetId() {
+
+			t.Errorf("Expected loaded transition '%v' to match the saved one.", i)
+		}
+	}
+}
+
+func TestReplayBufferSaveWithoutActions(t *testing.T) {
+
+	final := monoikos.NewBasicState()
+	final.Terminal = true
+
+	// Transitions without actions can be replayed, but not saved, since actions are saved by identifier.
+	buffer := monoikos.NewReplayBuffer(10)
+	buffer.Add(&monoikos.Transition{State: monoikos.NewBasicState(), NextState: final, Terminal: true})
+
+	dir, err := ioutil.TempDir("", "monoikos")
+	if err != nil {
+
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	path := filepath.Join(dir, "replay.json")
+	err = buffer.Save(path)
+	if _, statErr := os.Stat(path); err == nil || !os.IsNotExist(statErr) {
+
+		t.Errorf("Expected an error, and nothing written, for saving transitions without actions.")
+	}
+}
+
+func TestReplayBufferLoadIntoSmallerCapacity(t *testing.T) {
+
+	environment := new(CountEnvironment)
+	policy := environment.CreateRandomPolicy()
+
+	buffer := monoikos.NewReplayBuffer(100)
+	for buffer.Len() < 3 {
+
+		buffer.AddOutcomes(environment.CreateExperiment().Run(policy))
+	}
+
+	// Give every transition its own priority so they can be told apart once loaded.
+	saved := make(map[float64]*monoikos.Transition)
+	for i, transition := range buffer.Transitions {
+
+		buffer.Priorities[i] = float64(i + 1)
+		saved[float64(i+1)] = transition
+	}
+
+	dir, err := ioutil.TempDir("", "monoikos")
+	if err != nil {
+
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	for _, capacity := range []int{0, 2} {
+
+		buffer.Capacity = capacity
+		path := filepath.Join(dir, "replay.json")
+		err = buffer.Save(path)
+		if err != nil {
+
+			t.Fatal(err)
+		}
+
+		loaded, err := monoikos.LoadReplayBuffer(environment, path)
+		if err != nil {
+
+			t.Fatal(err)
+		}
+
+		if loaded.Len() != capacity {
+
+			t.Fatalf("Expected loaded buffer to hold '%v' transitions, got '%v'.", capacity, loaded.Len())
+		}
+
+		for i, transition := range loaded.Transitions {
+
+			original, ok := saved[loaded.Priorities[i]]
+			if !ok || loaded.Priorities[i] <= float64(len(saved)-capacity) {
+
+				t.Errorf("Expected only the newest transitions to be kept, got priority '%v'.", loaded.Priorities[i])
+
+			} else if transition.State.GetId() != original.State.GetId() || transition.Action.GetId() != original.Action.GetId() {
+
+				t.Errorf("Expected loaded transition '%v' to keep its own priority '%v'.", i, loaded.Priorities[i])
+			}
+		}
+	}
+}
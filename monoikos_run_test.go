@@ -0,0 +1,261 @@
+package monoikos_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/tysont/monoikos"
+)
+
+var loopingJSON = `{
+	"states": ["waiting", "done"],
+	"actions": ["wait", "stop"],
+	"start": {"waiting": 1.0},
+	"terminal": ["done"],
+	"transitions": [
+		{"state": "waiting", "action": "wait", "next": "waiting", "probability": 1.0, "reward": 1},
+		{"state": "waiting", "action": "stop", "next": "done", "probability": 1.0, "reward": 0}
+	]
+}`
+
+func TestTruncateExperiment(t *testing.T) {
+
+	mdp, err := monoikos.NewTabularMDPFromJSON([]byte(loopingJSON))
+	if err != nil {
+
+		t.Fatal(err)
+	}
+
+	// A policy that never stops would run forever without a limit.
+	runner := monoikos.NewEpisodeRunner()
+	runner.MaxSteps = 50
+	experiment := mdp.CreateExperiment()
+	if !monoikos.SetRunner(experiment, runner) {
+		t.Fatalf("Expected the experiment to be told which runner to use.")
+	}
+
+	policy := mdp.CreateRandomPolicy()
+	policy.SetRandomizationRate(0)
+	for _, action := range mdp.GetLegalActions(experiment.ObserveState()) {
+
+		if action.GetId() == "wait" {
+
+			policy.AddState(experiment.ObserveState(), action, []monoikos.Action{})
+		}
+	}
+
+	outcomes := experiment.Run(policy)
+	if len(outcomes) != 50 {
+		t.Fatalf("Expected the experiment to be truncated after 50 steps, got '%v'.", len(outcomes))
+	}
+
+	last := outcomes[len(outcomes)-1]
+	if !monoikos.IsTruncated(last) || last.GetFinalState().IsTerminal() || last.GetReward() != 50 {
+		t.Errorf("Expected the final state to be truncated rather than terminal.")
+	}
+
+	transitions := monoikos.GetTransitions(outcomes)
+	if transitions[0].Truncated || !transitions[len(transitions)-1].Truncated || transitions[len(transitions)-1].Terminal {
+		t.Errorf("Expected only the last transition to be truncated, and not terminal.")
+	}
+
+	// A truncated experiment only paid out part of what it would have, so it isn't averaged in while
+	// there's nothing to tell what waiting would have gone on to pay.
+	if len(monoikos.GetAverageRewards(outcomes)) != 0 {
+		t.Errorf("Expected truncated outcomes to be left out of the average rewards.")
+	}
+
+	// Once an experiment has stopped, waiting is credited with what was paid out plus what stopping
+	// goes on to pay.
+	outcomes = append(outcomes, mdp.CreateExperiment().ForceRun(&monoikos.TabularAction{Name: "stop"}, policy)...)
+	rewards := monoikos.GetAverageRewards(outcomes)
+	if len(rewards) != 2 || rewards[outcomes[0].GetId()] != 50 || rewards[outcomes[len(outcomes)-1].GetId()] != 0 {
+		t.Errorf("Expected truncated outcomes to be bootstrapped, got '%v'.", rewards)
+	}
+}
+
+// trapJSON is an MDP where waiting pays three a step for three steps and then costs a hundred, so that
+// waiting looks better than stopping if only the first three steps are counted.
+var trapJSON = `{
+	"states": ["start", "a", "b", "c", "done"],
+	"actions": ["wait", "stop"],
+	"start": {"start": 1.0},
+	"terminal": ["done"],
+	"transitions": [
+		{"state": "start", "action": "stop", "next": "done", "probability": 1.0, "reward": 5},
+		{"state": "start", "action": "wait", "next": "a", "probability": 1.0, "reward": 3},
+		{"state": "a", "action": "wait", "next": "b", "probability": 1.0, "reward": 3},
+		{"state": "b", "action": "wait", "next": "c", "probability": 1.0, "reward": 3},
+		{"state": "c", "action": "wait", "next": "done", "probability": 1.0, "reward": -100}
+	]
+}`
+
+func TestOptimizeTruncatedExperiments(t *testing.T) {
+
+	mdp, err := monoikos.NewTabularMDPFromJSON([]byte(trapJSON))
+	if err != nil {
+
+		t.Fatal(err)
+	}
+
+	// Waiting is always cut short before the cost, and would look like it pays nine if the partial
+	// rewards were averaged in.
+	config := monoikos.NewTrainingConfig()
+	config.ExperimentsPerIteration = 50
+	config.MaxSteps = 3
+	policy, report, err := monoikos.TrainPolicy(context.Background(), mdp, config)
+	if err != nil {
+
+		t.Fatal(err)
+	}
+
+	start := mdp.CreateExperiment().ObserveState()
+	if policy.GetPreferredAction(start).GetId() != "stop" {
+		t.Errorf("Expected the optimized policy to stop rather than fall into the trap.")
+	}
+
+	for id, value := range report.Values {
+
+		if value > 5 {
+			t.Errorf("Expected no value to be higher than stopping, got '%v' for '%v'.", value, id)
+		}
+	}
+}
+
+func TestBootstrapTruncatedTransitions(t *testing.T) {
+
+	mdp, err := monoikos.NewTabularMDPFromJSON([]byte(loopingJSON))
+	if err != nil {
+
+		t.Fatal(err)
+	}
+
+	runner := monoikos.NewEpisodeRunner()
+	runner.MaxSteps = 1
+
+	// Every experiment is cut short after a single step, so the value of waiting can only grow past
+	// the single reward by bootstrapping from the state that follows.
+	learner := monoikos.NewDynaQ(mdp)
+	learner.Discount = 0.5
+	learner.LearningRate = 0.5
+	learner.PlanningSteps = 0
+	policy := mdp.CreateRandomPolicy()
+	policy.SetRandomizationRate(50)
+	for i := 0; i < 200; i++ {
+
+		experiment := mdp.CreateExperiment()
+		monoikos.SetRunner(experiment, runner)
+		learner.LearnOutcomes(experiment.Run(policy))
+	}
+
+	state := mdp.CreateExperiment().ObserveState()
+	if value := learner.GetMaxValue(state); value < 1.9 {
+		t.Errorf("Expected waiting to be worth close to two, got '%v'.", value)
+	}
+}
+
+func TestTruncateGridworld(t *testing.T) {
+
+	gridworld, err := monoikos.NewGridworld("S#G")
+	if err != nil {
+
+		t.Fatal(err)
+	}
+
+	gridworld.MaxSteps = 10
+	policy := gridworld.CreateRandomPolicy()
+	outcomes := gridworld.CreateExperiment().Run(policy)
+	if len(outcomes) != 10 || !monoikos.IsTruncated(outcomes[0]) {
+		t.Errorf("Expected a walled in agent to be truncated after ten steps, got '%v'.", len(outcomes))
+	}
+}
+
+type countingHook struct {
+	steps    int
+	episodes int
+	outcomes int
+}
+
+func (this *countingHook) OnStep(outcome monoikos.Outcome) {
+
+	this.steps++
+	if monoikos.GetNextState(outcome) == nil || outcome.GetFinalState() != nil {
+		panic("expected a step to have a next state but no final state")
+	}
+}
+
+func (this *countingHook) OnEpisode(outcomes []monoikos.Outcome) {
+
+	this.episodes++
+	this.outcomes += len(outcomes)
+}
+
+func TestEpisodeRunner(t *testing.T) {
+
+	environment := new(CountEnvironment)
+	policy := environment.CreateRandomPolicy()
+
+	hook := new(countingHook)
+	runner := monoikos.NewEpisodeRunner()
+	runner.Hooks = append(runner.Hooks, hook)
+
+	// Forcing a stop on the first step ends the experiment straight away.
+	experiment := NewCountExperiment()
+	experiment.Context[countContextKey] = 5
+	outcomes := runner.ForceRun(experiment, new(StopAction), policy)
+	if len(outcomes) != 1 || outcomes[0].GetReward() != 5 || !outcomes[0].GetFinalState().IsTerminal() {
+		t.Errorf("Expected the forced stop to end the experiment with a reward of five.")
+	}
+
+	// Counting up one at a time takes at most one step per count.
+	runner.MaxSteps = 3
+	experiment = NewCountExperiment()
+	experiment.Context[countContextKey] = 0
+	outcomes = runner.ForceRun(experiment, new(IncrementAction), policy)
+	if len(outcomes) > 3 || (len(outcomes) == 3 && !outcomes[2].GetFinalState().IsTerminal() && !monoikos.IsTruncated(outcomes[2])) {
+		t.Errorf("Expected the experiment to end or be truncated within three steps, got '%v'.", len(outcomes))
+	}
+
+	if hook.episodes != 2 || hook.steps != hook.outcomes || hook.steps != 1+len(outcomes) {
+		t.Errorf("Expected the hook to see every step and episode, got '%v' steps in '%v' episodes.", hook.steps, hook.episodes)
+	}
+}
+
+func TestNewExperiment(t *testing.T) {
+
+	hook := new(countingHook)
+	runner := monoikos.NewEpisodeRunner()
+	runner.Hooks = []monoikos.EpisodeHook{hook}
+
+	// A step experiment only has to observe and apply, and can then be run like any other.
+	environment := new(CountEnvironment)
+	experiment := monoikos.NewExperiment(NewCountExperiment())
+	monoikos.SetRunner(experiment, runner)
+	outcomes := experiment.Run(environment.CreateRandomPolicy())
+	if len(outcomes) == 0 || !outcomes[len(outcomes)-1].GetFinalState().IsTerminal() {
+		t.Errorf("Expected the wrapped experiment to run to a terminal state.")
+	}
+
+	// Built in environments can be told which runner to use too, and keep their own step limit.
+	gridworld, _ := monoikos.NewGridworld("SG")
+	experiment = gridworld.CreateExperiment()
+	monoikos.SetRunner(experiment, runner)
+	experiment.Run(gridworld.CreateRandomPolicy())
+	if hook.episodes != 2 {
+		t.Errorf("Expected the hook to see both experiments, saw '%v'.", hook.episodes)
+	}
+
+	if runner.MaxSteps != monoikos.DefaultMaxSteps {
+		t.Errorf("Expected the gridworld to leave the runner's step limit alone, got '%v'.", runner.MaxSteps)
+	}
+
+	// Training tells every experiment to use a runner with the hooks in the config.
+	config := monoikos.NewTrainingConfig()
+	config.ExperimentsPerIteration = 10
+	config.Iterations = 2
+	config.Hooks = []monoikos.EpisodeHook{hook}
+	monoikos.TrainPolicy(context.Background(), gridworld, config)
+	if hook.episodes != 22 {
+		t.Errorf("Expected the hook to see every training experiment, saw '%v'.", hook.episodes)
+	}
+}
@@ -0,0 +1,148 @@
+package monoikos_test
+
+import (
+	"math/rand"
+	"testing"
+
+	"github.com/tysont/monoikos"
+)
+
+func TestCanonicalizeTicTacToe(t *testing.T) {
+
+	game := monoikos.NewTicTacToe()
+	symmetries := game.GetSymmetries()
+	if len(symmetries) != 7 {
+		t.Fatalf("Expected seven rotations and reflections of the board, got '%v'.", len(symmetries))
+	}
+
+	corner, _ := monoikos.Canonicalize(createBoardState("X........", "1"), symmetries)
+	for _, board := range []string{"..X......", "......X..", "........X"} {
+
+		canonical, _ := monoikos.Canonicalize(createBoardState(board, "1"), symmetries)
+		if canonical.GetId() != corner.GetId() {
+			t.Errorf("Expected every corner to have the same canonical form, got '%v'.", canonical.GetId())
+		}
+	}
+
+	center, _ := monoikos.Canonicalize(createBoardState("....X....", "1"), symmetries)
+	if center.GetId() == corner.GetId() {
+		t.Errorf("Expected the center not to be equivalent to a corner.")
+	}
+}
+
+func TestSymmetriesAreConsistent(t *testing.T) {
+
+	game := monoikos.NewTicTacToe()
+	for i := 0; i < 100; i++ {
+
+		// Play a few random moves to get to a random position.
+		state := game.GetInitialState()
+		for j := rand.Intn(6); j > 0; j-- {
+
+			actions := game.GetLegalActions(state)
+			state = game.GetNextState(state, actions[rand.Intn(len(actions))])
+		}
+
+		actions := game.GetLegalActions(state)
+		action := actions[rand.Intn(len(actions))]
+		for _, symmetry := range game.GetSymmetries() {
+
+			// Moving and then transforming has to be the same as transforming and then moving.
+			expected := symmetry.TransformState(game.GetNextState(state, action))
+			actual := game.GetNextState(symmetry.TransformState(state), symmetry.TransformAction(action))
+			if expected.GetId() != actual.GetId() {
+				t.Fatalf("Expected '%v', got '%v'.", expected.GetId(), actual.GetId())
+			}
+
+			if symmetry.Invert().TransformAction(symmetry.TransformAction(action)).GetId() != action.GetId() {
+				t.Fatalf("Expected the inverse to undo the transformation of '%v'.", action.GetId())
+			}
+		}
+	}
+}
+
+func TestSymmetricPolicyTranslatesActions(t *testing.T) {
+
+	game := monoikos.NewTicTacToe()
+	seat := monoikos.NewGameSeat(game, 1, nil)
+	policy := &monoikos.SymmetricPolicy{Policy: seat.CreateRandomPolicy(), Symmetries: game.GetSymmetries()}
+
+	// Answering a top left corner with the center should carry over to every other corner, and
+	// answering it with the opposite corner should be translated to match.
+	policy.AddState(createBoardState("X........", "1"), &monoikos.TicTacToeAction{Cell: 8}, []monoikos.Action{})
+	expected := map[string]string{"..X......": "Cell 6", "......X..": "Cell 2", "........X": "Cell 0"}
+	for board, cell := range expected {
+
+		action := policy.GetPreferredAction(createBoardState(board, "1"))
+		if action == nil || action.GetId() != cell {
+			t.Errorf("Expected '%v' to be answered with '%v', got '%v'.", board, cell, action)
+		}
+	}
+}
+
+func TestGridworldSymmetries(t *testing.T) {
+
+	gridworld, _ := monoikos.NewGridworld("G.G\n...\nG.G")
+	gridworld.StepCost = 0
+	if len(gridworld.GetSymmetries()) != 7 {
+		t.Fatalf("Expected a map with a goal in every corner to have seven symmetries, got '%v'.", len(gridworld.GetSymmetries()))
+	}
+
+	gridworld, _ = monoikos.NewGridworld("G..\n...\n...")
+	if len(gridworld.GetSymmetries()) != 1 {
+		t.Errorf("Expected a goal in one corner to only be symmetric across the diagonal, got '%v'.", len(gridworld.GetSymmetries()))
+	}
+
+	gridworld, _ = monoikos.NewGridworld("S..G")
+	if len(gridworld.GetSymmetries()) != 1 {
+		t.Errorf("Expected a single row to only be symmetric top to bottom, got '%v'.", len(gridworld.GetSymmetries()))
+	}
+}
+
+func TestCanonicalGridworld(t *testing.T) {
+
+	gridworld, _ := monoikos.NewGridworld("G...G\n.....\n.....\n.....\nG...G")
+	environment := monoikos.NewCanonicalEnvironment(gridworld, nil)
+	if len(environment.GetKnownStates()) != 6 {
+		t.Fatalf("Expected a five by five map to have six distinct tiles, got '%v'.", len(environment.GetKnownStates()))
+	}
+
+	policy := environment.CreateOptimizedPolicy(40, 1000, 5)
+	rendered := gridworld.RenderPolicy(policy)
+	expected := "G<.>G\n^...^\n.....\nv...v\nG<.>G\n"
+	for i := range rendered {
+
+		if expected[i] != '.' && rendered[i] != expected[i] {
+			t.Fatalf("Expected every tile beside a goal to step straight onto it, got\n%v", rendered)
+		}
+	}
+}
+
+func TestContextPermutation(t *testing.T) {
+
+	permutation := &monoikos.ContextPermutation{Keys: []string{"a", "b"}, Permutation: map[string]string{"x": "y", "y": "x"}}
+	state := monoikos.NewBasicState()
+	state.Context["a"] = "x"
+	state.Context["b"] = "z"
+	state.Context["c"] = "y"
+
+	transformed := permutation.TransformState(state)
+	if transformed.GetContext()["a"] != "y" || transformed.GetContext()["b"] != "z" || transformed.GetContext()["c"] != "y" {
+		t.Errorf("Expected only mapped values under the keys to be swapped, got '%v'.", transformed.GetId())
+	}
+
+	if permutation.Invert().TransformState(transformed).GetId() != state.GetId() {
+		t.Errorf("Expected the inverse to swap them back.")
+	}
+}
+
+func TestMinimaxQSharesSymmetricStates(t *testing.T) {
+
+	game := monoikos.NewTicTacToe()
+	learner := monoikos.NewMinimaxQ(game)
+	learner.Train(2000, 100)
+
+	if len(learner.KnownStates) > 4520/4 {
+		t.Errorf("Expected far fewer canonical states than positions, got '%v'.", len(learner.KnownStates))
+	}
+}
@@ -0,0 +1,217 @@
+package monoikos_test
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/tysont/monoikos"
+)
+
+var tabularYAML = `
+states: [start, middle, done]
+actions: [stop, walk, gamble]
+start: {start: 1.0}
+terminal: [done]
+transitions:
+  - {state: start, action: stop, next: done, probability: 1.0, reward: 1}
+  - {state: start, action: walk, next: middle, probability: 1.0, reward: 0}
+  - {state: middle, action: stop, next: done, probability: 1.0, reward: 3}
+  - {state: middle, action: gamble, next: done, probability: 0.5, reward: 10}
+  - {state: middle, action: gamble, next: middle, probability: 0.5, reward: -2}
+`
+
+var tabularJSON = `{
+	"states": ["start", "middle", "done"],
+	"actions": ["stop", "walk", "gamble"],
+	"start": {"start": 1.0},
+	"terminal": ["done"],
+	"transitions": [
+		{"state": "start", "action": "stop", "next": "done", "probability": 1.0, "reward": 1},
+		{"state": "start", "action": "walk", "next": "middle", "probability": 1.0, "reward": 0},
+		{"state": "middle", "action": "stop", "next": "done", "probability": 1.0, "reward": 3},
+		{"state": "middle", "action": "gamble", "next": "done", "probability": 0.5, "reward": 10},
+		{"state": "middle", "action": "gamble", "next": "middle", "probability": 0.5, "reward": -2}
+	]
+}`
+
+func TestLoadTabularMDP(t *testing.T) {
+
+	dir, err := ioutil.TempDir("", "monoikos")
+	if err != nil {
+
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	files := map[string]string{"mdp.yaml": tabularYAML, "mdp.json": tabularJSON}
+	for name, content := range files {
+
+		path := filepath.Join(dir, name)
+		err = ioutil.WriteFile(path, []byte(content), 0644)
+		if err != nil {
+
+			t.Fatal(err)
+		}
+
+		mdp, err := monoikos.LoadTabularMDP(path)
+		if err != nil {
+
+			t.Fatalf("Expected '%v' to load, got '%v'.", name, err)
+		}
+
+		if len(mdp.States) != 3 || len(mdp.Transitions) != 5 {
+
+			t.Errorf("Expected '%v' to have 3 states and 5 transitions, got '%v' and '%v'.", name, len(mdp.States), len(mdp.Transitions))
+		}
+	}
+}
+
+func TestValidateTabularMDP(t *testing.T) {
+
+	_, err := monoikos.NewTabularMDPFromJSON([]byte(`{
+		"states": ["a", "b"],
+		"actions": ["go"],
+		"terminal": ["b"],
+		"transitions": [{"state": "a", "action": "go", "next": "b", "probability": 0.5, "reward": 1}]
+	}`))
+
+	if err == nil {
+
+		t.Errorf("Expected probabilities that don't add up to one to be rejected.")
+	}
+
+	_, err = monoikos.NewTabularMDPFromJSON([]byte(`{
+		"states": ["a", "b"],
+		"actions": ["go"],
+		"terminal": ["b"],
+		"transitions": [{"state": "a", "action": "go", "next": "c", "probability": 1.0, "reward": 1}]
+	}`))
+
+	if err == nil {
+
+		t.Errorf("Expected a transition to an undeclared state to be rejected.")
+	}
+
+	_, err = monoikos.NewTabularMDPFromJSON([]byte(`{
+		"states": ["a", "b", "c"],
+		"actions": ["go"],
+		"terminal": ["b", "c"],
+		"transitions": [
+			{"state": "a", "action": "go", "next": "b", "probability": 1.5, "reward": 1},
+			{"state": "a", "action": "go", "next": "c", "probability": -0.5, "reward": 0}
+		]
+	}`))
+
+	if err == nil {
+
+		t.Errorf("Expected a negative probability to be rejected, even though the probabilities add up to one.")
+	}
+
+	_, err = monoikos.NewTabularMDPFromJSON([]byte(`{
+		"states": ["a", "b"],
+		"actions": ["go"],
+		"start": {"a": 2.0, "b": -1.0},
+		"terminal": ["b"],
+		"transitions": [{"state": "a", "action": "go", "next": "b", "probability": 1.0, "reward": 1}]
+	}`))
+
+	if err == nil {
+
+		t.Errorf("Expected a negative start probability to be rejected.")
+	}
+
+	mdp, err := monoikos.NewTabularMDPFromJSON([]byte(`{"states": ["a"], "terminal": ["a"]}`))
+	if err == nil || mdp != nil {
+
+		t.Errorf("Expected an MDP with nowhere to start to be rejected without an MDP.")
+	}
+}
+
+func TestSolveAndOptimizeTabularMDP(t *testing.T) {
+
+	mdp, err := monoikos.NewTabularMDPFromYAML([]byte(tabularYAML))
+	if err != nil {
+
+		t.Fatal(err)
+	}
+
+	solution := monoikos.SolveValueIteration(mdp, mdp)
+	expected := map[string]string{"start": "walk", "middle": "gamble"}
+	for _, state := range mdp.GetKnownStates() {
+
+		name := state.GetContext()["state"]
+		if _, ok := expected[name]; !ok {
+
+			continue
+		}
+
+		action := solution.Policy.GetPreferredAction(state)
+		if action.GetId() != expected[name] {
+
+			t.Errorf("Expected solved policy to '%v' in '%v', got '%v'.", expected[name], name, action.GetId())
+		}
+	}
+
+	policy := mdp.CreateOptimizedPolicy(40, 5000, 5)
+	agreement := solution.GetAgreement(policy)
+	if agreement != 1.0 {
+
+		t.Errorf("Expected optimized policy to agree with the solved policy, got '%v'.", agreement)
+	}
+}
+
+func TestAgreementToleratesTies(t *testing.T) {
+
+	mdp, err := monoikos.NewTabularMDPFromYAML([]byte(tabularYAML))
+	if err != nil {
+
+		t.Fatal(err)
+	}
+
+	// Prefer stopping in the middle, and make it a hair worse than gambling as far as the solution knows.
+	solution := monoikos.SolveValueIteration(mdp, mdp)
+	policy := monoikos.NewBasicPolicy()
+	var stop, gamble string
+	for _, state := range mdp.GetKnownStates() {
+
+		preferred := solution.Policy.GetPreferredAction(state)
+		if state.IsTerminal() || preferred == nil {
+
+			continue
+		}
+
+		if state.GetContext()["state"] != "middle" {
+
+			policy.AddState(state, preferred, nil)
+			continue
+		}
+
+		for _, action := range mdp.GetLegalActions(state) {
+
+			outcome := &monoikos.BasicOutcome{InitialState: state, ActionTaken: action}
+			if action.GetId() == "stop" {
+
+				policy.AddState(state, action, nil)
+				stop = outcome.GetId()
+
+			} else if action.GetId() == "gamble" {
+
+				gamble = outcome.GetId()
+			}
+		}
+	}
+
+	solution.ActionValues[stop] = solution.ActionValues[gamble] - solution.Tolerance/10
+	if agreement := solution.GetAgreement(policy); agreement != 1.0 {
+
+		t.Errorf("Expected a near tie to count as agreement, got '%v'.", agreement)
+	}
+
+	solution.ActionValues[stop] = solution.ActionValues[gamble] - 1
+	if agreement := solution.GetAgreement(policy); agreement != 0.5 {
+
+		t.Errorf("Expected a worse action to count as disagreement, got '%v'.", agreement)
+	}
+}
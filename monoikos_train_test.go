@@ -0,0 +1,86 @@
+package monoikos_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/tysont/monoikos"
+)
+
+func TestCancelTraining(t *testing.T) {
+
+	environment := new(CountEnvironment)
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+
+	// Far more experiments than could ever be run before the deadline.
+	start := time.Now()
+	policy, err := monoikos.CreateOptimizedPolicyContext(ctx, environment, 40, 1000000000, 5, monoikos.AbortOnFailure)
+	if err != context.DeadlineExceeded {
+		t.Errorf("Expected training to stop at the deadline, got '%v'.", err)
+	}
+
+	if time.Since(start) > time.Second {
+		t.Errorf("Expected training to stop promptly, took '%v'.", time.Since(start))
+	}
+
+	// Whatever was run before the deadline still went into the policy.
+	if policy == nil || len(policy.(*monoikos.BasicPolicy).KnownStates) == 0 || policy.GetRandomizationRate() != 0 {
+		t.Errorf("Expected the best policy so far to be returned.")
+	}
+
+	ctx, cancel = context.WithCancel(context.Background())
+	cancel()
+	policy, err = monoikos.CreateOptimizedPolicyContext(ctx, environment, 40, 100, 5, monoikos.AbortOnFailure)
+	if err != context.Canceled || policy == nil {
+		t.Errorf("Expected a random policy from training that was already cancelled, got '%v'.", err)
+	}
+}
+
+func TestTrainForDuration(t *testing.T) {
+
+	mdp, err := monoikos.NewTabularMDPFromJSON([]byte(tabularJSON))
+	if err != nil {
+
+		t.Fatal(err)
+	}
+
+	start := time.Now()
+	policy, err := monoikos.CreateOptimizedPolicyForDuration(context.Background(), mdp, 40, 300*time.Millisecond, 3, monoikos.AbortOnFailure)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	elapsed := time.Since(start)
+	if elapsed < 300*time.Millisecond || elapsed > 2*time.Second {
+		t.Errorf("Expected training to take about as long as it was given, took '%v'.", elapsed)
+	}
+
+	// Walking to the middle and gambling is worth eight, which beats stopping straight away.
+	state := mdp.CreateExperiment().ObserveState()
+	if policy.GetPreferredAction(state).GetId() != "walk" {
+		t.Errorf("Expected the policy to walk, got '%v'.", policy.GetPreferredAction(state).GetId())
+	}
+}
+
+func TestValidateTrainingConfig(t *testing.T) {
+
+	environment := new(CountEnvironment)
+	config := monoikos.NewTrainingConfig()
+	config.Iterations = 0
+	config.Duration = 100 * time.Millisecond
+	policy, _, err := monoikos.TrainPolicy(context.Background(), environment, config)
+	if err == nil || policy != nil {
+		t.Errorf("Expected training without any iterations to fail, got '%v'.", err)
+	}
+
+	// A single iteration doesn't randomize at all.
+	config.Iterations = 1
+	config.Duration = 0
+	config.ExperimentsPerIteration = 10
+	_, report, err := monoikos.TrainPolicy(context.Background(), environment, config)
+	if err != nil || len(report.Iterations) != 1 || report.Iterations[0].RandomizationRate != 0 {
+		t.Errorf("Expected a single iteration without randomization, got '%v'.", err)
+	}
+}
@@ -0,0 +1,194 @@
+package monoikos_test
+
+import (
+	"io/ioutil"
+	"math"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/tysont/monoikos"
+)
+
+func TestActionProbabilities(t *testing.T) {
+
+	mdp, err := monoikos.NewTabularMDPFromJSON([]byte(tabularJSON))
+	if err != nil {
+
+		t.Fatal(err)
+	}
+
+	// A state that hasn't been seen could be given any legal action.
+	policy := monoikos.NewBasicPolicy()
+	policy.Environment = mdp
+	state := mdp.CreateExperiment().ObserveState()
+	probabilities := policy.GetActionProbabilities(state)
+	if len(probabilities) != 2 || probabilities["walk"] != 0.5 || probabilities["stop"] != 0.5 {
+		t.Errorf("Expected every legal action to be as likely, got '%v'.", probabilities)
+	}
+
+	// Untried actions come first, and then the randomization rate is shared out.
+	policy.UnexploredFirst = true
+	policy.SetRandomizationRate(20)
+	policy.AddRandomState(state)
+	probabilities = policy.GetActionProbabilities(state)
+	if probabilities["walk"] != 0.5 || probabilities["stop"] != 0.5 {
+		t.Errorf("Expected untried actions to be as likely, got '%v'.", probabilities)
+	}
+
+	policy.UntriedActions[state.GetId()] = []monoikos.Action{}
+	probabilities = policy.GetActionProbabilities(state)
+	preferred := policy.GetPreferredAction(state).GetId()
+	if math.Abs(probabilities[preferred]-0.8) > 1e-9 || math.Abs(probabilities["walk"]+probabilities["stop"]-1) > 1e-9 {
+		t.Errorf("Expected the preferred action most of the time, got '%v'.", probabilities)
+	}
+}
+
+func TestTrajectoryRecorder(t *testing.T) {
+
+	dir, err := ioutil.TempDir("", "monoikos")
+	if err != nil {
+
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	mdp, err := monoikos.NewTabularMDPFromJSON([]byte(tabularJSON))
+	if err != nil {
+
+		t.Fatal(err)
+	}
+
+	recorder, err := monoikos.NewTrajectoryRecorder(dir)
+	if err != nil {
+
+		t.Fatal(err)
+	}
+
+	recorder.Run = "audit"
+	recorder.SetPolicyVersion("v3")
+	runner := monoikos.NewEpisodeRunner()
+	runner.Hooks = []monoikos.EpisodeHook{recorder}
+
+	// Walk and then stop, which is the preferred action four times in five.
+	policy := monoikos.NewBasicPolicy()
+	policy.Environment = mdp
+	policy.SetRandomizationRate(20)
+	start := mdp.CreateExperiment().ObserveState()
+	var walk, stop monoikos.Action
+	for _, action := range mdp.GetLegalActions(start) {
+
+		if action.GetId() == "walk" {
+
+			walk = action
+
+		} else {
+
+			stop = action
+		}
+	}
+
+	policy.AddState(start, walk, []monoikos.Action{stop})
+
+	experiment := mdp.CreateExperiment()
+	monoikos.SetRunner(experiment, runner)
+	outcomes := experiment.ForceRun(walk, policy)
+	err = recorder.Close()
+	if err != nil {
+
+		t.Fatal(err)
+	}
+
+	files, err := recorder.GetFiles()
+	if err != nil || len(files) != 1 {
+
+		t.Fatalf("Expected a single file, got '%v' '%v'.", files, err)
+	}
+
+	records, err := monoikos.ReadTrajectories(files[0])
+	if err != nil || len(records) != 1 {
+
+		t.Fatalf("Expected a single record, got '%v' '%v'.", len(records), err)
+	}
+
+	record := records[0]
+	if record.Format != monoikos.TrajectoryFormat || record.Run != "audit" || record.PolicyVersion != "v3" || record.Episode != 1 {
+		t.Errorf("Expected the record to say where it came from, got '%+v'.", record)
+	}
+
+	if len(record.Steps) != len(outcomes) || record.Reward != outcomes[0].GetReward() || !record.Terminal || record.Truncated {
+		t.Errorf("Expected a step for every outcome, got '%+v'.", record)
+	}
+
+	first := record.Steps[0]
+	if first.Context["state"] != "start" || first.Action != "walk" || first.Reward != 0 || first.Terminal || first.Probability != 1 {
+		t.Errorf("Expected the forced walk to be recorded for certain, got '%+v'.", first)
+	}
+
+	last := record.Steps[len(record.Steps)-1]
+	if !last.Terminal || record.Final["state"] != "done" || last.Probability <= 0 || last.Probability > 1 {
+		t.Errorf("Expected the last step to end the episode with a probability, got '%+v'.", last)
+	}
+}
+
+func TestRotateTrajectories(t *testing.T) {
+
+	dir, err := ioutil.TempDir("", "monoikos")
+	if err != nil {
+
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	environment := new(CountEnvironment)
+	policy := environment.CreateRandomPolicy()
+	recorder, err := monoikos.NewTrajectoryRecorder(dir)
+	if err != nil {
+
+		t.Fatal(err)
+	}
+
+	// Every record is bigger than a file can be, so each one gets a file of its own.
+	recorder.MaxBytes = 1
+	recorder.MaxFiles = 3
+	runner := monoikos.NewEpisodeRunner()
+	runner.Hooks = []monoikos.EpisodeHook{recorder}
+	for i := 0; i < 5; i++ {
+
+		runner.Run(environment.CreateExperiment().(monoikos.StepExperiment), policy)
+	}
+
+	err = recorder.Close()
+	if err != nil {
+
+		t.Fatal(err)
+	}
+
+	files, _ := recorder.GetFiles()
+	if len(files) != 3 || filepath.Base(files[0]) != "trajectories-000003.jsonl" {
+		t.Fatalf("Expected the three newest files, got '%v'.", files)
+	}
+
+	for i, file := range files {
+
+		records, err := monoikos.ReadTrajectories(file)
+		if err != nil || len(records) != 1 || records[0].Episode != int64(i+3) {
+			t.Errorf("Expected episode '%v' in '%v', got '%v' '%v'.", i+3, file, records, err)
+		}
+	}
+
+	// A new recorder carries on where the last one left off.
+	recorder, err = monoikos.NewTrajectoryRecorder(dir)
+	if err != nil {
+
+		t.Fatal(err)
+	}
+
+	runner.Hooks = []monoikos.EpisodeHook{recorder}
+	runner.Run(environment.CreateExperiment().(monoikos.StepExperiment), policy)
+	recorder.Close()
+	files, _ = recorder.GetFiles()
+	if len(files) != 4 || filepath.Base(files[3]) != "trajectories-000006.jsonl" {
+		t.Errorf("Expected numbering to carry on, got '%v'.", files)
+	}
+}
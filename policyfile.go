@@ -0,0 +1,190 @@
+package monoikos
+
+import (
+	"encoding/json"
+	"errors"
+	"io/ioutil"
+	"sort"
+	"sync"
+)
+
+// PolicyFile is the on disk representation of a policy.  Every state the policy knows is stored with
+// its context, its preferred action and other actions by identifier, and optionally the value and
+// number of visits of each action, so that it can be read by tools that don't know anything about the
+// environment.  It's versioned, and records the name and options of the registered environment that it
+// was trained on if there is one.
+type PolicyFile struct {
+	Version     string             `json:"version"`
+	Environment string             `json:"environment,omitempty"`
+	Options     map[string]string  `json:"options,omitempty"`
+	States      []*PolicyFileState `json:"states"`
+	index       map[string]*PolicyFileState
+	indexOnce   sync.Once
+}
+
+// PolicyFileState is a single state in a policy file.  Values and visits are keyed by action identifier.
+type PolicyFileState struct {
+	Id        string             `json:"id"`
+	Context   map[string]string  `json:"context"`
+	Preferred string             `json:"preferred"`
+	Others    []string           `json:"others,omitempty"`
+	Values    map[string]float64 `json:"values,omitempty"`
+	Visits    map[string]int     `json:"visits,omitempty"`
+}
+
+// NewPolicyFile creates a policy file for a policy, with the values and visits of outcomes keyed by
+// outcome identifier as they are in a TrainingReport; either can be nil.
+func NewPolicyFile(policy *BasicPolicy, values map[string]float64, visits map[string]int) *PolicyFile {
+
+	file := new(PolicyFile)
+	file.States = make([]*PolicyFileState, 0)
+
+	// Store states in order so that the file doesn't change if the policy doesn't.
+	ids := make([]string, 0)
+	for id := range policy.KnownStates {
+
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+
+	for _, id := range ids {
+
+		preferred := policy.PreferredAction[id]
+		if preferred == nil {
+
+			continue
+		}
+
+		state := policy.KnownStates[id]
+		s := &PolicyFileState{Id: id, Context: state.GetContext(), Preferred: preferred.GetId()}
+		s.Values = make(map[string]float64)
+		s.Visits = make(map[string]int)
+
+		actions := append([]Action{preferred}, policy.OtherActions[id]...)
+		for i, outcomeId := range getActionIds(state, actions) {
+
+			if i > 0 {
+
+				s.Others = append(s.Others, actions[i].GetId())
+			}
+
+			if value, ok := values[outcomeId]; ok {
+
+				s.Values[actions[i].GetId()] = value
+			}
+
+			if n, ok := visits[outcomeId]; ok {
+
+				s.Visits[actions[i].GetId()] = n
+			}
+		}
+
+		file.States = append(file.States, s)
+	}
+
+	return file
+}
+
+// ReadPolicyFile reads a policy file that was written with Write.
+func ReadPolicyFile(path string) (*PolicyFile, error) {
+
+	b, err := ioutil.ReadFile(path)
+	if err != nil {
+
+		return nil, err
+	}
+
+	file := new(PolicyFile)
+	err = json.Unmarshal(b, file)
+	if err != nil {
+
+		return nil, err
+	}
+
+	return file, nil
+}
+
+// Write writes the policy file to a JSON file at path.
+func (this *PolicyFile) Write(path string) error {
+
+	b, err := json.MarshalIndent(this, "", "  ")
+	if err != nil {
+
+		return err
+	}
+
+	return ioutil.WriteFile(path, b, 0644)
+}
+
+// GetState returns the stored state with an identifier, or nil if there isn't one.  The states are
+// indexed the first time it's called, so it's safe to call from more than one goroutine, but states
+// that are added after that aren't found.
+func (this *PolicyFile) GetState(id string) *PolicyFileState {
+
+	this.indexOnce.Do(func() {
+
+		this.index = make(map[string]*PolicyFileState)
+		for _, s := range this.States {
+
+			this.index[s.Id] = s
+		}
+	})
+
+	return this.index[id]
+}
+
+// CreatePolicy creates a policy for an environment from the policy file, without any randomization.
+// States are matched to the known states of the environment by identifier, and restored as basic
+// states otherwise, and actions are matched to the legal actions in each state by identifier.
+func (this *PolicyFile) CreatePolicy(environment Environment) (*BasicPolicy, error) {
+
+	known := make(map[string]State)
+	for _, state := range environment.GetKnownStates() {
+
+		known[state.GetId()] = state
+	}
+
+	policy := NewBasicPolicy()
+	policy.Environment = environment
+	policy.SetRandomizationRate(0)
+	for _, s := range this.States {
+
+		state, ok := known[s.Id]
+		if !ok {
+
+			basicState := NewBasicState()
+			for k, v := range s.Context {
+
+				basicState.Context[k] = v
+			}
+
+			state = basicState
+		}
+
+		// Find the actions with matching identifiers.
+		actions := make(map[string]Action)
+		for _, action := range environment.GetLegalActions(state) {
+
+			actions[action.GetId()] = action
+		}
+
+		preferred, ok := actions[s.Preferred]
+		if !ok {
+
+			return nil, errors.New("monoikos: no legal action '" + s.Preferred + "' for state " + s.Id)
+		}
+
+		others := make([]Action, 0)
+		for _, id := range s.Others {
+
+			if action, ok := actions[id]; ok {
+
+				others = append(others, action)
+			}
+		}
+
+		policy.AddState(state, preferred, others)
+	}
+
+	return policy, nil
+}
@@ -0,0 +1,450 @@
+package monoikos
+
+import (
+	"math"
+	"strconv"
+	"sync"
+)
+
+// PartiallyObservableExperiment is an experiment where the agent doesn't get to see everything that
+// matters.  ObserveState returns only what the agent can observe, which is what policies act on and
+// what outcomes record, while ObserveHiddenState returns the full state of the experiment for
+// evaluation and debugging.  Learners can train on the observations directly, in which case they
+// treat observations that look alike as the same state, or on a summary of the history of
+// observations by wrapping the environment in a BeliefEnvironment.
+type PartiallyObservableExperiment interface {
+	Experiment
+	ObserveHiddenState() State
+}
+
+// ObservationModel is a model of what an agent is likely to observe, which is the probability of an
+// observation given the hidden state that an action led to.  The action is nil for the first
+// observation of an experiment.
+type ObservationModel interface {
+	GetObservationProbability(action Action, state State, observation State) float64
+}
+
+// Belief summarizes what an agent has observed and done so far in an experiment into a single state
+// that a policy can condition on.  It's updated with every action that's taken and the observation
+// that follows it, and the summary should report whether the latest observation was terminal and
+// the reward paid out so far.
+type Belief interface {
+	Update(action Action, observation State)
+	GetState() State
+}
+
+// SummaryState is the state that policies see in a BeliefEnvironment; it's identified by the summary
+// that the belief produced, and keeps hold of the latest observation so that the legal actions can
+// still be worked out.
+type SummaryState struct {
+	Summary     State
+	Observation State
+}
+
+// GetId returns the identifier of the summary.
+func (this *SummaryState) GetId() string {
+
+	return this.Summary.GetId()
+}
+
+// IsTerminal returns whether the latest observation was terminal.
+func (this *SummaryState) IsTerminal() bool {
+
+	return this.Observation.IsTerminal()
+}
+
+// GetContext returns the context of the summary.
+func (this *SummaryState) GetContext() map[string]string {
+
+	return this.Summary.GetContext()
+}
+
+// GetReward returns the reward that's been paid out so far, according to the latest observation.
+func (this *SummaryState) GetReward() int {
+
+	return this.Observation.GetReward()
+}
+
+// BeliefEnvironment wraps an environment so that policies condition on a summary of the history of
+// an experiment rather than on the latest observation alone.  A new belief is created from the first
+// observation of every experiment, and is updated as the experiment runs.  Since summaries of the
+// history usually can't be listed up front, the known states are the summaries that have been
+// visited so far by any experiment.
+type BeliefEnvironment struct {
+	Environment  Environment
+	CreateBelief func(observation State) Belief
+	KnownStates  map[string]State
+	mutex        sync.Mutex
+}
+
+// NewBeliefEnvironment should be used to create a BeliefEnvironment; it handles instantiating members appropriately.
+func NewBeliefEnvironment(environment Environment, createBelief func(observation State) Belief) *BeliefEnvironment {
+
+	beliefEnvironment := new(BeliefEnvironment)
+	beliefEnvironment.Environment = environment
+	beliefEnvironment.CreateBelief = createBelief
+	beliefEnvironment.KnownStates = make(map[string]State)
+	return beliefEnvironment
+}
+
+// CreateRandomPolicy creates a random policy over summaries of the history.
+func (this *BeliefEnvironment) CreateRandomPolicy() Policy {
+
+	return CreateRandomPolicy(this)
+}
+
+// CreateImprovedPolicy creates an improved policy over summaries of the history from a set of outcomes.
+func (this *BeliefEnvironment) CreateImprovedPolicy(outcomes []Outcome) Policy {
+
+	return CreateImprovedPolicy(this, outcomes)
+}
+
+// CreateOptimizedPolicy creates an optimized policy over summaries of the history by running iterations
+// of experiments.
+func (this *BeliefEnvironment) CreateOptimizedPolicy(initialRandomizationRate int, experimentsPerIteration int, iterations int) Policy {
+
+	return CreateOptimizedPolicy(this, initialRandomizationRate, experimentsPerIteration, iterations)
+}
+
+// CreateExperiment creates an experiment in the wrapped environment, along with a new belief.
+func (this *BeliefEnvironment) CreateExperiment() Experiment {
+
+	experiment := new(BeliefExperiment)
+	experiment.Environment = this
+	experiment.Experiment = this.Environment.CreateExperiment()
+	observation := experiment.Experiment.ObserveState()
+	experiment.Belief = this.CreateBelief(observation)
+	experiment.state = &SummaryState{Summary: experiment.Belief.GetState(), Observation: observation}
+	this.addKnownState(experiment.state)
+
+	return experiment
+}
+
+// GetLegalActions returns the legal actions for the latest observation behind a summary.
+func (this *BeliefEnvironment) GetLegalActions(state State) []Action {
+
+	if summary, ok := state.(*SummaryState); ok {
+
+		return this.Environment.GetLegalActions(summary.Observation)
+	}
+
+	return this.Environment.GetLegalActions(state)
+}
+
+// GetKnownStates returns the summaries that have been visited so far.
+func (this *BeliefEnvironment) GetKnownStates() []State {
+
+	this.mutex.Lock()
+	defer this.mutex.Unlock()
+
+	states := make([]State, 0)
+	for _, state := range this.KnownStates {
+
+		states = append(states, state)
+	}
+
+	return states
+}
+
+// addKnownState records that a summary has been visited.
+func (this *BeliefEnvironment) addKnownState(state *SummaryState) {
+
+	this.mutex.Lock()
+	defer this.mutex.Unlock()
+
+	if _, ok := this.KnownStates[state.GetId()]; !ok {
+
+		this.KnownStates[state.GetId()] = state
+	}
+}
+
+// BeliefExperiment runs an experiment in the wrapped environment, keeping the belief up to date with
+// every action and observation, and records outcomes in terms of the summaries.
+type BeliefExperiment struct {
+	Environment *BeliefEnvironment
+	Experiment  Experiment
+	Belief      Belief
+	state       *SummaryState
+	action      Action
+	states      []State
+}
+
+// ObserveState returns the current summary.
+func (this *BeliefExperiment) ObserveState() State {
+
+	return this.state
+}
+
+// ObserveHiddenState returns the hidden state of the wrapped experiment if it has one, and the latest
+// observation otherwise.
+func (this *BeliefExperiment) ObserveHiddenState() State {
+
+	if experiment, ok := this.Experiment.(PartiallyObservableExperiment); ok {
+
+		return experiment.ObserveHiddenState()
+	}
+
+	return this.state.Observation
+}
+
+// SetRunner tells the wrapped experiment which episode runner to run with, if it can be told.
+func (this *BeliefExperiment) SetRunner(runner *EpisodeRunner) {
+
+	SetRunner(this.Experiment, runner)
+}
+
+// Run follows the policy until a terminal state is reached.
+func (this *BeliefExperiment) Run(policy Policy) []Outcome {
+
+	this.states = []State{this.state}
+	return this.summarize(this.Experiment.Run(&beliefPolicy{Policy: policy, experiment: this}))
+}
+
+// ForceRun takes an action and then follows the policy until a terminal state is reached.
+func (this *BeliefExperiment) ForceRun(action Action, policy Policy) []Outcome {
+
+	this.states = []State{this.state}
+	this.action = action
+	return this.summarize(this.Experiment.ForceRun(action, &beliefPolicy{Policy: policy, experiment: this}))
+}
+
+// observe updates the belief with the last action taken and the observation that followed it.
+func (this *BeliefExperiment) observe(observation State) {
+
+	this.Belief.Update(this.action, observation)
+	this.state = &SummaryState{Summary: this.Belief.GetState(), Observation: observation}
+	this.states = append(this.states, this.state)
+	this.Environment.addKnownState(this.state)
+}
+
+// summarize replaces the observations in the outcomes of the wrapped experiment with the summaries
+// that the policy saw at each step.
+func (this *BeliefExperiment) summarize(outcomes []Outcome) []Outcome {
+
+	if len(outcomes) == 0 {
+
+		return outcomes
+	}
+
+	// The final observation is never passed to the policy, so the belief is brought up to date here.
+	this.observe(outcomes[len(outcomes)-1].GetFinalState())
+
+	summaries := make([]Outcome, 0)
+	final := this.states[len(this.states)-1]
+	for i, outcome := range outcomes {
+
+		summary := new(BasicOutcome)
+		summary.InitialState = this.states[i]
+		summary.ActionTaken = GetActionTaken(outcome)
+		summary.NextState = this.states[i+1]
+		summary.FinalState = final
+		summary.Truncated = IsTruncated(outcome)
+		summary.BehaviorProbability = GetBehaviorProbability(outcome)
+		summaries = append(summaries, summary)
+	}
+
+	return summaries
+}
+
+// beliefPolicy sits between a wrapped experiment and the policy that's being followed; it updates the
+// belief with each observation and asks the policy for an action given the summary.
+type beliefPolicy struct {
+	Policy
+	experiment *BeliefExperiment
+}
+
+// GetAction updates the belief with the observation, unless it's the first, and returns the action
+// that the policy picks for the summary.
+func (this *beliefPolicy) GetAction(observation State) Action {
+
+	if this.experiment.action != nil {
+
+		this.experiment.observe(observation)
+	}
+
+	action := this.Policy.GetAction(this.experiment.state)
+	this.experiment.action = action
+	return action
+}
+
+// ObservationHistory is a belief that summarizes an experiment as a window of the most recent
+// observations and the actions that led to each of them.  Keys in the summary are prefixed with how
+// many steps ago they were observed, so the latest observation is prefixed with 0.
+type ObservationHistory struct {
+	Length       int
+	Observations []State
+	Actions      []Action
+}
+
+// NewObservationHistory returns a function that creates an ObservationHistory with a window of the
+// given length, which can be used to create a BeliefEnvironment.
+func NewObservationHistory(length int) func(observation State) Belief {
+
+	return func(observation State) Belief {
+
+		history := new(ObservationHistory)
+		history.Length = length
+		history.Observations = []State{observation}
+		history.Actions = []Action{nil}
+		return history
+	}
+}
+
+// Update adds an action and the observation that followed it, dropping the oldest if the window is full.
+func (this *ObservationHistory) Update(action Action, observation State) {
+
+	this.Observations = append(this.Observations, observation)
+	this.Actions = append(this.Actions, action)
+	if len(this.Observations) > this.Length {
+
+		this.Observations = this.Observations[len(this.Observations)-this.Length:]
+		this.Actions = this.Actions[len(this.Actions)-this.Length:]
+	}
+}
+
+// GetState returns the window of observations and actions as a state.
+func (this *ObservationHistory) GetState() State {
+
+	state := NewBasicState()
+	for i := range this.Observations {
+
+		j := len(this.Observations) - 1 - i
+		prefix := strconv.Itoa(i) + "."
+		for k, v := range this.Observations[j].GetContext() {
+
+			state.Context[prefix+k] = v
+		}
+
+		if this.Actions[j] != nil {
+
+			state.Context[prefix+"action"] = this.Actions[j].GetId()
+		}
+	}
+
+	latest := this.Observations[len(this.Observations)-1]
+	state.Terminal = latest.IsTerminal()
+	state.Reward = latest.GetReward()
+
+	return state
+}
+
+// BayesianBelief is a belief that keeps a probability distribution over the hidden states, updated
+// with Bayes' rule from a model of the hidden dynamics and a model of the observations.  The summary
+// has the probability of every hidden state that's still possible, rounded to the resolution, so that
+// beliefs that are nearly the same are treated as the same state.
+type BayesianBelief struct {
+	Model        Model
+	Observations ObservationModel
+	Resolution   float64
+	States       map[string]State
+	Distribution map[string]float64
+	latest       State
+}
+
+// NewBayesianBelief returns a function that creates a BayesianBelief, starting from a uniform prior
+// over the given hidden states, which can be used to create a BeliefEnvironment.  Probabilities are
+// rounded to tenths by default.
+func NewBayesianBelief(model Model, observations ObservationModel, prior []State) func(observation State) Belief {
+
+	return func(observation State) Belief {
+
+		belief := new(BayesianBelief)
+		belief.Model = model
+		belief.Observations = observations
+		belief.Resolution = 0.1
+		belief.States = make(map[string]State)
+		belief.Distribution = make(map[string]float64)
+		for _, state := range prior {
+
+			belief.States[state.GetId()] = state
+			belief.Distribution[state.GetId()] = 1 / float64(len(prior))
+		}
+
+		belief.condition(nil, observation)
+		return belief
+	}
+}
+
+// Update moves the distribution forward thru the model for the action, and then conditions it on the
+// observation.
+func (this *BayesianBelief) Update(action Action, observation State) {
+
+	states := make(map[string]State)
+	distribution := make(map[string]float64)
+	for id, p := range this.Distribution {
+
+		for _, successor := range this.Model.GetSuccessors(this.States[id], action) {
+
+			next := successor.State.GetId()
+			states[next] = successor.State
+			distribution[next] += p * successor.Probability
+		}
+	}
+
+	// Terminal hidden states have no successors, in which case there's nothing left to update.
+	if len(distribution) > 0 {
+
+		this.States = states
+		this.Distribution = distribution
+	}
+
+	this.condition(action, observation)
+}
+
+// condition weighs the distribution by how likely the observation was in each hidden state.  If the
+// observation wasn't possible in any of them the distribution is left alone.
+func (this *BayesianBelief) condition(action Action, observation State) {
+
+	this.latest = observation
+	total := 0.0
+	posterior := make(map[string]float64)
+	for id, p := range this.Distribution {
+
+		posterior[id] = p * this.Observations.GetObservationProbability(action, this.States[id], observation)
+		total += posterior[id]
+	}
+
+	if total <= 0 {
+
+		return
+	}
+
+	for id, p := range posterior {
+
+		if p > 0 {
+
+			posterior[id] = p / total
+
+		} else {
+
+			delete(posterior, id)
+			delete(this.States, id)
+		}
+	}
+
+	this.Distribution = posterior
+}
+
+// GetProbability returns the probability of a hidden state.
+func (this *BayesianBelief) GetProbability(state State) float64 {
+
+	return this.Distribution[state.GetId()]
+}
+
+// GetState returns the rounded distribution as a state.
+func (this *BayesianBelief) GetState() State {
+
+	digits := int(math.Max(0, math.Ceil(-math.Log10(this.Resolution))))
+
+	state := NewBasicState()
+	for id, p := range this.Distribution {
+
+		rounded := math.Round(p/this.Resolution) * this.Resolution
+		state.Context[id] = strconv.FormatFloat(rounded, 'f', digits, 64)
+	}
+
+	state.Terminal = this.latest.IsTerminal()
+	state.Reward = this.latest.GetReward()
+
+	return state
+}
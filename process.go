@@ -0,0 +1,416 @@
+package monoikos
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"sync"
+	"time"
+)
+
+// DefaultProcessTimeout is how long a process environment waits for the process to answer a request,
+// unless it's given a timeout of its own.
+const DefaultProcessTimeout = 30 * time.Second
+
+// ProcessEnvironment is an Environment that's implemented by another process, such as a simulator
+// written in another language, which it talks to over the process's stdin and stdout.  Every request
+// is a single line of JSON, and the process answers every request other than close with a single line
+// of JSON.
+//
+//	{"command": "reset"}                            => {"state": {...}}
+//	{"command": "observe"}                          => {"state": {...}}
+//	{"command": "legal_actions", "state": {...}}    => {"actions": ["...", ...]}
+//	{"command": "step", "action": "..."}            => {"state": {...}}
+//	{"command": "close"}
+//
+// A state is {"context": {...}, "terminal": false, "reward": 0}, where the context holds strings and
+// the reward is the total that's been paid out so far in the episode.  Reset starts a new episode and
+// answers with its first state, observe answers with the current state, and step takes an action in
+// the current state and answers with the next one.  Legal actions can be asked for about any state,
+// not just the current one.  Any request can instead be answered with {"error": "..."}.
+//
+// There's a single process, so experiments share it and have to be run one at a time.  The known
+// states are the non-terminal states that have been visited so far.  Anything the process writes to
+// stderr is passed on to Stderr.  If the process doesn't answer a request within the timeout, where
+// zero means waiting forever, it's killed, since a late answer would be mistaken for the answer to the
+// next request, and every request after that fails.
+type ProcessEnvironment struct {
+	KnownStates map[string]State
+	Path        string
+	Args        []string
+	Stderr      io.Writer
+	Timeout     time.Duration
+	command     *exec.Cmd
+	stdin       io.WriteCloser
+	pipe        io.ReadCloser
+	stdout      *bufio.Reader
+	err         error
+	mutex       sync.Mutex
+	statesMutex sync.Mutex
+}
+
+// processState is a state as it's sent to and from the process.
+type processState struct {
+	Context  map[string]string `json:"context"`
+	Terminal bool              `json:"terminal"`
+	Reward   int               `json:"reward"`
+}
+
+// processRequest is a request to the process.
+type processRequest struct {
+	Command string        `json:"command"`
+	Action  string        `json:"action,omitempty"`
+	State   *processState `json:"state,omitempty"`
+}
+
+// processResponse is the process's answer to a request.
+type processResponse struct {
+	State   *processState `json:"state,omitempty"`
+	Actions []string      `json:"actions,omitempty"`
+	Error   string        `json:"error,omitempty"`
+}
+
+// NewProcessEnvironment should be used to create a ProcessEnvironment; it passes anything the process
+// writes to stderr thru to os.Stderr, and starts with the default timeout.  The process isn't started
+// until Start is called.
+func NewProcessEnvironment(path string, args ...string) *ProcessEnvironment {
+
+	environment := new(ProcessEnvironment)
+	environment.KnownStates = make(map[string]State)
+	environment.Path = path
+	environment.Args = args
+	environment.Stderr = os.Stderr
+	environment.Timeout = DefaultProcessTimeout
+
+	return environment
+}
+
+// StartProcessEnvironment starts a process and creates an environment that talks to it, with the
+// settings from NewProcessEnvironment.  The process should be closed with Close once the environment
+// isn't needed any more.
+func StartProcessEnvironment(path string, args ...string) (*ProcessEnvironment, error) {
+
+	return StartProcessEnvironmentContext(context.Background(), path, args...)
+}
+
+// StartProcessEnvironmentContext starts a process in the same way as StartProcessEnvironment, but kills
+// it if the context is cancelled or its deadline passes, so that a request that's waiting on it fails
+// rather than waiting forever.
+func StartProcessEnvironmentContext(ctx context.Context, path string, args ...string) (*ProcessEnvironment, error) {
+
+	environment := NewProcessEnvironment(path, args...)
+	err := environment.Start(ctx)
+	if err != nil {
+
+		return nil, err
+	}
+
+	return environment, nil
+}
+
+// Start starts the process, which is killed if the context is cancelled or its deadline passes.
+func (this *ProcessEnvironment) Start(ctx context.Context) error {
+
+	this.command = exec.CommandContext(ctx, this.Path, this.Args...)
+	this.command.Stderr = this.Stderr
+
+	stdin, err := this.command.StdinPipe()
+	if err != nil {
+
+		return err
+	}
+
+	stdout, err := this.command.StdoutPipe()
+	if err != nil {
+
+		return err
+	}
+
+	err = this.command.Start()
+	if err != nil {
+
+		return fmt.Errorf("monoikos: couldn't start the process: %w", err)
+	}
+
+	this.stdin = stdin
+	this.pipe = stdout
+	this.stdout = bufio.NewReader(stdout)
+	return nil
+}
+
+// Close asks the process to close, and waits for it to exit.
+func (this *ProcessEnvironment) Close() error {
+
+	this.mutex.Lock()
+	defer this.mutex.Unlock()
+
+	b, _ := json.Marshal(&processRequest{Command: "close"})
+	this.stdin.Write(append(b, '\n'))
+	this.stdin.Close()
+	return this.command.Wait()
+}
+
+// CreateRandomPolicy creates a random policy.
+func (this *ProcessEnvironment) CreateRandomPolicy() Policy {
+
+	return CreateRandomPolicy(this)
+}
+
+// CreateImprovedPolicy creates an improved policy from a set of outcomes.
+func (this *ProcessEnvironment) CreateImprovedPolicy(outcomes []Outcome) Policy {
+
+	return CreateImprovedPolicy(this, outcomes)
+}
+
+// CreateOptimizedPolicy creates an optimized policy.
+func (this *ProcessEnvironment) CreateOptimizedPolicy(initialRandomizationRate int, experimentsPerIteration int, iterations int) Policy {
+
+	return CreateOptimizedPolicy(this, initialRandomizationRate, experimentsPerIteration, iterations)
+}
+
+// CreateExperiment creates an experiment, which resets the process when its state is first observed.
+func (this *ProcessEnvironment) CreateExperiment() Experiment {
+
+	return &ProcessExperiment{Environment: this}
+}
+
+// GetLegalActions asks the process for the legal actions in a state, and panics if it can't answer.
+func (this *ProcessEnvironment) GetLegalActions(state State) []Action {
+
+	request := &processRequest{Command: "legal_actions", State: &processState{Context: state.GetContext(), Terminal: state.IsTerminal(), Reward: state.GetReward()}}
+	response, err := this.send(request)
+	if err != nil {
+
+		panic(err)
+	}
+
+	actions := make([]Action, 0)
+	for _, id := range response.Actions {
+
+		actions = append(actions, &ProcessAction{Id: id})
+	}
+
+	return actions
+}
+
+// GetKnownStates returns the non-terminal states that have been visited so far.
+func (this *ProcessEnvironment) GetKnownStates() []State {
+
+	this.statesMutex.Lock()
+	defer this.statesMutex.Unlock()
+
+	states := make([]State, 0)
+	for _, state := range this.KnownStates {
+
+		states = append(states, state)
+	}
+
+	return states
+}
+
+// sendState sends a request that's answered with a state, and records the state as known if it isn't
+// terminal.
+func (this *ProcessEnvironment) sendState(request *processRequest) (State, error) {
+
+	response, err := this.send(request)
+	if err != nil {
+
+		return nil, err
+	}
+
+	if response.State == nil {
+
+		return nil, errors.New("monoikos: the process didn't answer '" + request.Command + "' with a state")
+	}
+
+	state := NewBasicState()
+	for k, v := range response.State.Context {
+
+		state.Context[k] = v
+	}
+
+	state.Terminal = response.State.Terminal
+	state.Reward = response.State.Reward
+	if !state.Terminal {
+
+		this.statesMutex.Lock()
+		if _, ok := this.KnownStates[state.GetId()]; !ok {
+
+			this.KnownStates[state.GetId()] = state
+		}
+		this.statesMutex.Unlock()
+	}
+
+	return state, nil
+}
+
+// send writes a request to the process and reads its answer, waiting for no longer than the timeout.
+// If the process doesn't answer in time it's killed, and the error is returned for every request
+// after that.
+func (this *ProcessEnvironment) send(request *processRequest) (*processResponse, error) {
+
+	this.mutex.Lock()
+	defer this.mutex.Unlock()
+
+	if this.err != nil {
+
+		return nil, this.err
+	}
+
+	b, err := json.Marshal(request)
+	if err != nil {
+
+		return nil, fmt.Errorf("monoikos: couldn't encode '%v' for the process: %w", request.Command, err)
+	}
+
+	_, err = this.stdin.Write(append(b, '\n'))
+	if err != nil {
+
+		return nil, fmt.Errorf("monoikos: couldn't send '%v' to the process: %w", request.Command, err)
+	}
+
+	this.setDeadline()
+	line, err := this.stdout.ReadBytes('\n')
+	if errors.Is(err, os.ErrDeadlineExceeded) {
+
+		this.command.Process.Kill()
+		this.err = fmt.Errorf("monoikos: the process didn't answer '%v' within %v", request.Command, this.Timeout)
+		return nil, this.err
+
+	} else if err == io.EOF && len(line) == 0 {
+
+		return nil, errors.New("monoikos: the process closed without answering '" + request.Command + "'")
+
+	} else if err != nil && err != io.EOF {
+
+		return nil, fmt.Errorf("monoikos: couldn't read the answer to '%v' from the process: %w", request.Command, err)
+	}
+
+	response := new(processResponse)
+	err = json.Unmarshal(line, response)
+	if err != nil {
+
+		return nil, fmt.Errorf("monoikos: the process's answer to '%v' isn't valid: %w", request.Command, err)
+	}
+
+	if response.Error != "" {
+
+		return nil, errors.New("monoikos: the process couldn't answer '" + request.Command + "': " + response.Error)
+	}
+
+	return response, nil
+}
+
+// setDeadline sets how long the next answer from the process is waited for, if the pipe it's read from
+// supports deadlines.
+func (this *ProcessEnvironment) setDeadline() {
+
+	if file, ok := this.pipe.(*os.File); ok {
+
+		deadline := time.Time{}
+		if this.Timeout > 0 {
+
+			deadline = time.Now().Add(this.Timeout)
+		}
+
+		file.SetReadDeadline(deadline)
+	}
+}
+
+// ProcessAction is an action in a process environment, which is known only by its identifier.  Running
+// it does nothing, since it's applied by the process.
+type ProcessAction struct {
+	Id string
+}
+
+// GetId returns the identifier.
+func (this *ProcessAction) GetId() string {
+
+	return this.Id
+}
+
+// Run does nothing, since the action is applied by the process.
+func (this *ProcessAction) Run(context map[string]interface{}) {
+}
+
+// ProcessExperiment is a single episode in a process environment.  The process is reset when the
+// state is first observed, and actions are applied by asking it to step.
+type ProcessExperiment struct {
+	Environment *ProcessEnvironment
+	started     bool
+	runnable
+}
+
+// ObserveState returns the current state of the process, resetting it first if this is the start of
+// the experiment, and panics if the process can't answer.
+func (this *ProcessExperiment) ObserveState() State {
+
+	command := "observe"
+	if !this.started {
+
+		this.started = true
+		command = "reset"
+	}
+
+	state, err := this.Environment.sendState(&processRequest{Command: command})
+	if err != nil {
+
+		panic(err)
+	}
+
+	return state
+}
+
+// Apply asks the process to take an action, and panics if it fails.
+func (this *ProcessExperiment) Apply(action Action) {
+
+	err := this.TryApply(action)
+	if err != nil {
+
+		panic(err)
+	}
+}
+
+// TryApply asks the process to take an action, and returns an ActionError if it fails.
+func (this *ProcessExperiment) TryApply(action Action) error {
+
+	_, err := this.Environment.send(&processRequest{Command: "step", Action: action.GetId()})
+	if err != nil {
+
+		return &ActionError{Action: action, Err: err}
+	}
+
+	return nil
+}
+
+// Run follows the policy until a terminal state is reached, or the experiment is truncated.
+func (this *ProcessExperiment) Run(policy Policy) []Outcome {
+
+	return this.getRunner().Run(this, policy)
+}
+
+// ForceRun takes an action and then follows the policy until a terminal state is reached, or the
+// experiment is truncated.
+func (this *ProcessExperiment) ForceRun(action Action, policy Policy) []Outcome {
+
+	return this.getRunner().ForceRun(this, action, policy)
+}
+
+// TryRun follows the policy in the same way as Run, but returns an error if the experiment fails,
+// including when the process can't answer.
+func (this *ProcessExperiment) TryRun(policy Policy) ([]Outcome, error) {
+
+	return this.TryForceRun(nil, policy)
+}
+
+// TryForceRun takes an action and then follows the policy in the same way as ForceRun, but returns an
+// error if the experiment fails, including when the process can't answer.
+func (this *ProcessExperiment) TryForceRun(action Action, policy Policy) ([]Outcome, error) {
+
+	return tryRun(func() []Outcome { return this.getRunner().ForceRun(this, action, policy) })
+}
@@ -0,0 +1,289 @@
+package monoikos
+
+import (
+	"errors"
+	"io/ioutil"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// EnvironmentFactory creates an environment from a set of options, which are named string values whose
+// meaning is up to the environment.
+type EnvironmentFactory func(options map[string]string) (Environment, error)
+
+// environments are the registered environment factories by name.
+var environments = make(map[string]EnvironmentFactory)
+var environmentsMutex sync.Mutex
+
+// RegisterEnvironment registers an environment factory under a name, replacing any factory that was
+// already registered under it, so that tools can create environments by name.  Packages that provide
+// their own environments can register them when they're initialized.
+func RegisterEnvironment(name string, factory EnvironmentFactory) {
+
+	environmentsMutex.Lock()
+	defer environmentsMutex.Unlock()
+
+	environments[name] = factory
+}
+
+// CreateEnvironment creates the environment registered under a name with a set of options.
+func CreateEnvironment(name string, options map[string]string) (Environment, error) {
+
+	environmentsMutex.Lock()
+	factory, ok := environments[name]
+	environmentsMutex.Unlock()
+
+	if !ok {
+
+		return nil, errors.New("monoikos: no environment registered as '" + name + "'")
+	}
+
+	if options == nil {
+
+		options = make(map[string]string)
+	}
+
+	return factory(options)
+}
+
+// GetEnvironmentNames returns the names of the registered environments in order.
+func GetEnvironmentNames() []string {
+
+	environmentsMutex.Lock()
+	defer environmentsMutex.Unlock()
+
+	names := make([]string, 0)
+	for name := range environments {
+
+		names = append(names, name)
+	}
+
+	sort.Strings(names)
+	return names
+}
+
+// defaultGridworldMap is the map used by the registered gridworld when no map file is given.
+var defaultGridworldMap = `
+S..G
+.#.P
+....
+`
+
+// The built in environments are registered under their own names.  Options are:
+//
+//	bandit: probabilities, a comma separated list of the chance each arm pays out one.
+//	blackjack, counting-blackjack: the house rules, which are decks, penetration, hits-soft-17, payout,
+//	  double-after-split, max-split-hands, surrender, insurance and bet, each defaulting to the
+//	  typical six deck shoe game.
+//	gridworld: map, the path of a file with an ASCII map.
+//	process: command, the command line of a process that speaks the ProcessEnvironment protocol.
+//	tabular: file, the path of a JSON or YAML description of the MDP.
+//	tiger: history, the number of observations the agent remembers.
+//	tictactoe: seat, which player the agent is, against players that move at random.
+func init() {
+
+	RegisterEnvironment("bandit", func(options map[string]string) (Environment, error) {
+
+		arms := make([]Arm, 0)
+		for _, p := range strings.Split(getOption(options, "probabilities", "0.2,0.5,0.8"), ",") {
+
+			probability, err := strconv.ParseFloat(strings.TrimSpace(p), 64)
+			if err != nil {
+
+				return nil, err
+			}
+
+			arms = append(arms, &BernoulliArm{Probability: probability})
+		}
+
+		return NewBandit(arms...), nil
+	})
+
+	RegisterEnvironment("blackjack", func(options map[string]string) (Environment, error) {
+
+		rules, err := parseBlackjackRules(options)
+		if err != nil {
+
+			return nil, err
+		}
+
+		return NewBlackjackEnvironment(rules), nil
+	})
+
+	RegisterEnvironment("counting-blackjack", func(options map[string]string) (Environment, error) {
+
+		rules, err := parseBlackjackRules(options)
+		if err != nil {
+
+			return nil, err
+		}
+
+		return NewCountingBlackjackEnvironment(rules, nil), nil
+	})
+
+	RegisterEnvironment("gridworld", func(options map[string]string) (Environment, error) {
+
+		m := defaultGridworldMap
+		if path, ok := options["map"]; ok {
+
+			b, err := ioutil.ReadFile(path)
+			if err != nil {
+
+				return nil, err
+			}
+
+			m = string(b)
+		}
+
+		return NewGridworld(m)
+	})
+
+	RegisterEnvironment("process", func(options map[string]string) (Environment, error) {
+
+		command := strings.Fields(options["command"])
+		if len(command) == 0 {
+
+			return nil, errors.New("monoikos: the process environment needs a command option")
+		}
+
+		return StartProcessEnvironment(command[0], command[1:]...)
+	})
+
+	RegisterEnvironment("tabular", func(options map[string]string) (Environment, error) {
+
+		path, ok := options["file"]
+		if !ok {
+
+			return nil, errors.New("monoikos: the tabular environment needs a file option")
+		}
+
+		mdp, err := LoadTabularMDP(path)
+		if err != nil {
+
+			return nil, err
+		}
+
+		return mdp, nil
+	})
+
+	RegisterEnvironment("tiger", func(options map[string]string) (Environment, error) {
+
+		length, err := strconv.Atoi(getOption(options, "history", "2"))
+		if err != nil {
+
+			return nil, err
+		}
+
+		return NewBeliefEnvironment(NewTiger(), NewObservationHistory(length)), nil
+	})
+
+	RegisterEnvironment("tictactoe", func(options map[string]string) (Environment, error) {
+
+		seat, err := strconv.Atoi(getOption(options, "seat", "0"))
+		if err != nil || seat < 0 || seat > 1 {
+
+			return nil, errors.New("monoikos: the tictactoe seat has to be 0 or 1")
+		}
+
+		game := NewTicTacToe()
+		random := NewUniformRandomPolicy(game)
+		return NewGameSeat(game, seat, []Policy{random, random}), nil
+	})
+}
+
+// parseBlackjackRules returns the house rules in a set of options, starting from the default rules, and
+// returns an error if any of them can't be parsed or don't make sense.
+func parseBlackjackRules(options map[string]string) (*BlackjackRules, error) {
+
+	rules := NewBlackjackRules()
+	errs := []error{
+		parseIntOption(options, "decks", &rules.Decks),
+		parseFloatOption(options, "penetration", &rules.Penetration),
+		parseBoolOption(options, "hits-soft-17", &rules.DealerHitsSoft17),
+		parseFloatOption(options, "payout", &rules.BlackjackPayout),
+		parseBoolOption(options, "double-after-split", &rules.DoubleAfterSplit),
+		parseIntOption(options, "max-split-hands", &rules.MaxSplitHands),
+		parseBoolOption(options, "surrender", &rules.LateSurrender),
+		parseBoolOption(options, "insurance", &rules.Insurance),
+		parseIntOption(options, "bet", &rules.Bet),
+	}
+
+	for _, err := range errs {
+
+		if err != nil {
+
+			return nil, err
+		}
+	}
+
+	if rules.Decks < 1 || rules.Penetration <= 0 || rules.Penetration > 1 || rules.MaxSplitHands < 1 || rules.Bet < 1 {
+
+		return nil, errors.New("monoikos: blackjack needs a deck, a penetration between 0 and 1, a hand and a bet")
+	}
+
+	return rules, nil
+}
+
+// parseIntOption sets a value from an option if it's set, and returns an error if it isn't a whole number.
+func parseIntOption(options map[string]string, key string, value *int) error {
+
+	if v, ok := options[key]; ok {
+
+		i, err := strconv.Atoi(v)
+		if err != nil {
+
+			return errors.New("monoikos: the " + key + " option has to be a whole number")
+		}
+
+		*value = i
+	}
+
+	return nil
+}
+
+// parseFloatOption sets a value from an option if it's set, and returns an error if it isn't a number.
+func parseFloatOption(options map[string]string, key string, value *float64) error {
+
+	if v, ok := options[key]; ok {
+
+		f, err := strconv.ParseFloat(v, 64)
+		if err != nil {
+
+			return errors.New("monoikos: the " + key + " option has to be a number")
+		}
+
+		*value = f
+	}
+
+	return nil
+}
+
+// parseBoolOption sets a value from an option if it's set, and returns an error if it isn't true or false.
+func parseBoolOption(options map[string]string, key string, value *bool) error {
+
+	if v, ok := options[key]; ok {
+
+		b, err := strconv.ParseBool(v)
+		if err != nil {
+
+			return errors.New("monoikos: the " + key + " option has to be true or false")
+		}
+
+		*value = b
+	}
+
+	return nil
+}
+
+// getOption returns the value of an option, or a default if it isn't set.
+func getOption(options map[string]string, key string, value string) string {
+
+	if v, ok := options[key]; ok {
+
+		return v
+	}
+
+	return value
+}
@@ -0,0 +1,347 @@
+package monoikos
+
+import (
+	"encoding/json"
+	"errors"
+	"io/ioutil"
+	"math"
+	"math/rand"
+)
+
+// Transition is a single step taken during an experiment; the state that was observed, the action that
+// was taken from it, the reward that was paid out for taking it, and the state that followed.
+type Transition struct {
+	State     State
+	Action    Action
+	Reward    int
+	NextState State
+	Terminal  bool
+	Truncated bool
+}
+
+// GetTransitions breaks the outcomes of a single experiment run down into transitions.  The next state
+// recorded on each outcome is used when it's there; otherwise outcomes are expected to be in the order
+// they were generated, so the next state for each step is the initial state of the outcome that
+// follows it, and the next state for the last step is the final state.  States report the reward that
+// has been paid out so far, so the reward for a step is the difference between what was paid out
+// before and after it.  The last step of a truncated run is marked as truncated rather than terminal.
+func GetTransitions(outcomes []Outcome) []*Transition {
+
+	transitions := make([]*Transition, 0)
+	for i, outcome := range outcomes {
+
+		// Work out what state came next if it wasn't recorded, which is only the final state at the
+		// end of the run.
+		next := GetNextState(outcome)
+		if next == nil && i < len(outcomes)-1 {
+
+			next = outcomes[i+1].GetInitialState()
+
+		} else if next == nil {
+
+			next = outcome.GetFinalState()
+		}
+
+		transition := new(Transition)
+		transition.State = outcome.GetInitialState()
+		transition.Action = GetActionTaken(outcome)
+		transition.Reward = next.GetReward() - transition.State.GetReward()
+		transition.NextState = next
+		transition.Terminal = next.IsTerminal()
+		transition.Truncated = i == len(outcomes)-1 && IsTruncated(outcome)
+		transitions = append(transitions, transition)
+	}
+
+	return transitions
+}
+
+// ReplayBuffer keeps a bounded history of transitions so that learners can reuse experience rather than
+// throwing it away after a single policy improvement.  Once the buffer reaches capacity the oldest
+// transition is evicted to make room for each new one.  Transitions can be sampled uniformly, or
+// in proportion to a priority that's typically derived from their temporal difference error.
+type ReplayBuffer struct {
+	Capacity    int
+	Alpha       float64
+	Epsilon     float64
+	Transitions []*Transition
+	Priorities  []float64
+	next        int
+}
+
+// NewReplayBuffer should be used to create a ReplayBuffer; it handles instantiating members appropriately.
+func NewReplayBuffer(capacity int) *ReplayBuffer {
+
+	buffer := new(ReplayBuffer)
+	buffer.Capacity = capacity
+	buffer.Alpha = 0.6
+	buffer.Epsilon = 0.01
+	buffer.Transitions = make([]*Transition, 0)
+	buffer.Priorities = make([]float64, 0)
+
+	return buffer
+}
+
+// Len returns the number of transitions currently held in the buffer.
+func (this *ReplayBuffer) Len() int {
+
+	return len(this.Transitions)
+}
+
+// Add puts a transition into the buffer, evicting the oldest transition if the buffer is full.  New
+// transitions are given the highest priority in the buffer so that they're sampled at least once
+// before their temporal difference error is known.
+func (this *ReplayBuffer) Add(transition *Transition) {
+
+	this.addWithPriority(transition, this.getMaxPriority())
+}
+
+// addWithPriority puts a transition into the buffer with the given priority, evicting the oldest
+// transition if the buffer is full.
+func (this *ReplayBuffer) addWithPriority(transition *Transition, priority float64) {
+
+	if this.Capacity <= 0 {
+
+		return
+	}
+
+	// If there's still room, just append the transition to the end.
+	if len(this.Transitions) < this.Capacity {
+
+		this.Transitions = append(this.Transitions, transition)
+		this.Priorities = append(this.Priorities, priority)
+		return
+	}
+
+	// Otherwise overwrite the oldest transition and move on to the next oldest.
+	this.Transitions[this.next] = transition
+	this.Priorities[this.next] = priority
+	this.next = (this.next + 1) % this.Capacity
+}
+
+// AddOutcomes breaks the outcomes of a single experiment run into transitions and adds each of them.
+func (this *ReplayBuffer) AddOutcomes(outcomes []Outcome) {
+
+	for _, transition := range GetTransitions(outcomes) {
+
+		this.Add(transition)
+	}
+}
+
+// Sample returns n transitions picked uniformly at random (with replacement) from the buffer.
+func (this *ReplayBuffer) Sample(n int) []*Transition {
+
+	transitions := make([]*Transition, 0)
+	if len(this.Transitions) == 0 {
+
+		return transitions
+	}
+
+	for i := 0; i < n; i++ {
+
+		k := rand.Intn(len(this.Transitions))
+		transitions = append(transitions, this.Transitions[k])
+	}
+
+	return transitions
+}
+
+// SamplePrioritized returns n transitions picked at random (with replacement) in proportion to their
+// priorities, along with their indexes in the buffer so that priorities can be updated afterwards.
+func (this *ReplayBuffer) SamplePrioritized(n int) ([]*Transition, []int) {
+
+	transitions := make([]*Transition, 0)
+	indexes := make([]int, 0)
+	if len(this.Transitions) == 0 {
+
+		return transitions, indexes
+	}
+
+	total := 0.0
+	for _, priority := range this.Priorities {
+
+		total += priority
+	}
+
+	// Walk the priorities until the running total passes a random point for each sample.
+	for i := 0; i < n; i++ {
+
+		k := len(this.Priorities) - 1
+		r := rand.Float64() * total
+		for j, priority := range this.Priorities {
+
+			r -= priority
+			if r < 0 {
+
+				k = j
+				break
+			}
+		}
+
+		transitions = append(transitions, this.Transitions[k])
+		indexes = append(indexes, k)
+	}
+
+	return transitions, indexes
+}
+
+// UpdatePriority sets the priority of the transition at an index based on its latest temporal difference
+// error.  Indexes stay valid until the transition they refer to is evicted.
+func (this *ReplayBuffer) UpdatePriority(index int, tdError float64) {
+
+	if index < 0 || index >= len(this.Priorities) {
+
+		return
+	}
+
+	this.Priorities[index] = math.Pow(math.Abs(tdError)+this.Epsilon, this.Alpha)
+}
+
+// getMaxPriority returns the highest priority in the buffer, or one if the buffer is empty.
+func (this *ReplayBuffer) getMaxPriority() float64 {
+
+	max := 1.0
+	for _, priority := range this.Priorities {
+
+		if priority > max {
+
+			max = priority
+		}
+	}
+
+	return max
+}
+
+// savedState is the on disk representation of a state.
+type savedState struct {
+	Context  map[string]string `json:"context"`
+	Terminal bool              `json:"terminal"`
+	Reward   int               `json:"reward"`
+}
+
+// savedTransition is the on disk representation of a transition; actions are stored by identifier.
+type savedTransition struct {
+	State     savedState `json:"state"`
+	Action    string     `json:"action"`
+	Reward    int        `json:"reward"`
+	NextState savedState `json:"next_state"`
+	Terminal  bool       `json:"terminal"`
+	Truncated bool       `json:"truncated,omitempty"`
+	Priority  float64    `json:"priority"`
+}
+
+// savedReplayBuffer is the on disk representation of a replay buffer.
+type savedReplayBuffer struct {
+	Capacity    int               `json:"capacity"`
+	Alpha       float64           `json:"alpha"`
+	Epsilon     float64           `json:"epsilon"`
+	Transitions []savedTransition `json:"transitions"`
+}
+
+// Save writes the buffer to a JSON file at path, oldest transition first.  Actions are saved by
+// identifier, so it returns an error without writing anything if a transition has no action, such as
+// one from an outcome that couldn't say which action was taken.
+func (this *ReplayBuffer) Save(path string) error {
+
+	saved := savedReplayBuffer{Capacity: this.Capacity, Alpha: this.Alpha, Epsilon: this.Epsilon}
+	saved.Transitions = make([]savedTransition, 0)
+
+	// Start from the oldest transition, which is only at the front until the buffer wraps.
+	for i := 0; i < len(this.Transitions); i++ {
+
+		k := (this.next + i) % len(this.Transitions)
+		transition := this.Transitions[k]
+		if transition.Action == nil {
+
+			return errors.New("monoikos: a transition from '" + transition.State.GetId() + "' has no action to save")
+		}
+
+		s := savedTransition{}
+		s.State = saveState(transition.State)
+		s.Action = transition.Action.GetId()
+		s.Reward = transition.Reward
+		s.NextState = saveState(transition.NextState)
+		s.Terminal = transition.Terminal
+		s.Truncated = transition.Truncated
+		s.Priority = this.Priorities[k]
+		saved.Transitions = append(saved.Transitions, s)
+	}
+
+	b, err := json.Marshal(saved)
+	if err != nil {
+
+		return err
+	}
+
+	return ioutil.WriteFile(path, b, 0644)
+}
+
+// LoadReplayBuffer reads a buffer that was written with Save.  States are restored as basic states, and
+// actions are restored by matching their identifiers against the legal actions in the environment.
+func LoadReplayBuffer(environment Environment, path string) (*ReplayBuffer, error) {
+
+	b, err := ioutil.ReadFile(path)
+	if err != nil {
+
+		return nil, err
+	}
+
+	saved := savedReplayBuffer{}
+	err = json.Unmarshal(b, &saved)
+	if err != nil {
+
+		return nil, err
+	}
+
+	buffer := NewReplayBuffer(saved.Capacity)
+	buffer.Alpha = saved.Alpha
+	buffer.Epsilon = saved.Epsilon
+	for _, s := range saved.Transitions {
+
+		transition := new(Transition)
+		transition.State = loadState(s.State)
+		transition.Reward = s.Reward
+		transition.NextState = loadState(s.NextState)
+		transition.Terminal = s.Terminal
+		transition.Truncated = s.Truncated
+
+		// Find the action with a matching identifier.
+		for _, action := range environment.GetLegalActions(transition.State) {
+
+			if action.GetId() == s.Action {
+
+				transition.Action = action
+				break
+			}
+		}
+
+		if transition.Action == nil {
+
+			return nil, errors.New("monoikos: no legal action '" + s.Action + "' for state " + transition.State.GetId())
+		}
+
+		buffer.addWithPriority(transition, s.Priority)
+	}
+
+	return buffer, nil
+}
+
+// saveState converts a state to its on disk representation.
+func saveState(state State) savedState {
+
+	return savedState{Context: state.GetContext(), Terminal: state.IsTerminal(), Reward: state.GetReward()}
+}
+
+// loadState converts a state from its on disk representation.
+func loadState(saved savedState) State {
+
+	state := NewBasicState()
+	for k, v := range saved.Context {
+
+		state.Context[k] = v
+	}
+
+	state.Terminal = saved.Terminal
+	state.Reward = saved.Reward
+
+	return state
+}
@@ -0,0 +1,406 @@
+package monoikos
+
+// DefaultMaxSteps is the number of steps after which new episode runners truncate experiments, unless
+// the experiments have a limit of their own.  It stops a policy that never reaches a terminal state,
+// such as one that never chooses to stop, from running forever.
+const DefaultMaxSteps = 10000
+
+// StepExperiment is the least that an experiment has to do to be run by an EpisodeRunner; it has to be
+// able to report its current state, and to apply an action to move on to the next one.  Everything
+// else that an Experiment does is handled by the runner, and NewExperiment turns a StepExperiment into
+// a full Experiment.
+type StepExperiment interface {
+	ObserveState() State
+	Apply(Action)
+}
+
+// TruncatingStepExperiment is a step experiment that can cut itself short, such as one with a time
+// limit of its own, rather than only being truncated by the runner's step limit.
+type TruncatingStepExperiment interface {
+	StepExperiment
+	IsTruncated() bool
+}
+
+// RunnerExperiment is an experiment that can be told which episode runner to run with, so that whoever
+// is running it, such as training, decides the step limit and the hooks.  Experiments that haven't been
+// told use a new EpisodeRunner.
+type RunnerExperiment interface {
+	Experiment
+	SetRunner(*EpisodeRunner)
+}
+
+// SetRunner tells an experiment which episode runner to run with, and returns whether it could be told.
+func SetRunner(experiment Experiment, runner *EpisodeRunner) bool {
+
+	if settable, ok := experiment.(RunnerExperiment); ok {
+
+		settable.SetRunner(runner)
+		return true
+	}
+
+	return false
+}
+
+// EpisodeHook is told about every step as an experiment is run, and about every outcome once it's over.
+// The outcome for a step has its next state but not yet its final state.
+type EpisodeHook interface {
+	OnStep(Outcome)
+	OnEpisode([]Outcome)
+}
+
+// TruncatedOutcome is an outcome that can report whether the experiment it came from was cut short by
+// a step limit rather than ending in a terminal state.
+type TruncatedOutcome interface {
+	Outcome
+	IsTruncated() bool
+}
+
+// IsTruncated returns whether an outcome came from an experiment that was truncated.  Outcomes that
+// can't report it are assumed to have ended in a terminal state.
+func IsTruncated(outcome Outcome) bool {
+
+	if truncated, ok := outcome.(TruncatedOutcome); ok {
+
+		return truncated.IsTruncated()
+	}
+
+	return false
+}
+
+// BehaviorOutcome is an outcome that can report how likely the policy that was followed was to take
+// its action, which is what off-policy learners and audits of what a policy did need to know.
+type BehaviorOutcome interface {
+	Outcome
+	GetBehaviorProbability() float64
+}
+
+// GetBehaviorProbability returns how likely the policy was to take an outcome's action, or zero if the
+// outcome or the policy couldn't say.
+func GetBehaviorProbability(outcome Outcome) float64 {
+
+	if behavior, ok := outcome.(BehaviorOutcome); ok {
+
+		return behavior.GetBehaviorProbability()
+	}
+
+	return 0
+}
+
+// ProbabilisticPolicy is a policy that can say how likely it is to pick each action in a state, keyed
+// by action identifier, before it picks one.
+type ProbabilisticPolicy interface {
+	Policy
+	GetActionProbabilities(State) map[string]float64
+}
+
+// EpisodeRunner walks step experiments from their current state until they reach a terminal state, or
+// until they've taken the maximum number of steps, and builds the outcomes along the way.  If it's
+// given an environment, every action is checked against the legal actions before it's applied.
+type EpisodeRunner struct {
+	MaxSteps    int
+	Hooks       []EpisodeHook
+	Environment Environment
+}
+
+// NewEpisodeRunner should be used to create an EpisodeRunner; it starts with the default step limit and
+// no hooks.
+func NewEpisodeRunner() *EpisodeRunner {
+
+	runner := new(EpisodeRunner)
+	runner.MaxSteps = DefaultMaxSteps
+	runner.Hooks = make([]EpisodeHook, 0)
+
+	return runner
+}
+
+// Run follows the policy until a terminal state is reached, or the experiment is truncated.
+func (this *EpisodeRunner) Run(experiment StepExperiment, policy Policy) []Outcome {
+
+	return this.ForceRun(experiment, nil, policy)
+}
+
+// ForceRun takes an action and then follows the policy until a terminal state is reached, or the
+// experiment is truncated; a nil action just follows the policy from the start.  It panics if the
+// experiment fails.
+func (this *EpisodeRunner) ForceRun(experiment StepExperiment, first Action, policy Policy) []Outcome {
+
+	outcomes, err := this.TryForceRun(experiment, first, policy)
+	if err != nil {
+
+		panic(err)
+	}
+
+	return outcomes
+}
+
+// TryRun follows the policy in the same way as Run, but returns an error if the experiment fails.
+func (this *EpisodeRunner) TryRun(experiment StepExperiment, policy Policy) ([]Outcome, error) {
+
+	return this.TryForceRun(experiment, nil, policy)
+}
+
+// TryForceRun takes an action and then follows the policy until a terminal state is reached, or the
+// experiment is truncated; a nil action just follows the policy from the start.  An outcome is
+// recorded for every step with its next state and the final state.  If the maximum number of steps is
+// taken first, or the experiment cuts itself short, the final state isn't terminal, and every outcome
+// reports that it was truncated so that learners don't treat the final state as the end.  A maximum
+// of zero means no limit.  Forced actions are taken for certain, and the probability of any other
+// action is only known if the policy can say.  If the policy can't pick an action, an action isn't
+// legal or an action fails, the experiment is abandoned and the error is returned.
+func (this *EpisodeRunner) TryForceRun(experiment StepExperiment, first Action, policy Policy) ([]Outcome, error) {
+
+	basicOutcomes := make([]*BasicOutcome, 0)
+	truncated := false
+	state := experiment.ObserveState()
+	for !state.IsTerminal() {
+
+		// Stop short if the experiment has run for too long, or has cut itself short.
+		if this.MaxSteps > 0 && len(basicOutcomes) >= this.MaxSteps || isTruncatedExperiment(experiment) {
+
+			truncated = true
+			break
+		}
+
+		// Take the forced action on the first step, and follow the policy after that, asking it how
+		// likely it was to pick each action before it picks one.
+		action := first
+		probability := 1.0
+		if len(basicOutcomes) > 0 || action == nil {
+
+			var probabilities map[string]float64
+			if probabilistic, ok := policy.(ProbabilisticPolicy); ok {
+
+				probabilities = probabilistic.GetActionProbabilities(state)
+			}
+
+			var err error
+			action, err = getAction(policy, state)
+			if err != nil {
+
+				return nil, err
+			}
+
+			probability = probabilities[action.GetId()]
+		}
+
+		err := this.apply(experiment, state, action)
+		if err != nil {
+
+			return nil, err
+		}
+
+		outcome := new(BasicOutcome)
+		outcome.InitialState = state
+		outcome.ActionTaken = action
+		outcome.BehaviorProbability = probability
+		basicOutcomes = append(basicOutcomes, outcome)
+
+		state = experiment.ObserveState()
+		outcome.NextState = state
+		for _, hook := range this.Hooks {
+
+			hook.OnStep(outcome)
+		}
+	}
+
+	outcomes := make([]Outcome, 0)
+	for _, outcome := range basicOutcomes {
+
+		outcome.FinalState = state
+		outcome.Truncated = truncated
+		outcomes = append(outcomes, outcome)
+	}
+
+	for _, hook := range this.Hooks {
+
+		hook.OnEpisode(outcomes)
+	}
+
+	return outcomes, nil
+}
+
+// apply checks that an action is legal if there's an environment to check it against, and then applies
+// it to the experiment.  A panic while applying it is turned into an ActionError.
+func (this *EpisodeRunner) apply(experiment StepExperiment, state State, action Action) (err error) {
+
+	if this.Environment != nil && !isLegalAction(this.Environment, state, action) {
+
+		return &IllegalActionError{State: state, Action: action}
+	}
+
+	if fallible, ok := experiment.(FallibleStepExperiment); ok {
+
+		return fallible.TryApply(action)
+	}
+
+	defer func() {
+
+		if r := recover(); r != nil {
+
+			err = &ActionError{Action: action, Err: recoveredError(r)}
+		}
+	}()
+
+	experiment.Apply(action)
+	return nil
+}
+
+// getAction asks a policy for an action, and returns an error rather than panicking if it can't pick
+// one.  A policy that returns no action is taken to mean that there aren't any legal actions.
+func getAction(policy Policy, state State) (action Action, err error) {
+
+	if fallible, ok := policy.(FalliblePolicy); ok {
+
+		action, err = fallible.TryGetAction(state)
+		if err == nil && action == nil {
+
+			err = &NoLegalActionsError{State: state}
+		}
+
+		return action, err
+	}
+
+	defer func() {
+
+		if r := recover(); r != nil {
+
+			action = nil
+			err = recoveredError(r)
+		}
+	}()
+
+	action = policy.GetAction(state)
+	if action == nil {
+
+		return nil, &NoLegalActionsError{State: state}
+	}
+
+	return action, nil
+}
+
+// isTruncatedExperiment returns whether a step experiment has cut itself short.
+func isTruncatedExperiment(experiment StepExperiment) bool {
+
+	if truncating, ok := experiment.(TruncatingStepExperiment); ok {
+
+		return truncating.IsTruncated()
+	}
+
+	return false
+}
+
+// isLegalAction returns whether an action is one of the legal actions in a state.
+func isLegalAction(environment Environment, state State, action Action) bool {
+
+	for _, legal := range environment.GetLegalActions(state) {
+
+		if legal.GetId() == action.GetId() {
+
+			return true
+		}
+	}
+
+	return false
+}
+
+// runnable holds the episode runner that an experiment has been told to run with, and is embedded in
+// the experiments that can be told.
+type runnable struct {
+	runner *EpisodeRunner
+}
+
+// SetRunner sets the episode runner that the experiment runs with.
+func (this *runnable) SetRunner(runner *EpisodeRunner) {
+
+	this.runner = runner
+}
+
+// getRunner returns the episode runner that the experiment has been told to run with, or a new one if
+// it hasn't been told.
+func (this *runnable) getRunner() *EpisodeRunner {
+
+	if this.runner == nil {
+
+		return NewEpisodeRunner()
+	}
+
+	return this.runner
+}
+
+// NewExperiment wraps a step experiment so that it can be used anywhere an Experiment can, running it
+// with a new EpisodeRunner each time unless it's told which runner to use.
+func NewExperiment(experiment StepExperiment) Experiment {
+
+	return &steppedExperiment{StepExperiment: experiment}
+}
+
+// steppedExperiment is a step experiment that can be run.
+type steppedExperiment struct {
+	StepExperiment
+	runner runnable
+}
+
+// SetRunner sets the episode runner that the experiment runs with.
+func (this *steppedExperiment) SetRunner(runner *EpisodeRunner) {
+
+	this.runner.SetRunner(runner)
+}
+
+// Run follows the policy until a terminal state is reached, or the experiment is truncated.
+func (this *steppedExperiment) Run(policy Policy) []Outcome {
+
+	return this.runner.getRunner().Run(this.StepExperiment, policy)
+}
+
+// ForceRun takes an action and then follows the policy until a terminal state is reached, or the
+// experiment is truncated.
+func (this *steppedExperiment) ForceRun(action Action, policy Policy) []Outcome {
+
+	return this.runner.getRunner().ForceRun(this.StepExperiment, action, policy)
+}
+
+// TryRun follows the policy in the same way as Run, but returns an error if the experiment fails.
+func (this *steppedExperiment) TryRun(policy Policy) ([]Outcome, error) {
+
+	return this.runner.getRunner().TryRun(this.StepExperiment, policy)
+}
+
+// TryForceRun takes an action and then follows the policy in the same way as ForceRun, but returns an
+// error if the experiment fails.
+func (this *steppedExperiment) TryForceRun(action Action, policy Policy) ([]Outcome, error) {
+
+	return this.runner.getRunner().TryForceRun(this.StepExperiment, action, policy)
+}
+
+// RunEpisode walks an experiment that runs its actions against a context, given a way to observe its
+// state, with a limit on the number of steps.
+func RunEpisode(observe func() State, context map[string]interface{}, first Action, policy Policy, maxSteps int) []Outcome {
+
+	runner := NewEpisodeRunner()
+	runner.MaxSteps = maxSteps
+	return runner.ForceRun(&contextExperiment{observe: observe, context: context}, first, policy)
+}
+
+// contextExperiment is a step experiment that runs actions against a context.
+type contextExperiment struct {
+	observe func() State
+	context map[string]interface{}
+}
+
+// ObserveState returns the current state of the experiment.
+func (this *contextExperiment) ObserveState() State {
+
+	return this.observe()
+}
+
+// Apply runs the action against the context.
+func (this *contextExperiment) Apply(action Action) {
+
+	action.Run(this.context)
+}
+
+// TryApply runs the action against the context, and returns an error if it fails.
+func (this *contextExperiment) TryApply(action Action) error {
+
+	return RunAction(action, this.context)
+}
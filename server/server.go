@@ -0,0 +1,222 @@
+// Package server serves a saved policy over HTTP, so that clients can ask which action to take in a
+// state without linking any of the training code.  States are looked up by their context, or by their
+// identifier, and answers are JSON.
+//
+//	POST /action   {"context": {...}} or {"id": "..."}
+//	POST /actions  {"states": [{"context": {...}}, ...]}
+//	GET  /health
+package server
+
+import (
+	"encoding/json"
+	"net/http"
+	"sort"
+	"strings"
+
+	"github.com/tysont/monoikos"
+)
+
+// DefaultConfidenceVisits is the number of visits to the preferred action at which a server is half
+// confident in it.
+var DefaultConfidenceVisits = 10
+
+// Query is a request for the action to take in a state, which is given either by its context or by
+// its identifier.
+type Query struct {
+	Id      string            `json:"id,omitempty"`
+	Context map[string]string `json:"context,omitempty"`
+}
+
+// Answer is the action to take in a state, along with the value and number of visits of every action
+// the policy has them for, how confident the policy is, and the version of the policy.  If the state
+// isn't known there's an error instead of an action.
+type Answer struct {
+	Id         string             `json:"id,omitempty"`
+	Action     string             `json:"action,omitempty"`
+	Others     []string           `json:"others,omitempty"`
+	Values     map[string]float64 `json:"values,omitempty"`
+	Visits     map[string]int     `json:"visits,omitempty"`
+	Confidence float64            `json:"confidence"`
+	Version    string             `json:"version"`
+	Error      string             `json:"error,omitempty"`
+}
+
+// BatchQuery is a request for the actions to take in a number of states.
+type BatchQuery struct {
+	States []*Query `json:"states"`
+}
+
+// BatchAnswer holds an answer for every state in a batch query, in the same order.
+type BatchAnswer struct {
+	Answers []*Answer `json:"answers"`
+	Version string    `json:"version"`
+}
+
+// Health is the answer to a health check.
+type Health struct {
+	Status      string `json:"status"`
+	Version     string `json:"version"`
+	Environment string `json:"environment,omitempty"`
+	States      int    `json:"states"`
+}
+
+// Server is an http.Handler that answers queries from a policy file.  Confidence is the number of
+// visits to the preferred action over that number plus ConfidenceVisits, so it grows towards one as
+// the preferred action is tried more, and is zero if the file has no visits.
+type Server struct {
+	File             *monoikos.PolicyFile
+	ConfidenceVisits int
+	contexts         map[string]*monoikos.PolicyFileState
+	mux              *http.ServeMux
+}
+
+// NewServer should be used to create a Server; it indexes the states of the policy file by context.
+func NewServer(file *monoikos.PolicyFile) *Server {
+
+	server := new(Server)
+	server.File = file
+	server.ConfidenceVisits = DefaultConfidenceVisits
+	server.contexts = make(map[string]*monoikos.PolicyFileState)
+	for _, s := range file.States {
+
+		server.contexts[getContextKey(s.Context)] = s
+	}
+
+	server.mux = http.NewServeMux()
+	server.mux.HandleFunc("/action", server.handleAction)
+	server.mux.HandleFunc("/actions", server.handleActions)
+	server.mux.HandleFunc("/health", server.handleHealth)
+	return server
+}
+
+// LoadServer reads a policy file and creates a server for it.
+func LoadServer(path string) (*Server, error) {
+
+	file, err := monoikos.ReadPolicyFile(path)
+	if err != nil {
+
+		return nil, err
+	}
+
+	return NewServer(file), nil
+}
+
+// ServeHTTP answers a request.
+func (this *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+
+	this.mux.ServeHTTP(w, r)
+}
+
+// Answer answers a single query.
+func (this *Server) Answer(query *Query) *Answer {
+
+	answer := &Answer{Version: this.File.Version}
+	var s *monoikos.PolicyFileState
+	if query.Id != "" {
+
+		s = this.File.GetState(query.Id)
+
+	} else if query.Context != nil {
+
+		s = this.contexts[getContextKey(query.Context)]
+	}
+
+	if s == nil {
+
+		answer.Error = "unknown state"
+		return answer
+	}
+
+	answer.Id = s.Id
+	answer.Action = s.Preferred
+	answer.Others = s.Others
+	answer.Values = s.Values
+	answer.Visits = s.Visits
+	if n := s.Visits[s.Preferred]; n > 0 {
+
+		answer.Confidence = float64(n) / float64(n+this.ConfidenceVisits)
+	}
+
+	return answer
+}
+
+// handleAction answers a single query, with a not found status if the state isn't known.
+func (this *Server) handleAction(w http.ResponseWriter, r *http.Request) {
+
+	if r.Method != http.MethodPost {
+
+		writeJSON(w, http.StatusMethodNotAllowed, &Answer{Version: this.File.Version, Error: "use POST"})
+		return
+	}
+
+	query := new(Query)
+	err := json.NewDecoder(r.Body).Decode(query)
+	if err != nil {
+
+		writeJSON(w, http.StatusBadRequest, &Answer{Version: this.File.Version, Error: err.Error()})
+		return
+	}
+
+	answer := this.Answer(query)
+	status := http.StatusOK
+	if answer.Error != "" {
+
+		status = http.StatusNotFound
+	}
+
+	writeJSON(w, status, answer)
+}
+
+// handleActions answers a batch of queries.  States that aren't known have an error in their answer
+// rather than failing the whole batch.
+func (this *Server) handleActions(w http.ResponseWriter, r *http.Request) {
+
+	if r.Method != http.MethodPost {
+
+		writeJSON(w, http.StatusMethodNotAllowed, &Answer{Version: this.File.Version, Error: "use POST"})
+		return
+	}
+
+	query := new(BatchQuery)
+	err := json.NewDecoder(r.Body).Decode(query)
+	if err != nil {
+
+		writeJSON(w, http.StatusBadRequest, &Answer{Version: this.File.Version, Error: err.Error()})
+		return
+	}
+
+	batch := &BatchAnswer{Answers: make([]*Answer, 0), Version: this.File.Version}
+	for _, q := range query.States {
+
+		batch.Answers = append(batch.Answers, this.Answer(q))
+	}
+
+	writeJSON(w, http.StatusOK, batch)
+}
+
+// handleHealth reports that the server is up, and which policy it's serving.
+func (this *Server) handleHealth(w http.ResponseWriter, r *http.Request) {
+
+	writeJSON(w, http.StatusOK, &Health{Status: "ok", Version: this.File.Version, Environment: this.File.Environment, States: len(this.File.States)})
+}
+
+// writeJSON writes a value as JSON with a status.
+func writeJSON(w http.ResponseWriter, status int, v interface{}) {
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(v)
+}
+
+// getContextKey returns a key for a context that doesn't depend on the order of the keys.
+func getContextKey(context map[string]string) string {
+
+	pairs := make([]string, 0)
+	for k, v := range context {
+
+		pairs = append(pairs, k+"="+v)
+	}
+
+	sort.Strings(pairs)
+	return strings.Join(pairs, "\x00")
+}
@@ -0,0 +1,214 @@
+package server_test
+
+import (
+	"bytes"
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/tysont/monoikos"
+	"github.com/tysont/monoikos/server"
+)
+
+// createPolicyFile creates a small blackjack policy file by hand.
+func createPolicyFile() *monoikos.PolicyFile {
+
+	file := new(monoikos.PolicyFile)
+	file.Version = "v7"
+	file.Environment = "blackjack"
+	file.States = []*monoikos.PolicyFileState{
+		{
+			Id:        "[dealer:10 player:16]",
+			Context:   map[string]string{"player": "16", "dealer": "10"},
+			Preferred: "hit",
+			Others:    []string{"stand"},
+			Values:    map[string]float64{"hit": -0.5, "stand": -0.54},
+			Visits:    map[string]int{"hit": 30, "stand": 12},
+		},
+		{
+			Id:        "[dealer:6 player:12]",
+			Context:   map[string]string{"player": "12", "dealer": "6"},
+			Preferred: "stand",
+			Others:    []string{"hit"},
+		},
+	}
+
+	return file
+}
+
+// post posts a value as JSON and decodes the answer into another.
+func post(t *testing.T, url string, query interface{}, answer interface{}) int {
+
+	b, _ := json.Marshal(query)
+	response, err := http.Post(url, "application/json", bytes.NewReader(b))
+	if err != nil {
+
+		t.Fatal(err)
+	}
+	defer response.Body.Close()
+
+	err = json.NewDecoder(response.Body).Decode(answer)
+	if err != nil {
+
+		t.Fatal(err)
+	}
+
+	return response.StatusCode
+}
+
+func TestServeAction(t *testing.T) {
+
+	s := httptest.NewServer(server.NewServer(createPolicyFile()))
+	defer s.Close()
+
+	answer := new(server.Answer)
+	status := post(t, s.URL+"/action", &server.Query{Context: map[string]string{"dealer": "10", "player": "16"}}, answer)
+	if status != http.StatusOK || answer.Action != "hit" || answer.Version != "v7" {
+		t.Errorf("Expected to be told to hit, got '%v' '%v'.", status, answer)
+	}
+
+	if answer.Values["stand"] != -0.54 || answer.Visits["hit"] != 30 || answer.Confidence != 0.75 {
+		t.Errorf("Expected values, visits and confidence, got '%v' '%v' '%v'.", answer.Values, answer.Visits, answer.Confidence)
+	}
+
+	// States can be given by identifier, and ones without visits have no confidence.
+	answer = new(server.Answer)
+	status = post(t, s.URL+"/action", &server.Query{Id: "[dealer:6 player:12]"}, answer)
+	if status != http.StatusOK || answer.Action != "stand" || answer.Confidence != 0 {
+		t.Errorf("Expected to be told to stand, got '%v' '%v'.", status, answer)
+	}
+
+	answer = new(server.Answer)
+	status = post(t, s.URL+"/action", &server.Query{Context: map[string]string{"player": "21"}}, answer)
+	if status != http.StatusNotFound || answer.Error == "" {
+		t.Errorf("Expected an unknown state not to be found, got '%v' '%v'.", status, answer)
+	}
+
+	response, err := http.Get(s.URL + "/action")
+	if err != nil {
+
+		t.Fatal(err)
+	}
+	response.Body.Close()
+
+	if response.StatusCode != http.StatusMethodNotAllowed {
+		t.Errorf("Expected queries to have to be posted, got '%v'.", response.StatusCode)
+	}
+}
+
+func TestServeActions(t *testing.T) {
+
+	s := httptest.NewServer(server.NewServer(createPolicyFile()))
+	defer s.Close()
+
+	query := &server.BatchQuery{States: []*server.Query{
+		{Context: map[string]string{"player": "12", "dealer": "6"}},
+		{Context: map[string]string{"player": "21"}},
+		{Context: map[string]string{"player": "16", "dealer": "10"}},
+	}}
+
+	batch := new(server.BatchAnswer)
+	status := post(t, s.URL+"/actions", query, batch)
+	if status != http.StatusOK || batch.Version != "v7" || len(batch.Answers) != 3 {
+		t.Fatalf("Expected an answer for every state, got '%v' '%v'.", status, batch)
+	}
+
+	if batch.Answers[0].Action != "stand" || batch.Answers[1].Error == "" || batch.Answers[2].Action != "hit" {
+		t.Errorf("Expected answers in order, got '%v' '%v' '%v'.", batch.Answers[0], batch.Answers[1], batch.Answers[2])
+	}
+}
+
+func TestServeConcurrently(t *testing.T) {
+
+	handler := server.NewServer(createPolicyFile())
+	s := httptest.NewServer(handler)
+	defer s.Close()
+
+	// States given by identifier are looked up from many requests at once, both over HTTP and by
+	// answering queries directly.
+	query := &server.Query{Id: "[dealer:6 player:12]"}
+	b, _ := json.Marshal(query)
+	start := make(chan bool)
+	results := make(chan string)
+	for i := 0; i < 20; i++ {
+
+		go func() {
+
+			<-start
+			results <- handler.Answer(query).Action
+		}()
+
+		go func() {
+
+			<-start
+			response, err := http.Post(s.URL+"/action", "application/json", bytes.NewReader(b))
+			if err != nil {
+
+				results <- err.Error()
+				return
+			}
+			defer response.Body.Close()
+
+			answer := new(server.Answer)
+			err = json.NewDecoder(response.Body).Decode(answer)
+			if err != nil {
+
+				results <- err.Error()
+				return
+			}
+
+			results <- answer.Action
+		}()
+	}
+
+	close(start)
+	for i := 0; i < 40; i++ {
+
+		if result := <-results; result != "stand" {
+			t.Errorf("Expected every query to be told to stand, got '%v'.", result)
+		}
+	}
+}
+
+func TestServeHealth(t *testing.T) {
+
+	dir, err := ioutil.TempDir("", "monoikos")
+	if err != nil {
+
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	path := filepath.Join(dir, "policy.json")
+	err = createPolicyFile().Write(path)
+	if err != nil {
+
+		t.Fatal(err)
+	}
+
+	loaded, err := server.LoadServer(path)
+	if err != nil {
+
+		t.Fatal(err)
+	}
+
+	s := httptest.NewServer(loaded)
+	defer s.Close()
+
+	response, err := http.Get(s.URL + "/health")
+	if err != nil {
+
+		t.Fatal(err)
+	}
+	defer response.Body.Close()
+
+	health := new(server.Health)
+	err = json.NewDecoder(response.Body).Decode(health)
+	if err != nil || response.StatusCode != http.StatusOK || health.Status != "ok" || health.Version != "v7" || health.States != 2 {
+		t.Errorf("Expected the server to be healthy, got '%v' '%v'.", response.StatusCode, health)
+	}
+}
@@ -0,0 +1,379 @@
+package monoikos
+
+import (
+	"math"
+)
+
+// Model is an optional interface that an Environment can implement when it knows exactly how it behaves.
+// It returns every state that may follow taking an action in a state, along with the probability that
+// it does and the reward paid out for getting there.  Probabilities for a state and action pair should
+// add up to one.  An EmpiricalModel also satisfies it, so a learned model can be solved in the same way.
+type Model interface {
+	GetSuccessors(State, Action) []Successor
+}
+
+// Solution is the result of solving an environment exactly.  It includes a policy that prefers the
+// optimal action in every state, the value of each state keyed by state identifier, and the value of
+// each action keyed by outcome identifier in the same way as average rewards.  Action values that are
+// within the tolerance of each other are treated as ties.
+type Solution struct {
+	Policy       Policy
+	States       []State
+	Values       map[string]float64
+	ActionValues map[string]float64
+	Iterations   int
+	Tolerance    float64
+}
+
+// Solver holds the parameters shared by the dynamic programming solvers.  The discount is applied to
+// future rewards, iteration stops once no value changes by more than the threshold, and the number
+// of sweeps is capped at the maximum iterations.  Evaluation sweeps is the number of sweeps used to
+// evaluate each policy when using modified policy iteration.  Iteration only gets values to within the
+// threshold, so action values within the tolerance of each other are treated as ties in the solution.
+type Solver struct {
+	Environment       Environment
+	Model             Model
+	Discount          float64
+	Threshold         float64
+	Tolerance         float64
+	MaxIterations     int
+	EvaluationSweeps  int
+	states            []State
+	terminal          map[string]bool
+	actions           map[string][]Action
+	successors        map[string][]Successor
+	preferredActionId map[string]string
+}
+
+// NewSolver should be used to create a Solver; it handles instantiating members appropriately.
+func NewSolver(environment Environment, model Model) *Solver {
+
+	solver := new(Solver)
+	solver.Environment = environment
+	solver.Model = model
+	solver.Discount = 1.0
+	solver.Threshold = 0.000001
+	solver.Tolerance = 0.0001
+	solver.MaxIterations = 10000
+	solver.EvaluationSweeps = 10
+
+	return solver
+}
+
+// SolveValueIteration is a utility function for finding the optimal policy of an environment that
+// implements Model, using value iteration with default parameters.
+func SolveValueIteration(environment Environment, model Model) *Solution {
+
+	return NewSolver(environment, model).ValueIteration()
+}
+
+// SolvePolicyIteration is a utility function for finding the optimal policy of an environment that
+// implements Model, using policy iteration with default parameters.
+func SolvePolicyIteration(environment Environment, model Model) *Solution {
+
+	return NewSolver(environment, model).PolicyIteration()
+}
+
+// ValueIteration repeatedly backs up the value of every state with the value of its best action until
+// values stop changing, and then returns the policy that's greedy with respect to those values.
+func (this *Solver) ValueIteration() *Solution {
+
+	this.explore()
+	values := make(map[string]float64)
+
+	// Sweep until the largest change falls under the threshold.
+	i := 0
+	for ; i < this.MaxIterations; i++ {
+
+		delta := 0.0
+		for _, state := range this.states {
+
+			id := state.GetId()
+			if this.terminal[id] {
+
+				continue
+			}
+
+			_, value := this.getBestAction(state, values)
+			delta = math.Max(delta, math.Abs(value-values[id]))
+			values[id] = value
+		}
+
+		if delta < this.Threshold {
+
+			i++
+			break
+		}
+	}
+
+	return this.createSolution(values, i)
+}
+
+// PolicyIteration alternates between fully evaluating the current policy and making it greedy with
+// respect to the resulting values, until the policy stops changing.
+func (this *Solver) PolicyIteration() *Solution {
+
+	return this.iteratePolicy(this.MaxIterations)
+}
+
+// ModifiedPolicyIteration works like PolicyIteration, except that each policy is only partially
+// evaluated with a fixed number of sweeps before it's improved.
+func (this *Solver) ModifiedPolicyIteration() *Solution {
+
+	return this.iteratePolicy(this.EvaluationSweeps)
+}
+
+// iteratePolicy runs policy iteration, evaluating each policy with at most the given number of sweeps.
+func (this *Solver) iteratePolicy(sweeps int) *Solution {
+
+	this.explore()
+	values := make(map[string]float64)
+
+	// Start off with the first legal action in every state.
+	this.preferredActionId = make(map[string]string)
+	for _, state := range this.states {
+
+		id := state.GetId()
+		if !this.terminal[id] && len(this.actions[id]) > 0 {
+
+			this.preferredActionId[id] = this.actions[id][0].GetId()
+		}
+	}
+
+	i := 0
+	for ; i < this.MaxIterations; i++ {
+
+		// Evaluate the current policy.
+		converged := false
+		for j := 0; j < sweeps && !converged; j++ {
+
+			delta := 0.0
+			for _, state := range this.states {
+
+				id := state.GetId()
+				action := this.getPreferredAction(state)
+				if action == nil {
+
+					continue
+				}
+
+				value := this.getActionValue(state, action, values)
+				delta = math.Max(delta, math.Abs(value-values[id]))
+				values[id] = value
+			}
+
+			converged = delta < this.Threshold
+		}
+
+		// Make the policy greedy, only switching actions when there's a strict improvement so that
+		// ties don't keep the policy from settling.
+		stable := true
+		for _, state := range this.states {
+
+			id := state.GetId()
+			current := this.getPreferredAction(state)
+			if current == nil {
+
+				continue
+			}
+
+			best, value := this.getBestAction(state, values)
+			if best.GetId() != current.GetId() && value > this.getActionValue(state, current, values)+this.Threshold {
+
+				this.preferredActionId[id] = best.GetId()
+				stable = false
+			}
+		}
+
+		// Partially evaluated policies can look stable before their values have settled, so keep
+		// going until both have.
+		if stable && converged {
+
+			i++
+			break
+		}
+	}
+
+	return this.createSolution(values, i)
+}
+
+// explore finds every state that can be reached from the known states of the environment, and caches
+// their legal actions and successors.
+func (this *Solver) explore() {
+
+	this.states = make([]State, 0)
+	this.terminal = make(map[string]bool)
+	this.actions = make(map[string][]Action)
+	this.successors = make(map[string][]Successor)
+
+	seen := make(map[string]bool)
+	queue := this.Environment.GetKnownStates()
+	for len(queue) > 0 {
+
+		state := queue[0]
+		queue = queue[1:]
+
+		id := state.GetId()
+		if seen[id] {
+
+			continue
+		}
+
+		seen[id] = true
+		this.states = append(this.states, state)
+		this.terminal[id] = state.IsTerminal()
+		if state.IsTerminal() {
+
+			continue
+		}
+
+		// Cache the successors of every action and queue up any states we haven't seen.
+		this.actions[id] = this.Environment.GetLegalActions(state)
+		for _, action := range this.actions[id] {
+
+			successors := this.Model.GetSuccessors(state, action)
+			this.successors[getOutcomeId(state, action)] = successors
+			for _, successor := range successors {
+
+				if !seen[successor.State.GetId()] {
+
+					queue = append(queue, successor.State)
+				}
+			}
+		}
+	}
+}
+
+// getActionValue returns the expected reward plus discounted value of taking an action in a state.
+func (this *Solver) getActionValue(state State, action Action, values map[string]float64) float64 {
+
+	value := 0.0
+	for _, successor := range this.successors[getOutcomeId(state, action)] {
+
+		next := successor.State.GetId()
+		future := 0.0
+		if !this.terminal[next] {
+
+			future = values[next]
+		}
+
+		value += successor.Probability * (successor.Reward + this.Discount*future)
+	}
+
+	return value
+}
+
+// getBestAction returns the legal action with the highest value in a state, and that value.
+func (this *Solver) getBestAction(state State, values map[string]float64) (Action, float64) {
+
+	var best Action
+	max := 0.0
+	for _, action := range this.actions[state.GetId()] {
+
+		value := this.getActionValue(state, action, values)
+		if best == nil || value > max {
+
+			best = action
+			max = value
+		}
+	}
+
+	return best, max
+}
+
+// getPreferredAction returns the action that the policy being iterated on prefers in a state.
+func (this *Solver) getPreferredAction(state State) Action {
+
+	id := this.preferredActionId[state.GetId()]
+	for _, action := range this.actions[state.GetId()] {
+
+		if action.GetId() == id {
+
+			return action
+		}
+	}
+
+	return nil
+}
+
+// createSolution builds the action values and greedy policy for a set of state values.
+func (this *Solver) createSolution(values map[string]float64, iterations int) *Solution {
+
+	solution := new(Solution)
+	solution.States = this.states
+	solution.Values = values
+	solution.ActionValues = make(map[string]float64)
+	solution.Iterations = iterations
+	solution.Tolerance = this.Tolerance
+
+	policy := NewBasicPolicy()
+	policy.Environment = this.Environment
+	policy.SetRandomizationRate(0)
+	for _, state := range this.states {
+
+		id := state.GetId()
+		if this.terminal[id] {
+
+			values[id] = 0
+			continue
+		}
+
+		for _, action := range this.actions[id] {
+
+			solution.ActionValues[getOutcomeId(state, action)] = this.getActionValue(state, action, values)
+		}
+
+		// Prefer the best action, keeping the rest as other actions in case randomization is turned on.
+		best, _ := this.getBestAction(state, values)
+		if best == nil {
+
+			continue
+		}
+
+		otherActions := make([]Action, 0)
+		for _, action := range this.actions[id] {
+
+			if action.GetId() != best.GetId() {
+
+				otherActions = append(otherActions, action)
+			}
+		}
+
+		policy.AddState(state, best, otherActions)
+	}
+
+	solution.Policy = policy
+	return solution
+}
+
+// GetAgreement returns the fraction of non-terminal states where another policy prefers an action that's
+// as good as the optimal one, to within the tolerance, which makes a solution useful as ground truth for
+// sampling-based policies.
+func (this *Solution) GetAgreement(policy Policy) float64 {
+
+	n := 0
+	agreed := 0
+	for _, state := range this.States {
+
+		optimal := this.Policy.GetPreferredAction(state)
+		action := policy.GetPreferredAction(state)
+		if state.IsTerminal() || optimal == nil {
+
+			continue
+		}
+
+		// Count ties as agreement, since either action is optimal.
+		n++
+		if action != nil && this.ActionValues[getOutcomeId(state, action)] >= this.ActionValues[getOutcomeId(state, optimal)]-this.Tolerance {
+
+			agreed++
+		}
+	}
+
+	if n == 0 {
+
+		return 1.0
+	}
+
+	return float64(agreed) / float64(n)
+}
@@ -0,0 +1,471 @@
+package monoikos
+
+import (
+	"strconv"
+)
+
+// Symmetry is a transformation of states and actions that leaves an environment unchanged, like
+// rotating or reflecting a board, or swapping context values that are interchangeable.  Taking the
+// transformed action in the transformed state has to lead to the transformed result, so that what's
+// learned about one state holds for every state that's equivalent to it.  Invert returns the
+// transformation that undoes this one.
+type Symmetry interface {
+	TransformState(State) State
+	TransformAction(Action) Action
+	Invert() Symmetry
+}
+
+// SymmetricEnvironment is an environment that declares its symmetries.
+type SymmetricEnvironment interface {
+	GetSymmetries() []Symmetry
+}
+
+// identitySymmetry leaves states and actions as they are.
+type identitySymmetry struct {
+}
+
+// TransformState returns the state as it is.
+func (this identitySymmetry) TransformState(state State) State {
+
+	return state
+}
+
+// TransformAction returns the action as it is.
+func (this identitySymmetry) TransformAction(action Action) Action {
+
+	return action
+}
+
+// Invert returns the identity.
+func (this identitySymmetry) Invert() Symmetry {
+
+	return this
+}
+
+// Canonicalize returns the canonical form of a state, which is whichever of the state and its
+// transformations has the lowest identifier, along with the symmetry that transforms the state into
+// it.  Every state that's equivalent under the symmetries has the same canonical form, so it can be
+// used as a key that's shared between them.
+func Canonicalize(state State, symmetries []Symmetry) (State, Symmetry) {
+
+	var canonical State = state
+	var symmetry Symmetry = identitySymmetry{}
+	id := state.GetId()
+	for _, s := range symmetries {
+
+		transformed := s.TransformState(state)
+		if transformed.GetId() < id {
+
+			canonical = transformed
+			symmetry = s
+			id = transformed.GetId()
+		}
+	}
+
+	return canonical, symmetry
+}
+
+// CanonicalizeOutcomes returns the outcomes with their states in canonical form, and the actions that
+// were taken transformed in the same way as the initial states, so that outcomes from equivalent
+// states add up together when they're averaged.
+func CanonicalizeOutcomes(outcomes []Outcome, symmetries []Symmetry) []Outcome {
+
+	canonicalized := make([]Outcome, 0)
+	for _, outcome := range outcomes {
+
+		state, symmetry := Canonicalize(outcome.GetInitialState(), symmetries)
+
+		basicOutcome := new(BasicOutcome)
+		basicOutcome.InitialState = state
+		basicOutcome.ActionTaken = symmetry.TransformAction(GetActionTaken(outcome))
+		if GetNextState(outcome) != nil {
+
+			basicOutcome.NextState, _ = Canonicalize(GetNextState(outcome), symmetries)
+		}
+
+		basicOutcome.FinalState, _ = Canonicalize(outcome.GetFinalState(), symmetries)
+		basicOutcome.Truncated = IsTruncated(outcome)
+		basicOutcome.BehaviorProbability = GetBehaviorProbability(outcome)
+		canonicalized = append(canonicalized, basicOutcome)
+	}
+
+	return canonicalized
+}
+
+// SymmetricPolicy is a policy over canonical states.  It canonicalizes every state it's asked about,
+// asks the wrapped policy about the canonical state, and translates the action back, so that
+// everything the wrapped policy knows is shared across equivalent states.
+type SymmetricPolicy struct {
+	Policy     Policy
+	Symmetries []Symmetry
+}
+
+// GetAction returns an action for a state, from the wrapped policy's action for the canonical state.
+func (this *SymmetricPolicy) GetAction(state State) Action {
+
+	canonical, symmetry := Canonicalize(state, this.Symmetries)
+	return this.translate(this.Policy.GetAction(canonical), symmetry)
+}
+
+// GetPreferredAction returns the preferred action for a state, from the wrapped policy's preferred
+// action for the canonical state.
+func (this *SymmetricPolicy) GetPreferredAction(state State) Action {
+
+	canonical, symmetry := Canonicalize(state, this.Symmetries)
+	return this.translate(this.Policy.GetPreferredAction(canonical), symmetry)
+}
+
+// AddRandomState adds the canonical state to the wrapped policy with a random preferred action.
+func (this *SymmetricPolicy) AddRandomState(state State) {
+
+	canonical, _ := Canonicalize(state, this.Symmetries)
+	this.Policy.AddRandomState(canonical)
+}
+
+// AddState adds the canonical state to the wrapped policy, with the actions transformed to match.
+func (this *SymmetricPolicy) AddState(state State, preferredAction Action, otherActions []Action) {
+
+	canonical, symmetry := Canonicalize(state, this.Symmetries)
+	actions := make([]Action, len(otherActions))
+	for i, action := range otherActions {
+
+		actions[i] = symmetry.TransformAction(action)
+	}
+
+	this.Policy.AddState(canonical, symmetry.TransformAction(preferredAction), actions)
+}
+
+// SetRandomizationRate sets the randomization rate of the wrapped policy.
+func (this *SymmetricPolicy) SetRandomizationRate(randomizationRate int) {
+
+	this.Policy.SetRandomizationRate(randomizationRate)
+}
+
+// GetRandomizationRate gets the randomization rate of the wrapped policy.
+func (this *SymmetricPolicy) GetRandomizationRate() int {
+
+	return this.Policy.GetRandomizationRate()
+}
+
+// translate turns an action for a canonical state back into an action for the original state.
+func (this *SymmetricPolicy) translate(action Action, symmetry Symmetry) Action {
+
+	if action == nil {
+
+		return nil
+	}
+
+	return symmetry.Invert().TransformAction(action)
+}
+
+// CanonicalEnvironment wraps an environment so that policies are learned over canonical states, which
+// shrinks the state space by up to the number of symmetries.  Experiments are run in the wrapped
+// environment as they are, with policies that translate actions back and forth, and outcomes are
+// canonicalized before policies are improved from them.
+type CanonicalEnvironment struct {
+	Environment Environment
+	Symmetries  []Symmetry
+}
+
+// NewCanonicalEnvironment should be used to create a CanonicalEnvironment; the symmetries are taken
+// from the environment if none are given and the environment declares them.
+func NewCanonicalEnvironment(environment Environment, symmetries []Symmetry) *CanonicalEnvironment {
+
+	if symmetries == nil {
+
+		if symmetric, ok := environment.(SymmetricEnvironment); ok {
+
+			symmetries = symmetric.GetSymmetries()
+		}
+	}
+
+	canonical := new(CanonicalEnvironment)
+	canonical.Environment = environment
+	canonical.Symmetries = symmetries
+	return canonical
+}
+
+// CreateRandomPolicy creates a random policy over canonical states.
+func (this *CanonicalEnvironment) CreateRandomPolicy() Policy {
+
+	return &SymmetricPolicy{Policy: CreateRandomPolicy(this), Symmetries: this.Symmetries}
+}
+
+// CreateImprovedPolicy creates an improved policy over canonical states from a set of outcomes.
+func (this *CanonicalEnvironment) CreateImprovedPolicy(outcomes []Outcome) Policy {
+
+	policy := CreateImprovedPolicy(this, CanonicalizeOutcomes(outcomes, this.Symmetries))
+	return &SymmetricPolicy{Policy: policy, Symmetries: this.Symmetries}
+}
+
+// CreateExploringPolicy creates an improved policy over canonical states from a set of outcomes in the
+// same way as CreateImprovedPolicy, treating untried actions as the exploration says.
+func (this *CanonicalEnvironment) CreateExploringPolicy(outcomes []Outcome, exploration Exploration) Policy {
+
+	rewards := GetAverageRewards(CanonicalizeOutcomes(outcomes, this.Symmetries))
+	policy := CreateExploringPolicyFromValues(this, rewards, exploration)
+	return &SymmetricPolicy{Policy: policy, Symmetries: this.Symmetries}
+}
+
+// CreateOptimizedPolicy creates an optimized policy over canonical states by running iterations of experiments.
+func (this *CanonicalEnvironment) CreateOptimizedPolicy(initialRandomizationRate int, experimentsPerIteration int, iterations int) Policy {
+
+	return CreateOptimizedPolicy(this, initialRandomizationRate, experimentsPerIteration, iterations)
+}
+
+// CreateExperiment creates an experiment in the wrapped environment.
+func (this *CanonicalEnvironment) CreateExperiment() Experiment {
+
+	return this.Environment.CreateExperiment()
+}
+
+// GetLegalActions returns the legal actions in the wrapped environment.
+func (this *CanonicalEnvironment) GetLegalActions(state State) []Action {
+
+	return this.Environment.GetLegalActions(state)
+}
+
+// GetKnownStates returns the canonical form of every known state, without repeats.
+func (this *CanonicalEnvironment) GetKnownStates() []State {
+
+	states := make([]State, 0)
+	seen := make(map[string]bool)
+	for _, state := range this.Environment.GetKnownStates() {
+
+		canonical, _ := Canonicalize(state, this.Symmetries)
+		if !seen[canonical.GetId()] {
+
+			seen[canonical.GetId()] = true
+			states = append(states, canonical)
+		}
+	}
+
+	return states
+}
+
+// ContextPermutation is a symmetry that swaps context values that are interchangeable, like suits of
+// cards or identical machines, leaving actions as they are.  Values under the given keys are mapped by
+// the permutation, and anything the permutation doesn't mention is left alone.
+type ContextPermutation struct {
+	Keys        []string
+	Permutation map[string]string
+}
+
+// TransformState returns the state with the values permuted.
+func (this *ContextPermutation) TransformState(state State) State {
+
+	transformed := copyState(state)
+	for _, key := range this.Keys {
+
+		if value, ok := this.Permutation[transformed.Context[key]]; ok {
+
+			transformed.Context[key] = value
+		}
+	}
+
+	return transformed
+}
+
+// TransformAction returns the action as it is.
+func (this *ContextPermutation) TransformAction(action Action) Action {
+
+	return action
+}
+
+// Invert returns the permutation that maps values back.
+func (this *ContextPermutation) Invert() Symmetry {
+
+	inverse := new(ContextPermutation)
+	inverse.Keys = this.Keys
+	inverse.Permutation = make(map[string]string)
+	for k, v := range this.Permutation {
+
+		inverse.Permutation[v] = k
+	}
+
+	return inverse
+}
+
+// copyState copies a state into a BasicState, so that its context can be changed.
+func copyState(state State) *BasicState {
+
+	copied := NewBasicState()
+	for k, v := range state.GetContext() {
+
+		copied.Context[k] = v
+	}
+
+	copied.Terminal = state.IsTerminal()
+	copied.Reward = state.GetReward()
+
+	return copied
+}
+
+// dihedral is one of the eight ways of rotating and reflecting a grid, done by optionally swapping
+// rows and columns, and then optionally flipping the rows and the columns.  Swapping only makes sense
+// for square grids.
+type dihedral struct {
+	rows        int
+	columns     int
+	transpose   bool
+	flipRows    bool
+	flipColumns bool
+}
+
+// getDihedrals returns the rotations and reflections of a grid other than the identity; all seven of
+// them for a square grid, and the three that don't swap rows and columns otherwise.
+func getDihedrals(rows int, columns int) []dihedral {
+
+	dihedrals := make([]dihedral, 0)
+	for i := 1; i < 8; i++ {
+
+		d := dihedral{rows: rows, columns: columns, transpose: i&4 != 0, flipRows: i&2 != 0, flipColumns: i&1 != 0}
+		if !d.transpose || rows == columns {
+
+			dihedrals = append(dihedrals, d)
+		}
+	}
+
+	return dihedrals
+}
+
+// mapPoint returns where a point on the grid ends up.
+func (this dihedral) mapPoint(row int, column int) (int, int) {
+
+	if this.transpose {
+
+		row, column = column, row
+	}
+
+	if this.flipRows {
+
+		row = this.rows - 1 - row
+	}
+
+	if this.flipColumns {
+
+		column = this.columns - 1 - column
+	}
+
+	return row, column
+}
+
+// mapDirection returns which way a direction points after the transformation.
+func (this dihedral) mapDirection(direction GridDirection) GridDirection {
+
+	vectors := map[GridDirection][2]int{GridUp: {-1, 0}, GridDown: {1, 0}, GridLeft: {0, -1}, GridRight: {0, 1}}
+	v := vectors[direction]
+	if this.transpose {
+
+		v[0], v[1] = v[1], v[0]
+	}
+
+	if this.flipRows {
+
+		v[0] = -v[0]
+	}
+
+	if this.flipColumns {
+
+		v[1] = -v[1]
+	}
+
+	for d, vector := range vectors {
+
+		if vector == v {
+
+			return d
+		}
+	}
+
+	return direction
+}
+
+// invert returns the transformation that undoes this one.  Flips after a swap are the same as flips
+// of the other axis before it, so undoing one swaps which axis is flipped.
+func (this dihedral) invert() dihedral {
+
+	if this.transpose {
+
+		return dihedral{rows: this.rows, columns: this.columns, transpose: true, flipRows: this.flipColumns, flipColumns: this.flipRows}
+	}
+
+	return this
+}
+
+// boardSymmetry rotates or reflects the board of a board game, along with the moves on it.
+type boardSymmetry struct {
+	dihedral
+}
+
+// TransformState returns the state with the board rotated or reflected.
+func (this *boardSymmetry) TransformState(state State) State {
+
+	transformed := copyState(state)
+	board := transformed.Context[BoardContextKey]
+	cells := make([]byte, len(board))
+	for i := 0; i < len(board); i++ {
+
+		row, column := this.mapPoint(i/this.columns, i%this.columns)
+		cells[row*this.columns+column] = board[i]
+	}
+
+	transformed.Context[BoardContextKey] = string(cells)
+	return transformed
+}
+
+// TransformAction returns the move in the rotated or reflected position.
+func (this *boardSymmetry) TransformAction(action Action) Action {
+
+	switch action := action.(type) {
+	case *TicTacToeAction:
+		row, column := this.mapPoint(action.Cell/this.columns, action.Cell%this.columns)
+		return &TicTacToeAction{Cell: row*this.columns + column}
+	case *ConnectFourAction:
+		_, column := this.mapPoint(0, action.Column)
+		return &ConnectFourAction{Column: column}
+	}
+
+	return action
+}
+
+// Invert returns the transformation that undoes this one.
+func (this *boardSymmetry) Invert() Symmetry {
+
+	return &boardSymmetry{this.invert()}
+}
+
+// gridSymmetry rotates or reflects the position of an agent on a gridworld, along with its moves.
+type gridSymmetry struct {
+	dihedral
+}
+
+// TransformState returns the state with the position rotated or reflected.
+func (this *gridSymmetry) TransformState(state State) State {
+
+	transformed := copyState(state)
+	row, _ := strconv.Atoi(transformed.Context[gridworldRowContextKey])
+	column, _ := strconv.Atoi(transformed.Context[gridworldColumnContextKey])
+	row, column = this.mapPoint(row, column)
+	transformed.Context[gridworldRowContextKey] = strconv.Itoa(row)
+	transformed.Context[gridworldColumnContextKey] = strconv.Itoa(column)
+
+	return transformed
+}
+
+// TransformAction returns the move in the rotated or reflected direction.
+func (this *gridSymmetry) TransformAction(action Action) Action {
+
+	if action, ok := action.(*GridAction); ok {
+
+		return &GridAction{Direction: this.mapDirection(action.Direction)}
+	}
+
+	return action
+}
+
+// Invert returns the transformation that undoes this one.
+func (this *gridSymmetry) Invert() Symmetry {
+
+	return &gridSymmetry{this.invert()}
+}
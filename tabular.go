@@ -0,0 +1,439 @@
+package monoikos
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"math"
+	"math/rand"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"gopkg.in/yaml.v2"
+)
+
+var tabularMDPContextKey = "mdp"
+var tabularStateContextKey = "state"
+var tabularRewardContextKey = "reward"
+
+// TabularTransition is a single row of a tabular MDP; the probability of moving to the next state when
+// an action is taken in a state, and the reward that's paid out when it happens.
+type TabularTransition struct {
+	State       string  `json:"state" yaml:"state"`
+	Action      string  `json:"action" yaml:"action"`
+	Next        string  `json:"next" yaml:"next"`
+	Probability float64 `json:"probability" yaml:"probability"`
+	Reward      int     `json:"reward" yaml:"reward"`
+}
+
+// TabularMDP is an environment that's defined entirely by a table rather than by code.  It lists the
+// states and actions by name, the distribution of starting states, which states are terminal, and the
+// transitions between states along with their probabilities and rewards.  It implements Environment
+// and Model, so it can be optimized by sampling or solved exactly, and it can be loaded from JSON or
+// YAML so that small problems can be defined without writing Go.
+type TabularMDP struct {
+	States      []string            `json:"states" yaml:"states"`
+	Actions     []string            `json:"actions" yaml:"actions"`
+	Start       map[string]float64  `json:"start" yaml:"start"`
+	Terminal    []string            `json:"terminal" yaml:"terminal"`
+	Transitions []TabularTransition `json:"transitions" yaml:"transitions"`
+	terminal    map[string]bool
+	table       map[string][]TabularTransition
+}
+
+// NewTabularMDP should be used to create a TabularMDP; it handles instantiating members appropriately.
+func NewTabularMDP() *TabularMDP {
+
+	mdp := new(TabularMDP)
+	mdp.States = make([]string, 0)
+	mdp.Actions = make([]string, 0)
+	mdp.Start = make(map[string]float64)
+	mdp.Terminal = make([]string, 0)
+	mdp.Transitions = make([]TabularTransition, 0)
+
+	return mdp
+}
+
+// NewTabularMDPFromJSON creates a TabularMDP from a JSON description and validates it.
+func NewTabularMDPFromJSON(b []byte) (*TabularMDP, error) {
+
+	mdp := NewTabularMDP()
+	err := json.Unmarshal(b, mdp)
+	if err != nil {
+
+		return nil, err
+	}
+
+	err = mdp.Validate()
+	if err != nil {
+
+		return nil, err
+	}
+
+	return mdp, nil
+}
+
+// NewTabularMDPFromYAML creates a TabularMDP from a YAML description and validates it.
+func NewTabularMDPFromYAML(b []byte) (*TabularMDP, error) {
+
+	mdp := NewTabularMDP()
+	err := yaml.Unmarshal(b, mdp)
+	if err != nil {
+
+		return nil, err
+	}
+
+	err = mdp.Validate()
+	if err != nil {
+
+		return nil, err
+	}
+
+	return mdp, nil
+}
+
+// LoadTabularMDP reads a TabularMDP from a file, which is parsed as YAML if it has a .yaml or .yml
+// extension and as JSON otherwise.
+func LoadTabularMDP(path string) (*TabularMDP, error) {
+
+	b, err := ioutil.ReadFile(path)
+	if err != nil {
+
+		return nil, err
+	}
+
+	extension := strings.ToLower(filepath.Ext(path))
+	if extension == ".yaml" || extension == ".yml" {
+
+		return NewTabularMDPFromYAML(b)
+	}
+
+	return NewTabularMDPFromJSON(b)
+}
+
+// Validate checks that every state and action that's referenced has been declared, that there's at
+// least one non-terminal state, and that the probabilities for each state and action pair (and the
+// start distribution) aren't negative and add up to one.  It also indexes the transitions, so it needs
+// to be called after the MDP is changed by hand.
+func (this *TabularMDP) Validate() error {
+
+	states := make(map[string]bool)
+	for _, state := range this.States {
+
+		states[state] = true
+	}
+
+	actions := make(map[string]bool)
+	for _, action := range this.Actions {
+
+		actions[action] = true
+	}
+
+	this.terminal = make(map[string]bool)
+	for _, state := range this.Terminal {
+
+		if !states[state] {
+
+			return fmt.Errorf("monoikos: terminal state '%v' isn't declared", state)
+		}
+
+		this.terminal[state] = true
+	}
+
+	// Index the transitions by state and action, checking that everything they reference exists.
+	this.table = make(map[string][]TabularTransition)
+	totals := make(map[string]float64)
+	for _, transition := range this.Transitions {
+
+		if !states[transition.State] || !states[transition.Next] {
+
+			return fmt.Errorf("monoikos: transition from '%v' to '%v' references an undeclared state", transition.State, transition.Next)
+		}
+
+		if !actions[transition.Action] {
+
+			return fmt.Errorf("monoikos: transition from '%v' references undeclared action '%v'", transition.State, transition.Action)
+		}
+
+		if this.terminal[transition.State] {
+
+			return fmt.Errorf("monoikos: transition from terminal state '%v'", transition.State)
+		}
+
+		if transition.Probability < 0 {
+
+			return fmt.Errorf("monoikos: transition from '%v' to '%v' has a negative probability", transition.State, transition.Next)
+		}
+
+		key := transition.State + "/" + transition.Action
+		this.table[key] = append(this.table[key], transition)
+		totals[key] += transition.Probability
+	}
+
+	for key, total := range totals {
+
+		if math.Abs(total-1.0) > 0.000001 {
+
+			return fmt.Errorf("monoikos: probabilities for '%v' add up to %v", key, total)
+		}
+	}
+
+	// Check the start distribution, which is optional.
+	total := 0.0
+	for state, probability := range this.Start {
+
+		if !states[state] {
+
+			return fmt.Errorf("monoikos: start state '%v' isn't declared", state)
+		}
+
+		if probability < 0 {
+
+			return fmt.Errorf("monoikos: start state '%v' has a negative probability", state)
+		}
+
+		total += probability
+	}
+
+	if len(this.Start) > 0 && math.Abs(total-1.0) > 0.000001 {
+
+		return fmt.Errorf("monoikos: start probabilities add up to %v", total)
+	}
+
+	// Every state that isn't terminal needs at least one action, or experiments would get stuck, and
+	// there needs to be at least one of them for experiments to start from.
+	nonTerminal := 0
+	for _, state := range this.States {
+
+		if this.terminal[state] {
+
+			continue
+		}
+
+		if len(this.getActionNames(state)) == 0 {
+
+			return errors.New("monoikos: non-terminal state '" + state + "' has no transitions")
+		}
+
+		nonTerminal++
+	}
+
+	if nonTerminal == 0 {
+
+		return errors.New("monoikos: there are no non-terminal states")
+	}
+
+	return nil
+}
+
+// CreateRandomPolicy creates a random policy for the MDP.
+func (this *TabularMDP) CreateRandomPolicy() Policy {
+
+	return CreateRandomPolicy(this)
+}
+
+// CreateImprovedPolicy creates an improved policy for the MDP from a set of outcomes.
+func (this *TabularMDP) CreateImprovedPolicy(outcomes []Outcome) Policy {
+
+	return CreateImprovedPolicy(this, outcomes)
+}
+
+// CreateOptimizedPolicy creates an optimized policy for the MDP by running iterations of experiments.
+func (this *TabularMDP) CreateOptimizedPolicy(initialRandomizationRate int, experimentsPerIteration int, iterations int) Policy {
+
+	return CreateOptimizedPolicy(this, initialRandomizationRate, experimentsPerIteration, iterations)
+}
+
+// CreateExperiment creates an experiment that starts in a state drawn from the start distribution, or
+// in a random non-terminal state if there isn't one.
+func (this *TabularMDP) CreateExperiment() Experiment {
+
+	experiment := new(TabularExperiment)
+	experiment.Context = make(map[string]interface{})
+	experiment.Context[tabularMDPContextKey] = this
+	experiment.Context[tabularStateContextKey] = this.getStartState()
+	experiment.Context[tabularRewardContextKey] = 0
+
+	return experiment
+}
+
+// GetLegalActions returns the actions that have transitions from a state, in the order they were declared.
+func (this *TabularMDP) GetLegalActions(state State) []Action {
+
+	actions := make([]Action, 0)
+	for _, name := range this.getActionNames(state.GetContext()[tabularStateContextKey]) {
+
+		actions = append(actions, &TabularAction{Name: name})
+	}
+
+	return actions
+}
+
+// GetKnownStates returns every declared state.
+func (this *TabularMDP) GetKnownStates() []State {
+
+	states := make([]State, 0)
+	for _, name := range this.States {
+
+		states = append(states, this.createState(name, 0))
+	}
+
+	return states
+}
+
+// GetSuccessors returns the states that may follow an action in a state along with their probabilities
+// and rewards, straight from the table.
+func (this *TabularMDP) GetSuccessors(state State, action Action) []Successor {
+
+	successors := make([]Successor, 0)
+	for _, transition := range this.table[state.GetContext()[tabularStateContextKey]+"/"+action.GetId()] {
+
+		successor := Successor{State: this.createState(transition.Next, 0)}
+		successor.Probability = transition.Probability
+		successor.Reward = float64(transition.Reward)
+		successors = append(successors, successor)
+	}
+
+	return successors
+}
+
+// getActionNames returns the names of the actions that have transitions from a named state.
+func (this *TabularMDP) getActionNames(state string) []string {
+
+	names := make([]string, 0)
+	for _, action := range this.Actions {
+
+		if _, ok := this.table[state+"/"+action]; ok {
+
+			names = append(names, action)
+		}
+	}
+
+	return names
+}
+
+// getStartState picks a starting state name.
+func (this *TabularMDP) getStartState() string {
+
+	// Without a start distribution, pick any state that isn't terminal.
+	if len(this.Start) == 0 {
+
+		names := make([]string, 0)
+		for _, state := range this.States {
+
+			if !this.terminal[state] {
+
+				names = append(names, state)
+			}
+		}
+
+		return names[rand.Intn(len(names))]
+	}
+
+	// Sort the names so that the same random number always picks the same state.
+	names := make([]string, 0)
+	for state := range this.Start {
+
+		names = append(names, state)
+	}
+	sort.Strings(names)
+
+	r := rand.Float64()
+	for _, state := range names {
+
+		r -= this.Start[state]
+		if r < 0 {
+
+			return state
+		}
+	}
+
+	return names[len(names)-1]
+}
+
+// sampleNext picks the state that follows an action in a named state, along with the reward paid out.
+func (this *TabularMDP) sampleNext(state string, action string) (string, int) {
+
+	transitions := this.table[state+"/"+action]
+	r := rand.Float64()
+	for _, transition := range transitions {
+
+		r -= transition.Probability
+		if r < 0 {
+
+			return transition.Next, transition.Reward
+		}
+	}
+
+	last := transitions[len(transitions)-1]
+	return last.Next, last.Reward
+}
+
+// createState creates the state for a name, which reports the reward that's been paid out so far.
+func (this *TabularMDP) createState(name string, reward int) *BasicState {
+
+	state := NewBasicState()
+	state.Context[tabularStateContextKey] = name
+	state.Terminal = this.terminal[name]
+	state.Reward = reward
+
+	return state
+}
+
+// TabularExperiment is a single walk thru a TabularMDP.
+type TabularExperiment struct {
+	Context map[string]interface{}
+	runnable
+}
+
+// ObserveState returns the current state of the experiment.
+func (this *TabularExperiment) ObserveState() State {
+
+	mdp := this.Context[tabularMDPContextKey].(*TabularMDP)
+	name := this.Context[tabularStateContextKey].(string)
+	reward := this.Context[tabularRewardContextKey].(int)
+
+	return mdp.createState(name, reward)
+}
+
+// Run follows the policy until a terminal state is reached.
+func (this *TabularExperiment) Run(policy Policy) []Outcome {
+
+	return this.getRunner().Run(this, policy)
+}
+
+// ForceRun takes an action and then follows the policy until a terminal state is reached.
+func (this *TabularExperiment) ForceRun(action Action, policy Policy) []Outcome {
+
+	return this.getRunner().ForceRun(this, action, policy)
+}
+
+// Apply runs an action against the experiment context.
+func (this *TabularExperiment) Apply(action Action) {
+
+	action.Run(this.Context)
+}
+
+// TabularAction is an action in a TabularMDP, identified by its name.
+type TabularAction struct {
+	Name string
+}
+
+// GetId returns the name of the action.
+func (this *TabularAction) GetId() string {
+
+	return this.Name
+}
+
+// Run moves the experiment to a state sampled from the transitions for the action in the current state.
+func (this *TabularAction) Run(context map[string]interface{}) {
+
+	mdp := context[tabularMDPContextKey].(*TabularMDP)
+	state := context[tabularStateContextKey].(string)
+
+	next, reward := mdp.sampleNext(state, this.Name)
+	context[tabularStateContextKey] = next
+	context[tabularRewardContextKey] = context[tabularRewardContextKey].(int) + reward
+}
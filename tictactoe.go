@@ -0,0 +1,166 @@
+package monoikos
+
+import (
+	"strconv"
+	"strings"
+)
+
+// BoardContextKey and TurnContextKey are the keys used in the context of board game states; the board
+// as a string with one character per cell, and the index of the player whose turn it is.
+var (
+	BoardContextKey = "board"
+	TurnContextKey  = "turn"
+)
+
+// Board game cells, and the marks of the first and second players.
+const (
+	BoardEmpty  = '.'
+	BoardFirst  = 'X'
+	BoardSecond = 'O'
+)
+
+// boardMarks are the marks of each player, by index.
+var boardMarks = []byte{BoardFirst, BoardSecond}
+
+// TicTacToe is the game of noughts and crosses for two players, where the first player is X.  The
+// board is a string of nine cells in rows from the top left.  A win pays out one to the winner and
+// minus one to the loser, and a draw pays out nothing.  It's deterministic, so it can be solved
+// exactly by a MinimaxSolver, and with perfect play from both sides it's a draw.
+type TicTacToe struct {
+}
+
+// NewTicTacToe should be used to create a TicTacToe.
+func NewTicTacToe() *TicTacToe {
+
+	return new(TicTacToe)
+}
+
+// GetPlayers returns two.
+func (this *TicTacToe) GetPlayers() int {
+
+	return 2
+}
+
+// CreateMatch creates a match on an empty board.
+func (this *TicTacToe) CreateMatch() Match {
+
+	return NewDeterministicMatch(this)
+}
+
+// GetLegalActions returns a move for every empty cell.
+func (this *TicTacToe) GetLegalActions(state State) []Action {
+
+	board := state.GetContext()[BoardContextKey]
+	actions := make([]Action, 0)
+	for i := 0; i < len(board); i++ {
+
+		if board[i] == BoardEmpty {
+
+			actions = append(actions, &TicTacToeAction{Cell: i})
+		}
+	}
+
+	return actions
+}
+
+// GetInitialState returns an empty board with X to play.
+func (this *TicTacToe) GetInitialState() State {
+
+	return createBoardState(strings.Repeat(string(BoardEmpty), 9), 0, false)
+}
+
+// GetNextState returns the board after the player whose turn it is marks a cell.
+func (this *TicTacToe) GetNextState(state State, action Action) State {
+
+	board := []byte(state.GetContext()[BoardContextKey])
+	player := this.GetCurrentPlayer(state)
+	board[action.(*TicTacToeAction).Cell] = boardMarks[player]
+
+	terminal := getTicTacToeWinner(string(board)) != BoardEmpty || !strings.ContainsRune(string(board), BoardEmpty)
+	return createBoardState(string(board), 1-player, terminal)
+}
+
+// GetCurrentPlayer returns the player whose turn it is.
+func (this *TicTacToe) GetCurrentPlayer(state State) int {
+
+	player, _ := strconv.Atoi(state.GetContext()[TurnContextKey])
+	return player
+}
+
+// GetRewards returns one for the winner and minus one for the loser, or nothing for a draw.
+func (this *TicTacToe) GetRewards(state State) []int {
+
+	return getBoardRewards(getTicTacToeWinner(state.GetContext()[BoardContextKey]))
+}
+
+// ticTacToeLines are the rows, columns and diagonals of the board.
+var ticTacToeLines = [][3]int{
+	{0, 1, 2}, {3, 4, 5}, {6, 7, 8},
+	{0, 3, 6}, {1, 4, 7}, {2, 5, 8},
+	{0, 4, 8}, {2, 4, 6},
+}
+
+// getTicTacToeWinner returns the mark of the player with three in a row, if there is one.
+func getTicTacToeWinner(board string) byte {
+
+	for _, line := range ticTacToeLines {
+
+		if board[line[0]] != BoardEmpty && board[line[0]] == board[line[1]] && board[line[1]] == board[line[2]] {
+
+			return board[line[0]]
+		}
+	}
+
+	return BoardEmpty
+}
+
+// getBoardRewards returns the rewards for a board game given the mark of the winner.
+func getBoardRewards(winner byte) []int {
+
+	switch winner {
+	case BoardFirst:
+		return []int{1, -1}
+	case BoardSecond:
+		return []int{-1, 1}
+	}
+
+	return []int{0, 0}
+}
+
+// createBoardState creates the state of a board game.
+func createBoardState(board string, player int, terminal bool) *BasicState {
+
+	state := NewBasicState()
+	state.Context[BoardContextKey] = board
+	state.Context[TurnContextKey] = strconv.Itoa(player)
+	state.Terminal = terminal
+
+	return state
+}
+
+// TicTacToeAction marks a cell, numbered from zero in rows from the top left.
+type TicTacToeAction struct {
+	Cell int
+}
+
+// GetId returns an identifier for the cell.
+func (this *TicTacToeAction) GetId() string {
+
+	return "Cell " + strconv.Itoa(this.Cell)
+}
+
+// Run does nothing, since moves are made by the match.
+func (this *TicTacToeAction) Run(context map[string]interface{}) {
+}
+
+// GetSymmetries returns the seven rotations and reflections of the board.
+func (this *TicTacToe) GetSymmetries() []Symmetry {
+
+	symmetries := make([]Symmetry, 0)
+	for _, d := range getDihedrals(3, 3) {
+
+		symmetries = append(symmetries, &boardSymmetry{d})
+	}
+
+	return symmetries
+}
@@ -0,0 +1,278 @@
+package monoikos
+
+import (
+	"math/rand"
+)
+
+var tigerContextKey = "tiger"
+var tigerDoorContextKey = "door"
+var tigerRewardContextKey = "reward"
+var tigerStepsContextKey = "steps"
+var tigerOpenContextKey = "open"
+
+// TigerHeardContextKey is the key for what was heard in the context of tiger observations, and
+// TigerSideContextKey is the key for which door the tiger is behind in the context of hidden states.
+var (
+	TigerHeardContextKey = "heard"
+	TigerSideContextKey  = "tiger"
+)
+
+// Tiger doors and the moves that can be made.
+const (
+	TigerLeft      = "left"
+	TigerRight     = "right"
+	TigerNone      = "none"
+	TigerListen    = "Listen"
+	TigerOpenLeft  = "Open left"
+	TigerOpenRight = "Open right"
+)
+
+// Tiger is the classic partially observable problem, where a tiger is behind one of two doors and
+// treasure is behind the other.  The agent can open a door, which ends the experiment, or pay to
+// listen, which tells it which side the tiger is on but is only right some of the time.  The side
+// the tiger is on is hidden, so the agent only observes what it last heard.  Tiger implements Model
+// and ObservationModel over the hidden states, so that a BayesianBelief can be kept for it.
+type Tiger struct {
+	ListenAccuracy float64
+	ListenCost     int
+	TreasureReward int
+	TigerPenalty   int
+	MaxSteps       int
+}
+
+// NewTiger should be used to create a Tiger; listening is right 85% of the time and costs 1, the
+// treasure is worth 10 and the tiger costs 100.
+func NewTiger() *Tiger {
+
+	tiger := new(Tiger)
+	tiger.ListenAccuracy = 0.85
+	tiger.ListenCost = 1
+	tiger.TreasureReward = 10
+	tiger.TigerPenalty = 100
+	tiger.MaxSteps = 100
+
+	return tiger
+}
+
+// CreateRandomPolicy creates a random tiger policy.
+func (this *Tiger) CreateRandomPolicy() Policy {
+
+	return CreateRandomPolicy(this)
+}
+
+// CreateImprovedPolicy creates an improved tiger policy from a set of outcomes.
+func (this *Tiger) CreateImprovedPolicy(outcomes []Outcome) Policy {
+
+	return CreateImprovedPolicy(this, outcomes)
+}
+
+// CreateOptimizedPolicy creates an optimized tiger policy by running iterations of experiments.
+func (this *Tiger) CreateOptimizedPolicy(initialRandomizationRate int, experimentsPerIteration int, iterations int) Policy {
+
+	return CreateOptimizedPolicy(this, initialRandomizationRate, experimentsPerIteration, iterations)
+}
+
+// CreateExperiment creates an experiment with the tiger behind a random door.
+func (this *Tiger) CreateExperiment() Experiment {
+
+	side := TigerLeft
+	if rand.Intn(2) == 1 {
+
+		side = TigerRight
+	}
+
+	experiment := new(TigerExperiment)
+	experiment.Context = make(map[string]interface{})
+	experiment.Context[tigerContextKey] = this
+	experiment.Context[tigerDoorContextKey] = side
+	experiment.Context[tigerRewardContextKey] = 0
+	experiment.Context[tigerStepsContextKey] = 0
+	experiment.Context[tigerOpenContextKey] = false
+	experiment.Context[TigerHeardContextKey] = TigerNone
+
+	return experiment
+}
+
+// GetLegalActions returns the three moves, which are legal whatever has been heard.
+func (this *Tiger) GetLegalActions(state State) []Action {
+
+	actions := make([]Action, 3)
+	actions[0] = &TigerAction{Move: TigerListen}
+	actions[1] = &TigerAction{Move: TigerOpenLeft}
+	actions[2] = &TigerAction{Move: TigerOpenRight}
+	return actions
+}
+
+// GetKnownStates returns the observations of having heard nothing yet, or the tiger on either side.
+func (this *Tiger) GetKnownStates() []State {
+
+	states := make([]State, 0)
+	for _, heard := range []string{TigerNone, TigerLeft, TigerRight} {
+
+		states = append(states, createTigerObservation(heard, false, 0))
+	}
+
+	return states
+}
+
+// GetHiddenStates returns the hidden states that an experiment can start in.
+func (this *Tiger) GetHiddenStates() []State {
+
+	return []State{createTigerState(TigerLeft, false, 0), createTigerState(TigerRight, false, 0)}
+}
+
+// GetSuccessors returns where the tiger is after a move, which is where it was; opening a door ends
+// the experiment with either the treasure or the tiger.
+func (this *Tiger) GetSuccessors(state State, action Action) []Successor {
+
+	if state.IsTerminal() {
+
+		return []Successor{}
+	}
+
+	side := state.GetContext()[TigerSideContextKey]
+	move := action.(*TigerAction).Move
+	if move == TigerListen {
+
+		return []Successor{{State: createTigerState(side, false, 0), Probability: 1, Reward: float64(-this.ListenCost)}}
+	}
+
+	return []Successor{{State: createTigerState(side, true, 0), Probability: 1, Reward: float64(this.getOpenReward(move, side))}}
+}
+
+// GetObservationProbability returns how likely it is to hear the tiger on a side, given the side it's
+// really on.  Nothing is heard unless the agent listens.
+func (this *Tiger) GetObservationProbability(action Action, state State, observation State) float64 {
+
+	heard := observation.GetContext()[TigerHeardContextKey]
+	if heard == TigerNone {
+
+		return 1
+	}
+
+	if heard == state.GetContext()[TigerSideContextKey] {
+
+		return this.ListenAccuracy
+	}
+
+	return 1 - this.ListenAccuracy
+}
+
+// getOpenReward returns the reward for opening a door when the tiger is on a side.
+func (this *Tiger) getOpenReward(move string, side string) int {
+
+	if (move == TigerOpenLeft) == (side == TigerLeft) {
+
+		return -this.TigerPenalty
+	}
+
+	return this.TreasureReward
+}
+
+// createTigerObservation creates what the agent observes.
+func createTigerObservation(heard string, terminal bool, reward int) *BasicState {
+
+	state := NewBasicState()
+	state.Context[TigerHeardContextKey] = heard
+	state.Terminal = terminal
+	state.Reward = reward
+
+	return state
+}
+
+// createTigerState creates the hidden state.
+func createTigerState(side string, terminal bool, reward int) *BasicState {
+
+	state := NewBasicState()
+	state.Context[TigerSideContextKey] = side
+	state.Terminal = terminal
+	state.Reward = reward
+
+	return state
+}
+
+// TigerExperiment is a single attempt at finding the treasure.
+type TigerExperiment struct {
+	Context map[string]interface{}
+	runnable
+}
+
+// ObserveState returns what was last heard, which is terminal once a door has been opened or the
+// maximum number of steps has been taken.
+func (this *TigerExperiment) ObserveState() State {
+
+	tiger := this.Context[tigerContextKey].(*Tiger)
+	heard := this.Context[TigerHeardContextKey].(string)
+	reward := this.Context[tigerRewardContextKey].(int)
+	steps := this.Context[tigerStepsContextKey].(int)
+	open := this.Context[tigerOpenContextKey].(bool)
+
+	return createTigerObservation(heard, open || (tiger.MaxSteps > 0 && steps >= tiger.MaxSteps), reward)
+}
+
+// ObserveHiddenState returns which side the tiger is really on.
+func (this *TigerExperiment) ObserveHiddenState() State {
+
+	side := this.Context[tigerDoorContextKey].(string)
+	observation := this.ObserveState()
+
+	return createTigerState(side, observation.IsTerminal(), observation.GetReward())
+}
+
+// Run follows the policy until a door is opened.
+func (this *TigerExperiment) Run(policy Policy) []Outcome {
+
+	return this.getRunner().Run(this, policy)
+}
+
+// ForceRun makes a move and then follows the policy until a door is opened.
+func (this *TigerExperiment) ForceRun(action Action, policy Policy) []Outcome {
+
+	return this.getRunner().ForceRun(this, action, policy)
+}
+
+// Apply runs an action against the experiment context.
+func (this *TigerExperiment) Apply(action Action) {
+
+	action.Run(this.Context)
+}
+
+// TigerAction is either listening or opening a door.
+type TigerAction struct {
+	Move string
+}
+
+// GetId returns the name of the move.
+func (this *TigerAction) GetId() string {
+
+	return this.Move
+}
+
+// Run makes the move, hearing the tiger on one side or the other when listening.
+func (this *TigerAction) Run(context map[string]interface{}) {
+
+	tiger := context[tigerContextKey].(*Tiger)
+	side := context[tigerDoorContextKey].(string)
+	context[tigerStepsContextKey] = context[tigerStepsContextKey].(int) + 1
+
+	if this.Move != TigerListen {
+
+		context[tigerOpenContextKey] = true
+		context[TigerHeardContextKey] = TigerNone
+		context[tigerRewardContextKey] = context[tigerRewardContextKey].(int) + tiger.getOpenReward(this.Move, side)
+		return
+	}
+
+	heard := side
+	if rand.Float64() >= tiger.ListenAccuracy {
+
+		heard = TigerLeft
+		if side == TigerLeft {
+
+			heard = TigerRight
+		}
+	}
+
+	context[TigerHeardContextKey] = heard
+	context[tigerRewardContextKey] = context[tigerRewardContextKey].(int) - tiger.ListenCost
+}
@@ -0,0 +1,311 @@
+package monoikos
+
+import (
+	"context"
+	"errors"
+	"math"
+	"time"
+)
+
+// CreateOptimizedPolicyContext optimizes a policy in the same way as TryCreateOptimizedPolicy, but stops
+// early if the context is cancelled or its deadline passes.  The context is checked before every
+// experiment, so training stops within an experiment of being asked to.  When it stops early it
+// returns the best policy it has so far, which is the policy from the last iteration that finished, or
+// a policy improved from whatever was run in the first iteration if none has, along with the error
+// from the context.
+func CreateOptimizedPolicyContext(ctx context.Context, environment Environment, initialRandomizationRate int, experimentsPerIteration int, iterations int, mode FailureMode) (Policy, error) {
+
+	config := &TrainingConfig{InitialRandomizationRate: initialRandomizationRate, ExperimentsPerIteration: experimentsPerIteration, Iterations: iterations, FailureMode: mode, MaxSteps: DefaultMaxSteps}
+	policy, _, err := TrainPolicy(ctx, environment, config)
+	return policy, err
+}
+
+// CreateOptimizedPolicyForDuration optimizes a policy in the same way as CreateOptimizedPolicyContext,
+// but rather than running a fixed number of experiments it splits the duration evenly between the
+// iterations and runs as many experiments as fit into each of them.  Running out of time is how it's
+// meant to finish, so no error is returned for it, but it still stops early with the context's error if
+// the context is cancelled or its own deadline passes first.
+func CreateOptimizedPolicyForDuration(ctx context.Context, environment Environment, initialRandomizationRate int, duration time.Duration, iterations int, mode FailureMode) (Policy, error) {
+
+	config := &TrainingConfig{InitialRandomizationRate: initialRandomizationRate, Duration: duration, Iterations: iterations, FailureMode: mode, MaxSteps: DefaultMaxSteps}
+	policy, _, err := TrainPolicy(ctx, environment, config)
+	return policy, err
+}
+
+// TrainingConfig holds the settings for training a policy.  If there's a duration it's split evenly
+// between the iterations, and each iteration runs as many experiments as fit; otherwise each iteration
+// runs the given number of experiments.  Experiments that can be told which runner to use are
+// truncated after the maximum number of steps, where zero means no limit, and the hooks are told about
+// every step and episode of them.  Untried actions are treated as the exploration says when the
+// policy is improved.
+type TrainingConfig struct {
+	InitialRandomizationRate int
+	ExperimentsPerIteration  int
+	Iterations               int
+	Duration                 time.Duration
+	FailureMode              FailureMode
+	MaxSteps                 int
+	Hooks                    []EpisodeHook
+	Exploration              Exploration
+}
+
+// NewTrainingConfig should be used to create a TrainingConfig; it starts with reasonable defaults.
+func NewTrainingConfig() *TrainingConfig {
+
+	config := new(TrainingConfig)
+	config.InitialRandomizationRate = 40
+	config.ExperimentsPerIteration = 1000
+	config.Iterations = 5
+	config.FailureMode = AbortOnFailure
+	config.MaxSteps = DefaultMaxSteps
+	config.Hooks = make([]EpisodeHook, 0)
+
+	return config
+}
+
+// Validate returns an error if the config can't be trained with; there has to be at least one
+// iteration, and neither the number of experiments nor the duration can be negative.
+func (this *TrainingConfig) Validate() error {
+
+	if this.Iterations < 1 {
+
+		return errors.New("monoikos: there has to be at least one iteration")
+	}
+
+	if this.ExperimentsPerIteration < 0 {
+
+		return errors.New("monoikos: the number of experiments per iteration can't be negative")
+	}
+
+	if this.Duration < 0 {
+
+		return errors.New("monoikos: the duration can't be negative")
+	}
+
+	return nil
+}
+
+// createRunner creates an episode runner with the settings in the config.
+func (this *TrainingConfig) createRunner() *EpisodeRunner {
+
+	runner := NewEpisodeRunner()
+	runner.MaxSteps = this.MaxSteps
+	runner.Hooks = append(runner.Hooks, this.Hooks...)
+	return runner
+}
+
+// IterationReport describes a single iteration of training; the randomization rate it ran with, how
+// many experiments were run and how many of them failed, the average reward that the experiments
+// that didn't fail paid out, and how long it took.
+type IterationReport struct {
+	Iteration         int           `json:"iteration"`
+	RandomizationRate int           `json:"randomization_rate"`
+	Experiments       int           `json:"experiments"`
+	Failures          int           `json:"failures"`
+	AverageReward     float64       `json:"average_reward"`
+	Duration          time.Duration `json:"duration"`
+}
+
+// TrainingReport describes how training went, iteration by iteration.  It also holds the average
+// reward and the number of visits for every outcome in the last iteration, keyed by outcome
+// identifier, which are what the final policy was created from.
+type TrainingReport struct {
+	Iterations []*IterationReport `json:"iterations"`
+	Values     map[string]float64 `json:"-"`
+	Visits     map[string]int     `json:"-"`
+}
+
+// TrainPolicy optimizes a policy in the same way as CreateOptimizedPolicyContext, with the settings in
+// the config, and reports on how every iteration went.  The report covers the iterations that were
+// run, even if training stopped early.  It returns an error without training if the config isn't
+// valid.
+func TrainPolicy(ctx context.Context, environment Environment, config *TrainingConfig) (Policy, *TrainingReport, error) {
+
+	err := config.Validate()
+	if err != nil {
+
+		return nil, nil, err
+	}
+
+	report := new(TrainingReport)
+	report.Iterations = make([]*IterationReport, 0)
+
+	start := time.Now()
+	more := func(iteration int, experiments int) bool {
+
+		return experiments < config.ExperimentsPerIteration
+	}
+
+	if config.Duration > 0 {
+
+		more = func(iteration int, experiments int) bool {
+
+			// Every iteration gets to run at least one experiment, so there's always something to learn from.
+			end := start.Add(config.Duration * time.Duration(iteration+1) / time.Duration(config.Iterations))
+			return experiments == 0 || time.Now().Before(end)
+		}
+	}
+
+	policy, err := optimizePolicy(ctx, environment, config, more, report)
+	return policy, report, err
+}
+
+// optimizePolicy runs iterations of experiments and improves the policy after each of them, for as long
+// as the function says to keep running experiments in an iteration and the context hasn't been
+// cancelled.  Each iteration is added to the report as it finishes.
+func optimizePolicy(ctx context.Context, environment Environment, config *TrainingConfig, more func(iteration int, experiments int) bool, report *TrainingReport) (Policy, error) {
+
+	policy := environment.CreateRandomPolicy()
+	improved := false
+	runner := config.createRunner()
+	iterations := config.Iterations
+
+	// Loop for the number of desired iterations, counting up so that the iteration can be used to
+	// share out time.
+	for iteration := 0; iteration < iterations; iteration++ {
+
+		// Set a randomization rate that decreases with each iteration.  The -1 is because we always want
+		// an extra iteration at 0 randomization, which is the only iteration if there's just one.
+		i := iterations - 1 - iteration
+		randomizationRate := 0
+		if iterations > 1 {
+
+			randomizationRate = int(float64(config.InitialRandomizationRate) * (float64(i) / float64(iterations-1)))
+		}
+		policy.SetRandomizationRate(randomizationRate)
+
+		started := time.Now()
+		iterationReport := &IterationReport{Iteration: iteration + 1, RandomizationRate: randomizationRate}
+		total := 0
+
+		// Run experiments to generate sets of outcomes to improve the policy.
+		outcomes := []Outcome{}
+		for experiments := 0; more(iteration, experiments); experiments++ {
+
+			// Stop if we've been asked to, and hand back the best policy we have.
+			err := ctx.Err()
+			if err != nil && (improved || len(outcomes) == 0) {
+
+				policy.SetRandomizationRate(0)
+				return policy, err
+
+			} else if err != nil {
+
+				policy = CreateExploringImprovedPolicy(environment, outcomes, config.Exploration)
+				policy.SetRandomizationRate(0)
+				return policy, err
+			}
+
+			iterationReport.Experiments++
+			experiment := environment.CreateExperiment()
+			SetRunner(experiment, runner)
+			run, err := TryRunExperiment(experiment, policy)
+			if err != nil && config.FailureMode == AbortOnFailure {
+
+				return nil, err
+
+			} else if err != nil {
+
+				iterationReport.Failures++
+				continue
+			}
+
+			if len(run) > 0 {
+
+				total += run[0].GetReward()
+			}
+
+			outcomes = append(outcomes, run...)
+		}
+
+		// Create the improved policy and use it moving forward.
+		policy = CreateExploringImprovedPolicy(environment, outcomes, config.Exploration)
+		improved = true
+
+		if succeeded := iterationReport.Experiments - iterationReport.Failures; succeeded > 0 {
+
+			iterationReport.AverageReward = float64(total) / float64(succeeded)
+		}
+
+		iterationReport.Duration = time.Since(started)
+		report.Iterations = append(report.Iterations, iterationReport)
+		report.Values = GetAverageRewards(outcomes)
+		report.Visits = getVisits(outcomes)
+	}
+
+	// Set the final randomization rate to zero and return the policy.
+	policy.SetRandomizationRate(0)
+	return policy, nil
+}
+
+// Evaluation is how well a policy did over a number of experiments; the average reward that the
+// experiments that didn't fail paid out and its standard error, and how many experiments failed.
+type Evaluation struct {
+	Experiments   int     `json:"experiments"`
+	Failures      int     `json:"failures"`
+	AverageReward float64 `json:"average_reward"`
+	StandardError float64 `json:"standard_error"`
+}
+
+// EvaluatePolicy runs a number of experiments with a policy, exactly as it is, and reports how well it
+// did.  Experiments that fail are counted but otherwise left out.
+func EvaluatePolicy(environment Environment, policy Policy, experiments int) *Evaluation {
+
+	return EvaluatePolicyWithRunner(environment, policy, experiments, NewEpisodeRunner())
+}
+
+// EvaluatePolicyWithRunner evaluates a policy in the same way as EvaluatePolicy, but tells experiments
+// that can be told to run with the given runner, such as one with hooks that record every episode.
+func EvaluatePolicyWithRunner(environment Environment, policy Policy, experiments int, runner *EpisodeRunner) *Evaluation {
+
+	evaluation := &Evaluation{Experiments: experiments}
+	sum := 0.0
+	squares := 0.0
+	for i := 0; i < experiments; i++ {
+
+		experiment := environment.CreateExperiment()
+		SetRunner(experiment, runner)
+		outcomes, err := TryRunExperiment(experiment, policy)
+		if err != nil {
+
+			evaluation.Failures++
+			continue
+		}
+
+		reward := 0.0
+		if len(outcomes) > 0 {
+
+			reward = float64(outcomes[0].GetReward())
+		}
+
+		sum += reward
+		squares += reward * reward
+	}
+
+	// Work out the mean and the standard error of the mean from the sums.
+	n := float64(experiments - evaluation.Failures)
+	if n > 0 {
+
+		evaluation.AverageReward = sum / n
+	}
+
+	if n > 1 {
+
+		variance := (squares - sum*sum/n) / (n - 1)
+		evaluation.StandardError = math.Sqrt(math.Max(variance, 0) / n)
+	}
+
+	return evaluation
+}
+
+// getVisits returns the number of times each outcome occurs, keyed by outcome identifier.
+func getVisits(outcomes []Outcome) map[string]int {
+
+	visits := make(map[string]int)
+	for _, outcome := range outcomes {
+
+		visits[outcome.GetId()]++
+	}
+
+	return visits
+}
@@ -0,0 +1,344 @@
+package monoikos
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// TrajectoryFormat is the version of the on disk format of trajectory records, which is written with
+// every record so that tools can tell what they're reading.  Fields may be added without changing it,
+// but it changes if any are removed or change meaning.
+const TrajectoryFormat = 1
+
+// TrajectoryRecord is the on disk representation of a single episode; every step that was taken, and
+// the state the episode finished in.  The run is whatever the run that the episode was part of was
+// called, and the policy version is whatever the policy was called, both as they were given to the
+// recorder.
+type TrajectoryRecord struct {
+	Format        int               `json:"format"`
+	Episode       int64             `json:"episode"`
+	Time          time.Time         `json:"time"`
+	Run           string            `json:"run,omitempty"`
+	PolicyVersion string            `json:"policy_version,omitempty"`
+	Steps         []*TrajectoryStep `json:"steps"`
+	Final         map[string]string `json:"final"`
+	Reward        int               `json:"reward"`
+	Terminal      bool              `json:"terminal"`
+	Truncated     bool              `json:"truncated,omitempty"`
+}
+
+// TrajectoryStep is a single step in a trajectory record; the context of the state the action was
+// taken in, the action by identifier, the reward for just this step, whether it ended the episode, and
+// how likely the policy was to take the action, which is left out if the policy couldn't say.
+type TrajectoryStep struct {
+	Context     map[string]string `json:"context"`
+	Action      string            `json:"action"`
+	Reward      int               `json:"reward"`
+	Terminal    bool              `json:"terminal"`
+	Probability float64           `json:"probability,omitempty"`
+}
+
+// TrajectoryRecorder is an EpisodeHook that writes every episode to JSON lines files in a directory, one
+// record per line, so that there's a record of what a policy did.  Files are named with the prefix and
+// a sequence number, and a new file is started once the current one would grow past the maximum size;
+// if there's a maximum number of files, the oldest are removed to stay within it.  Numbering carries on
+// from any files that are already there.  Adding a recorder to the hooks of a training config records
+// every experiment run while training, and adding it to a runner records every experiment run with
+// it.  Hooks can't return errors, so the first error is kept and returned by Err and Close, and
+// nothing more is written after it.
+type TrajectoryRecorder struct {
+	Directory     string
+	Prefix        string
+	MaxBytes      int64
+	MaxFiles      int
+	Run           string
+	PolicyVersion string
+	episode       int64
+	sequence      int
+	file          *os.File
+	writer        *bufio.Writer
+	size          int64
+	err           error
+	mutex         sync.Mutex
+}
+
+// NewTrajectoryRecorder should be used to create a TrajectoryRecorder; it creates the directory if it
+// needs to, and starts with files of up to 64MB and no limit on how many there are.
+func NewTrajectoryRecorder(directory string) (*TrajectoryRecorder, error) {
+
+	err := os.MkdirAll(directory, 0755)
+	if err != nil {
+
+		return nil, err
+	}
+
+	recorder := new(TrajectoryRecorder)
+	recorder.Directory = directory
+	recorder.Prefix = "trajectories"
+	recorder.MaxBytes = 64 << 20
+
+	return recorder, nil
+}
+
+// SetPolicyVersion sets the policy version that's written with the episodes that follow.
+func (this *TrajectoryRecorder) SetPolicyVersion(version string) {
+
+	this.mutex.Lock()
+	defer this.mutex.Unlock()
+
+	this.PolicyVersion = version
+}
+
+// OnStep does nothing, since whole episodes are written once they're over.
+func (this *TrajectoryRecorder) OnStep(outcome Outcome) {
+}
+
+// OnEpisode writes the episode, unless it didn't take any steps.
+func (this *TrajectoryRecorder) OnEpisode(outcomes []Outcome) {
+
+	if len(outcomes) == 0 {
+
+		return
+	}
+
+	this.mutex.Lock()
+	defer this.mutex.Unlock()
+
+	if this.err != nil {
+
+		return
+	}
+
+	this.episode++
+	record := &TrajectoryRecord{Format: TrajectoryFormat, Episode: this.episode, Time: time.Now().UTC(), Run: this.Run, PolicyVersion: this.PolicyVersion}
+	record.Steps = make([]*TrajectoryStep, 0)
+	for i, transition := range GetTransitions(outcomes) {
+
+		step := &TrajectoryStep{Context: transition.State.GetContext(), Action: transition.Action.GetId(), Reward: transition.Reward, Terminal: transition.Terminal}
+		step.Probability = GetBehaviorProbability(outcomes[i])
+		record.Steps = append(record.Steps, step)
+	}
+
+	final := outcomes[0].GetFinalState()
+	record.Final = final.GetContext()
+	record.Reward = outcomes[0].GetReward()
+	record.Terminal = final.IsTerminal()
+	record.Truncated = IsTruncated(outcomes[0])
+
+	this.err = this.write(record)
+}
+
+// Flush writes anything that's buffered to the current file.
+func (this *TrajectoryRecorder) Flush() error {
+
+	this.mutex.Lock()
+	defer this.mutex.Unlock()
+
+	if this.err == nil && this.writer != nil {
+
+		this.err = this.writer.Flush()
+	}
+
+	return this.err
+}
+
+// Err returns the first error the recorder ran into, if there was one.
+func (this *TrajectoryRecorder) Err() error {
+
+	this.mutex.Lock()
+	defer this.mutex.Unlock()
+
+	return this.err
+}
+
+// Close writes anything that's buffered and closes the current file.  Recording can carry on after
+// it's closed, in a new file.
+func (this *TrajectoryRecorder) Close() error {
+
+	this.mutex.Lock()
+	defer this.mutex.Unlock()
+
+	err := this.closeFile()
+	if this.err == nil {
+
+		this.err = err
+	}
+
+	return this.err
+}
+
+// GetFiles returns the paths of the files that have been written, oldest first.
+func (this *TrajectoryRecorder) GetFiles() ([]string, error) {
+
+	paths, err := filepath.Glob(filepath.Join(this.Directory, this.Prefix+"-*.jsonl"))
+	if err != nil {
+
+		return nil, err
+	}
+
+	files := make([]string, 0)
+	for _, path := range paths {
+
+		if this.getSequence(path) > 0 {
+
+			files = append(files, path)
+		}
+	}
+
+	sort.Slice(files, func(i int, j int) bool { return this.getSequence(files[i]) < this.getSequence(files[j]) })
+	return files, nil
+}
+
+// write writes a record as a line, starting a new file first if there isn't one or the record wouldn't
+// fit in the current one.
+func (this *TrajectoryRecorder) write(record *TrajectoryRecord) error {
+
+	b, err := json.Marshal(record)
+	if err != nil {
+
+		return err
+	}
+
+	b = append(b, '\n')
+	if this.file == nil || (this.MaxBytes > 0 && this.size > 0 && this.size+int64(len(b)) > this.MaxBytes) {
+
+		err = this.rotate()
+		if err != nil {
+
+			return err
+		}
+	}
+
+	n, err := this.writer.Write(b)
+	this.size += int64(n)
+	return err
+}
+
+// rotate closes the current file, starts the next one, and removes the oldest files if there are too
+// many.
+func (this *TrajectoryRecorder) rotate() error {
+
+	err := this.closeFile()
+	if err != nil {
+
+		return err
+	}
+
+	files, err := this.GetFiles()
+	if err != nil {
+
+		return err
+	}
+
+	// Carry on numbering from the newest file that's there.
+	if len(files) > 0 && this.getSequence(files[len(files)-1]) > this.sequence {
+
+		this.sequence = this.getSequence(files[len(files)-1])
+	}
+
+	this.sequence++
+	path := filepath.Join(this.Directory, fmt.Sprintf("%v-%06d.jsonl", this.Prefix, this.sequence))
+	this.file, err = os.OpenFile(path, os.O_CREATE|os.O_EXCL|os.O_WRONLY, 0644)
+	if err != nil {
+
+		return err
+	}
+
+	this.writer = bufio.NewWriter(this.file)
+	this.size = 0
+	files = append(files, path)
+	for this.MaxFiles > 0 && len(files) > this.MaxFiles {
+
+		err = os.Remove(files[0])
+		if err != nil {
+
+			return err
+		}
+
+		files = files[1:]
+	}
+
+	return nil
+}
+
+// closeFile writes anything that's buffered and closes the current file, if there is one.
+func (this *TrajectoryRecorder) closeFile() error {
+
+	if this.file == nil {
+
+		return nil
+	}
+
+	err := this.writer.Flush()
+	closeErr := this.file.Close()
+	this.file = nil
+	this.writer = nil
+	if err != nil {
+
+		return err
+	}
+
+	return closeErr
+}
+
+// getSequence returns the sequence number in the name of a file, or zero if it doesn't have one.
+func (this *TrajectoryRecorder) getSequence(path string) int {
+
+	name := strings.TrimSuffix(strings.TrimPrefix(filepath.Base(path), this.Prefix+"-"), ".jsonl")
+	sequence, err := strconv.Atoi(name)
+	if err != nil || sequence < 0 {
+
+		return 0
+	}
+
+	return sequence
+}
+
+// ReadTrajectories reads the records in a file that was written by a TrajectoryRecorder.
+func ReadTrajectories(path string) ([]*TrajectoryRecord, error) {
+
+	file, err := os.Open(path)
+	if err != nil {
+
+		return nil, err
+	}
+	defer file.Close()
+
+	records := make([]*TrajectoryRecord, 0)
+	reader := bufio.NewReader(file)
+	for {
+
+		line, err := reader.ReadBytes('\n')
+		if len(strings.TrimSpace(string(line))) > 0 {
+
+			record := new(TrajectoryRecord)
+			unmarshalErr := json.Unmarshal(line, record)
+			if unmarshalErr != nil {
+
+				return nil, unmarshalErr
+			}
+
+			records = append(records, record)
+		}
+
+		if err == io.EOF {
+
+			break
+
+		} else if err != nil {
+
+			return nil, err
+		}
+	}
+
+	return records, nil
+}